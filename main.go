@@ -2,29 +2,47 @@ package main
 
 import (
 	"database/sql"
+	"dating-app/src/auth"
+	"dating-app/src/config"
 	"dating-app/src/controllers"
-	"dating-app/src/models"
+	"dating-app/src/deck"
+	"dating-app/src/interactors"
+	"dating-app/src/media"
+	"dating-app/src/migrations"
+	"dating-app/src/repositories"
+	"dating-app/src/storage"
+	"flag"
 	"fmt"
 	_ "github.com/go-sql-driver/mysql"
-	"github.com/golang-jwt/jwt"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"log"
 	"net/http"
-	"regexp"
-	"strings"
+	"os"
 	"time"
 )
 
 /*
 main
+	load config
 	connect to db
-	create database tables
-	initialise controllers with db access (to init interactors)
+	apply schema migrations (or roll them back with -rollback)
+	repair likability scores from the ledger and exit with -repair-likability
+	initialise repositories with db access and pass them to the controllers (to init interactors)
 	specify routes
 	start server
 */
 func main() {
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "optional .json or .yaml config file, environment variables take precedence")
+	rollback := flag.Int("rollback", 0, "roll back the given number of schema migrations and exit")
+	repairLikability := flag.Bool("repair-likability", false, "recompute every user's likability from the ledger and exit")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Echo instance
 	e := echo.New()
 
@@ -32,9 +50,9 @@ func main() {
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
 
-	// Connect to the database with the name of the database container and it's login details.
+	// Connect to the database, by default the database container with it's login details.
 	fmt.Println("Connecting to db")
-	conn, err := sql.Open("mysql", "root:mypassword@tcp(db:3306)/testdb")
+	conn, err := sql.Open("mysql", cfg.DatabaseDSN)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -49,103 +67,118 @@ func main() {
 	}
 	fmt.Println("Connected")
 
-	err = migrateTable(conn)
+	migrator, err := migrations.NewRunner(conn)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	readTokenForRequest := readToken(models.JWT_KEY, "token")
+	if *rollback > 0 {
+		err = migrator.Down(*rollback)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-	auth := controllers.NewAuth(conn)
-	e.POST("/user/create", auth.Create)
-	e.POST("/login", auth.Login)
+	err = migrator.Up()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	match := controllers.NewMatch(conn)
-	e.GET("/profiles", match.Profiles, readTokenForRequest)
-	e.POST("/swipe", match.Swipe, readTokenForRequest)
+	users := repositories.NewMySQLUserRepository(conn)
+	matches := repositories.NewMySQLMatchRepository(conn)
+	sessions := repositories.NewMySQLSessionRepository(conn)
+	profiles := repositories.NewMySQLProfileRepository(conn)
+	photos := repositories.NewMySQLPhotoRepository(conn)
 
-	e.GET("/health", healthCheck)
+	if *repairLikability {
+		repaired, err := interactors.NewLikability(repositories.NewMySQLLikabilityRepository(conn)).Repair()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Repaired likability for %d users\n", repaired)
+		return
+	}
 
-	// Start server
-	e.Logger.Fatal(e.Start(":8080"))
-}
+	blobs, err := storage.New(cfg.Media)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// photos kept on this server are served by it unless they're published somewhere else
+	if local, ok := blobs.(*storage.Local); ok && cfg.Media.PublicURL == "" {
+		e.Static(storage.LocalPath, local.Dir())
+	}
 
-func healthCheck(c echo.Context) error {
-	return c.String(http.StatusOK, "")
-}
+	decks, err := deck.New(cfg.Deck)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-/*
-readToken - read JWT token from request so we can pull the user id in the required functions
-*/
-func readToken(signingKey []byte, contextKey string) echo.MiddlewareFunc {
-	return func(next echo.HandlerFunc) echo.HandlerFunc {
-		return func(c echo.Context) (err error) {
-			token, err := parse(c, signingKey)
-			if token != nil && err == nil {
-				c.Set(contextKey, token)
-			}
-
-			return next(c)
-		}
+	keys, err := auth.LoadKeySet(cfg)
+	if err != nil {
+		log.Fatal(err)
 	}
-}
+	tokens := auth.NewTokens(cfg, keys)
 
-var jwtRegex = regexp.MustCompile("^Bearer\\s+(.*)$")
+	requireAuth := auth.Middleware(tokens, interactors.NewSession(cfg, tokens, sessions))
 
-func parse(c echo.Context, key []byte) (*jwt.Token, error) {
-	tokenString := getTokenStringFromHeader(c)
-	if tokenString == "" {
-		return nil, fmt.Errorf("Token not present")
-	}
+	// passwords from before hashing was introduced are upgraded in the background,
+	// until then they're still accepted and upgraded on login
+	go func() {
+		err := interactors.NewAuth(cfg, users).UpgradeLegacyPasswords()
+		if err != nil {
+			log.Println(err)
+		}
+	}()
+
+	authController := controllers.NewAuth(cfg, tokens, users, sessions)
+	e.POST("/user/register", authController.Register)
+	e.POST("/login", authController.Login)
+	e.POST("/token/refresh", authController.Refresh)
+	e.POST("/logout", authController.Logout, requireAuth)
+	e.POST("/logout/all", authController.LogoutAll, requireAuth)
+
+	match := controllers.NewMatch(cfg, users, matches, profiles, photos, blobs, decks)
+	e.GET("/profiles", match.Profiles, requireAuth)
+	e.POST("/swipe", match.Swipe, requireAuth)
+	e.GET("/matches", match.Matches, requireAuth)
+
+	me := controllers.NewMe(users, profiles, photos, blobs, decks)
+	e.GET("/me", me.Get, requireAuth)
+	e.PATCH("/me", me.Patch, requireAuth)
+	e.PUT("/me/location", me.UpdateLocation, requireAuth)
+	e.GET("/me/preferences", me.Preferences, requireAuth)
+	e.PUT("/me/preferences", me.UpdatePreferences, requireAuth)
+	e.PUT("/me/interests", me.UpdateInterests, requireAuth)
+	e.PUT("/me/prompts", me.UpdatePrompts, requireAuth)
+
+	photoController := controllers.NewPhotos(photos, blobs)
+	// the photo plus the rest of the multipart body
+	uploadLimit := middleware.BodyLimit(fmt.Sprintf("%dM", media.MaxFileSize>>20+1))
+	e.GET("/me/photos", photoController.List, requireAuth)
+	e.POST("/me/photos", photoController.Upload, requireAuth, uploadLimit)
+	e.PUT("/me/photos/order", photoController.Order, requireAuth)
+	e.PUT("/me/photos/:id/primary", photoController.MakePrimary, requireAuth)
+	e.DELETE("/me/photos/:id", photoController.Delete, requireAuth)
+
+	catalog := controllers.NewCatalog(users, profiles, photos, blobs, decks)
+	e.GET("/interests", catalog.Interests, requireAuth)
+	e.GET("/prompts", catalog.Prompts, requireAuth)
+	e.GET("/genders", catalog.Genders, requireAuth)
+
+	e.GET("/.well-known/jwks.json", controllers.NewJWKS(keys).Get)
 
-	return parseTokenString(tokenString, key)
-}
+	e.GET("/health", healthCheck)
 
-func getTokenStringFromHeader(c echo.Context) string {
-	header := c.Request().Header.Get(echo.HeaderAuthorization)
-	if jwtRegex.MatchString(header) {
-		return strings.TrimSpace(jwtRegex.FindStringSubmatch(header)[1])
+	// random users are only for seeding development environments
+	if !cfg.IsProduction() {
+		e.POST("/dev/user/create", authController.CreateRandom)
 	}
 
-	return ""
+	// Start server
+	e.Logger.Fatal(e.Start(cfg.Address()))
 }
 
-func parseTokenString(tokenString string, key []byte) (*jwt.Token, error) {
-	return jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
-		if token.Method.Alg() != "HS256" {
-			return nil, fmt.Errorf("Invalid jwt signing method")
-		}
-		return key, nil
-	})
+func healthCheck(c echo.Context) error {
+	return c.String(http.StatusOK, "")
 }
-
-func migrateTable(db *sql.DB) error {
-	_, err := db.Exec(`
-	CREATE TABLE IF NOT EXISTS users
-(
-	id int auto_increment,
-	email varchar(255) NOT NULL,
-	password varchar(255) NOT NULL,
-	name varchar(255) NOT NULL,
-	gender int NOT NULL DEFAULT 2,
-	date_of_birth datetime,
-	latitude int,
-	longitude int,
-	likability int NOT NULL DEFAULT 0,
-	PRIMARY KEY (id)
-);`)
-	if err != nil {
-		log.Fatal(err)
-	}
-	_, err = db.Exec(`
-	CREATE TABLE IF NOT EXISTS matches
-(
-	user_id int,
-	match_user_id int,
-	state int default 0
-);`)
-	if err != nil {
-		log.Fatal(err)
-	}
-	return nil
-}
\ No newline at end of file
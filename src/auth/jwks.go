@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+)
+
+/*
+JWK - a public key in the JSON Web Key format (RFC 7517), RSA keys fill n and e, Ed25519 keys fill crv and x (RFC 8037)
+*/
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+/*
+JWKS - the published keys other services can verify our tokens with
+*/
+func (s *KeySet) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+
+	for _, key := range s.Published() {
+		jwk := JWK{
+			KeyID:     key.ID,
+			Use:       "sig",
+			Algorithm: key.Algorithm,
+		}
+
+		switch public := key.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
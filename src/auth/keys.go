@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"dating-app/src/config"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"os"
+	"sort"
+	"time"
+)
+
+var ErrNoSigningKey = errors.New("no active signing key")
+
+const minRSABits = 2048
+
+/*
+Key - a signing key and the schedule it's used on
+*/
+type Key struct {
+	config.SigningKey
+	Algorithm string
+	private   crypto.Signer
+	public    crypto.PublicKey
+}
+
+/*
+KeySet - every configured signing key
+*/
+type KeySet struct {
+	keys []*Key
+	now  func() time.Time
+}
+
+/*
+LoadKeySet - reads the private keys in the config
+Outside of production, if no keys are configured a temporary Ed25519 key is generated. Tokens signed with it
+stop working when the app restarts
+*/
+func LoadKeySet(cfg *config.Config) (*KeySet, error) {
+	set := &KeySet{now: time.Now}
+
+	for _, keyConfig := range cfg.JWTKeys {
+		key, err := loadKey(keyConfig)
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %s: %w", keyConfig.ID, err)
+		}
+		set.keys = append(set.keys, key)
+	}
+
+	if len(set.keys) == 0 && !cfg.IsProduction() {
+		key, err := temporaryKey()
+		if err != nil {
+			return nil, err
+		}
+		set.keys = append(set.keys, key)
+	}
+
+	// newest first so SigningKey picks the most recently activated key
+	sort.SliceStable(set.keys, func(i, j int) bool {
+		return set.keys[i].ActivateAt.After(set.keys[j].ActivateAt)
+	})
+
+	return set, nil
+}
+
+func loadKey(keyConfig config.SigningKey) (*Key, error) {
+	contents, err := os.ReadFile(keyConfig.PrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(contents)
+	if block == nil {
+		return nil, errors.New("private_key_file is not PEM encoded")
+	}
+
+	var parsed any
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newKey(keyConfig, parsed)
+}
+
+/*
+newKey - the algorithm is decided by the type of key, RSA keys sign with RS256 and Ed25519 keys with EdDSA
+*/
+func newKey(keyConfig config.SigningKey, private any) (*Key, error) {
+	switch private := private.(type) {
+	case *rsa.PrivateKey:
+		if private.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("RSA keys must be at least %d bits", minRSABits)
+		}
+		return &Key{SigningKey: keyConfig, Algorithm: jwt.SigningMethodRS256.Alg(), private: private, public: &private.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &Key{SigningKey: keyConfig, Algorithm: jwt.SigningMethodEdDSA.Alg(), private: private, public: private.Public()}, nil
+	default:
+		return nil, errors.New("only RSA and Ed25519 keys are supported")
+	}
+}
+
+func temporaryKey() (*Key, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 8)
+	_, err = rand.Read(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return newKey(config.SigningKey{
+		ID:         "dev-" + hex.EncodeToString(id),
+		ActivateAt: time.Now().Add(-time.Minute),
+	}, private)
+}
+
+/*
+SigningKey - the most recently activated key that is active now
+*/
+func (s *KeySet) SigningKey() (*Key, error) {
+	now := s.now()
+	for _, key := range s.keys {
+		if key.Active(now) {
+			return key, nil
+		}
+	}
+
+	return nil, ErrNoSigningKey
+}
+
+/*
+VerificationKey - the key a token's kid refers to, if it hasn't been retired
+keys that aren't active yet are accepted so that a token signed by another replica
+whose clock is slightly ahead still verifies
+*/
+func (s *KeySet) VerificationKey(kid string) (*Key, bool) {
+	now := s.now()
+	for _, key := range s.keys {
+		if key.ID == kid && !key.Retired(now) {
+			return key, true
+		}
+	}
+
+	return nil, false
+}
+
+/*
+Published - every key that isn't retired, including ones that will activate later
+so that other services have cached them before they're used
+*/
+func (s *KeySet) Published() []*Key {
+	now := s.now()
+
+	var keys []*Key
+	for _, key := range s.keys {
+		if !key.Retired(now) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+func (k *Key) Public() crypto.PublicKey {
+	return k.public
+}
@@ -0,0 +1,249 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"dating-app/src/config"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func publishedIDs(keys *KeySet) []string {
+	var ids []string
+	for _, jwk := range keys.JWKS().Keys {
+		ids = append(ids, jwk.KeyID)
+	}
+	return ids
+}
+
+func equalIDs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+TestKeyRotation - an old key retiring an hour after testNow and a new key that activates an hour before it,
+so the two overlap for two hours
+*/
+func TestKeyRotation(t *testing.T) {
+	old := rsaKey(t, config.SigningKey{
+		ID:         "old",
+		ActivateAt: testNow.Add(-30 * 24 * time.Hour),
+		RetireAt:   at(testNow.Add(time.Hour)),
+	})
+	next := ed25519Key(t, config.SigningKey{ID: "next", ActivateAt: testNow.Add(-time.Hour)})
+
+	tests := []struct {
+		name          string
+		now           time.Time
+		wantSigning   string
+		wantVerifies  []string
+		wantRejects   []string
+		wantPublished []string
+	}{
+		{
+			// next isn't active yet but is published so verifiers have it cached before it signs anything
+			name:          "before the overlap",
+			now:           testNow.Add(-2 * time.Hour),
+			wantSigning:   "old",
+			wantVerifies:  []string{"old", "next"},
+			wantPublished: []string{"next", "old"},
+		},
+		{
+			name:          "during the overlap",
+			now:           testNow,
+			wantSigning:   "next",
+			wantVerifies:  []string{"old", "next"},
+			wantPublished: []string{"next", "old"},
+		},
+		{
+			name:          "at retire_at",
+			now:           testNow.Add(time.Hour),
+			wantSigning:   "next",
+			wantVerifies:  []string{"next"},
+			wantRejects:   []string{"old"},
+			wantPublished: []string{"next"},
+		},
+		{
+			name:          "after the overlap",
+			now:           testNow.Add(2 * time.Hour),
+			wantSigning:   "next",
+			wantVerifies:  []string{"next"},
+			wantRejects:   []string{"old"},
+			wantPublished: []string{"next"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keys := newTestKeySet(test.now, next, old)
+
+			signing, err := keys.SigningKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if signing.ID != test.wantSigning {
+				t.Errorf("signing key = %s, want %s", signing.ID, test.wantSigning)
+			}
+
+			for _, id := range test.wantVerifies {
+				if key, ok := keys.VerificationKey(id); !ok || key.ID != id {
+					t.Errorf("%s doesn't verify", id)
+				}
+			}
+			for _, id := range test.wantRejects {
+				if _, ok := keys.VerificationKey(id); ok {
+					t.Errorf("%s still verifies", id)
+				}
+			}
+			if _, ok := keys.VerificationKey("unknown"); ok {
+				t.Error("an unknown kid verifies")
+			}
+
+			if ids := publishedIDs(keys); !equalIDs(ids, test.wantPublished) {
+				t.Errorf("published = %v, want %v", ids, test.wantPublished)
+			}
+		})
+	}
+}
+
+func TestSigningKeyNoneActive(t *testing.T) {
+	upcoming := ed25519Key(t, config.SigningKey{ID: "upcoming", ActivateAt: testNow.Add(time.Hour)})
+	retired := ed25519Key(t, config.SigningKey{
+		ID:         "retired",
+		ActivateAt: testNow.Add(-48 * time.Hour),
+		RetireAt:   at(testNow.Add(-time.Hour)),
+	})
+	keys := newTestKeySet(testNow, upcoming, retired)
+
+	if _, err := keys.SigningKey(); !errors.Is(err, ErrNoSigningKey) {
+		t.Errorf("err = %v, want ErrNoSigningKey", err)
+	}
+	if _, ok := keys.VerificationKey("upcoming"); !ok {
+		t.Error("a key that isn't active yet should still verify")
+	}
+	if ids := publishedIDs(keys); !equalIDs(ids, []string{"upcoming"}) {
+		t.Errorf("published = %v, want [upcoming]", ids)
+	}
+}
+
+func TestJWKSEncoding(t *testing.T) {
+	rsaCurrent := rsaKey(t, config.SigningKey{ID: "rsa", ActivateAt: testNow.Add(-time.Hour)})
+	edCurrent := ed25519Key(t, config.SigningKey{ID: "ed", ActivateAt: testNow.Add(-2 * time.Hour)})
+	keys := newTestKeySet(testNow, rsaCurrent, edCurrent)
+
+	set := keys.JWKS()
+	if len(set.Keys) != 2 {
+		t.Fatalf("got %d keys, want 2", len(set.Keys))
+	}
+
+	rsaJWK := set.Keys[0]
+	if rsaJWK.KeyType != "RSA" || rsaJWK.KeyID != "rsa" || rsaJWK.Algorithm != "RS256" || rsaJWK.Use != "sig" {
+		t.Errorf("RSA JWK = %+v", rsaJWK)
+	}
+	if rsaJWK.Curve != "" || rsaJWK.X != "" {
+		t.Errorf("RSA JWK has OKP members: %+v", rsaJWK)
+	}
+	n, err := base64.RawURLEncoding.DecodeString(rsaJWK.N)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(rsaJWK.E)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public := rsaCurrent.Public().(*rsa.PublicKey)
+	if new(big.Int).SetBytes(n).Cmp(public.N) != 0 {
+		t.Error("n doesn't match the modulus")
+	}
+	if int(new(big.Int).SetBytes(e).Int64()) != public.E {
+		t.Errorf("e = %x, want %d", e, public.E)
+	}
+	// 65537 is AQAB, with no leading zero bytes
+	if rsaJWK.E != "AQAB" {
+		t.Errorf("e = %q, want AQAB", rsaJWK.E)
+	}
+
+	edJWK := set.Keys[1]
+	if edJWK.KeyType != "OKP" || edJWK.Curve != "Ed25519" || edJWK.KeyID != "ed" || edJWK.Algorithm != "EdDSA" || edJWK.Use != "sig" {
+		t.Errorf("Ed25519 JWK = %+v", edJWK)
+	}
+	if edJWK.N != "" || edJWK.E != "" {
+		t.Errorf("Ed25519 JWK has RSA members: %+v", edJWK)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(edJWK.X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !edCurrent.Public().(ed25519.PublicKey).Equal(ed25519.PublicKey(x)) {
+		t.Error("x doesn't match the public key")
+	}
+}
+
+func TestJWKSEmpty(t *testing.T) {
+	// an empty set is still {"keys": []}, never null
+	if keys := newTestKeySet(testNow).JWKS().Keys; keys == nil || len(keys) != 0 {
+		t.Errorf("keys = %#v", keys)
+	}
+}
+
+func TestLoadKeySet(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaFile := filepath.Join(dir, "rsa.pem")
+	writePEM(t, rsaFile, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey(t, config.SigningKey{}).private.(*rsa.PrivateKey)))
+	edFile := filepath.Join(dir, "ed.pem")
+	edDER, err := x509.MarshalPKCS8PrivateKey(ed25519Key(t, config.SigningKey{}).private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, edFile, "PRIVATE KEY", edDER)
+
+	cfg := config.Default()
+	cfg.Environment = config.Production
+	cfg.JWTKeys = []config.SigningKey{
+		{ID: "older", PrivateKeyFile: rsaFile, ActivateAt: testNow.Add(-time.Hour)},
+		{ID: "newer", PrivateKeyFile: edFile, ActivateAt: testNow},
+	}
+
+	keys, err := LoadKeySet(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys.keys) != 2 || keys.keys[0].ID != "newer" || keys.keys[1].ID != "older" {
+		t.Fatalf("keys aren't newest first")
+	}
+	if keys.keys[0].Algorithm != "EdDSA" || keys.keys[1].Algorithm != "RS256" {
+		t.Errorf("algorithms = %s, %s", keys.keys[0].Algorithm, keys.keys[1].Algorithm)
+	}
+
+	cfg.JWTKeys = nil
+	keys, err = LoadKeySet(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys.keys) != 0 {
+		t.Error("production generated a temporary key")
+	}
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
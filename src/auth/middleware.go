@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+/*
+SessionChecker - reports whether a session is still logged in, satisfied by interactors.Session
+*/
+type SessionChecker interface {
+	IsActive(sessionID string) (bool, error)
+}
+
+/*
+ErrorResponse - body of every 401 returned by Middleware
+Error is a stable code clients can switch on, Message is for humans
+*/
+type ErrorResponse struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+var bearerRegex = regexp.MustCompile(`^Bearer\s+(.*)$`)
+
+/*
+Middleware - requires a valid access token on the request
+The token must have a valid signature, issuer, audience and expiry and belong to a session that hasn't been
+logged out, otherwise the request is rejected with a 401 before it reaches the handler.
+Handlers read the caller with PrincipalFrom
+*/
+func Middleware(tokens *Tokens, sessions SessionChecker) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			tokenString := tokenFromHeader(c)
+			if tokenString == "" {
+				return unauthorised(c, "missing_token", "an access token is required")
+			}
+
+			claims, err := tokens.Parse(tokenString)
+			if errors.Is(err, ErrTokenExpired) {
+				return unauthorised(c, "token_expired", "the access token has expired")
+			}
+			if err != nil {
+				return unauthorised(c, "invalid_token", "the access token is invalid")
+			}
+
+			active, err := sessions.IsActive(claims.SessionID)
+			if err != nil {
+				log.Error(err)
+				return err
+			}
+			if !active {
+				return unauthorised(c, "session_revoked", "the session has been logged out")
+			}
+
+			c.Set(principalKey, &Principal{
+				UserID:    claims.UserID,
+				Roles:     claims.Roles,
+				SessionID: claims.SessionID,
+			})
+
+			return next(c)
+		}
+	}
+}
+
+func tokenFromHeader(c echo.Context) string {
+	header := c.Request().Header.Get(echo.HeaderAuthorization)
+	if matches := bearerRegex.FindStringSubmatch(header); matches != nil {
+		return strings.TrimSpace(matches[1])
+	}
+
+	return ""
+}
+
+/*
+unauthorised - writes the 401, with the WWW-Authenticate challenge from RFC 6750
+*/
+func unauthorised(c echo.Context, code, message string) error {
+	challenge := `Bearer realm="dating-app"`
+	if code != "missing_token" {
+		challenge += `, error="invalid_token"`
+	}
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, challenge)
+
+	return c.JSON(http.StatusUnauthorized, ErrorResponse{
+		Error:   code,
+		Message: message,
+	})
+}
@@ -0,0 +1,321 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"dating-app/src/config"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func at(t time.Time) *time.Time {
+	return &t
+}
+
+func rsaKey(t *testing.T, keyConfig config.SigningKey) *Key {
+	t.Helper()
+
+	private, err := rsa.GenerateKey(rand.Reader, minRSABits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := newKey(keyConfig, private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func ed25519Key(t *testing.T, keyConfig config.SigningKey) *Key {
+	t.Helper()
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := newKey(keyConfig, private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+/*
+newTestKeySet - keys newest first like LoadKeySet leaves them, on a clock fixed at now
+*/
+func newTestKeySet(now time.Time, keys ...*Key) *KeySet {
+	return &KeySet{keys: keys, now: func() time.Time { return now }}
+}
+
+type sessionSet map[string]bool
+
+func (s sessionSet) IsActive(sessionID string) (bool, error) {
+	return s[sessionID], nil
+}
+
+func TestMiddleware(t *testing.T) {
+	rsaCurrent := rsaKey(t, config.SigningKey{ID: "rsa-current", ActivateAt: testNow.Add(-time.Hour)})
+	edCurrent := ed25519Key(t, config.SigningKey{ID: "ed-current", ActivateAt: testNow.Add(-2 * time.Hour)})
+	retired := ed25519Key(t, config.SigningKey{
+		ID:         "ed-retired",
+		ActivateAt: testNow.Add(-48 * time.Hour),
+		RetireAt:   at(testNow.Add(-time.Hour)),
+	})
+	keys := newTestKeySet(testNow, rsaCurrent, edCurrent, retired)
+
+	cfg := config.Default()
+	tokens := NewTokens(&cfg, keys)
+	sessions := sessionSet{"active": true, "revoked": false}
+
+	claims := func(change func(*Claims)) *Claims {
+		claims := &Claims{
+			UserID:    7,
+			SessionID: "active",
+			Roles:     []string{RoleUser},
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    cfg.JWTIssuer,
+				Audience:  jwt.ClaimStrings{cfg.JWTAudience},
+				IssuedAt:  jwt.NewNumericDate(time.Now()),
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+		}
+		if change != nil {
+			change(claims)
+		}
+		return claims
+	}
+
+	sign := func(t *testing.T, key *Key, claims *Claims) string {
+		t.Helper()
+
+		token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), claims)
+		token.Header["kid"] = key.ID
+		signed, err := token.SignedString(key.private)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	// the classic alg confusion attack, an HMAC keyed with the public key anyone can fetch from the JWKS
+	algConfusion := func(t *testing.T) string {
+		t.Helper()
+
+		der, err := x509.MarshalPKIXPublicKey(rsaCurrent.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		public := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims(nil))
+		token.Header["kid"] = rsaCurrent.ID
+		signed, err := token.SignedString(public)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name          string
+		authorization func(t *testing.T) string
+		wantCode      string
+	}{
+		{
+			name:          "missing bearer token",
+			authorization: func(t *testing.T) string { return "" },
+			wantCode:      "missing_token",
+		},
+		{
+			name:          "not a bearer token",
+			authorization: func(t *testing.T) string { return "Basic dXNlcjpwYXNz" },
+			wantCode:      "missing_token",
+		},
+		{
+			name: "expired",
+			authorization: func(t *testing.T) string {
+				return "Bearer " + sign(t, rsaCurrent, claims(func(c *Claims) {
+					c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+				}))
+			},
+			wantCode: "token_expired",
+		},
+		{
+			name: "no expiry",
+			authorization: func(t *testing.T) string {
+				return "Bearer " + sign(t, rsaCurrent, claims(func(c *Claims) { c.ExpiresAt = nil }))
+			},
+			wantCode: "invalid_token",
+		},
+		{
+			name: "wrong issuer",
+			authorization: func(t *testing.T) string {
+				return "Bearer " + sign(t, rsaCurrent, claims(func(c *Claims) { c.Issuer = "someone-else" }))
+			},
+			wantCode: "invalid_token",
+		},
+		{
+			name: "wrong audience",
+			authorization: func(t *testing.T) string {
+				return "Bearer " + sign(t, rsaCurrent, claims(func(c *Claims) { c.Audience = jwt.ClaimStrings{"another-app"} }))
+			},
+			wantCode: "invalid_token",
+		},
+		{
+			name: "unknown kid",
+			authorization: func(t *testing.T) string {
+				unknown := ed25519Key(t, config.SigningKey{ID: "unknown", ActivateAt: testNow.Add(-time.Hour)})
+				return "Bearer " + sign(t, unknown, claims(nil))
+			},
+			wantCode: "invalid_token",
+		},
+		{
+			name: "kid of a key from another set",
+			authorization: func(t *testing.T) string {
+				impostor := ed25519Key(t, config.SigningKey{ID: edCurrent.ID, ActivateAt: testNow.Add(-time.Hour)})
+				return "Bearer " + sign(t, impostor, claims(nil))
+			},
+			wantCode: "invalid_token",
+		},
+		{
+			name: "retired kid",
+			authorization: func(t *testing.T) string {
+				return "Bearer " + sign(t, retired, claims(nil))
+			},
+			wantCode: "invalid_token",
+		},
+		{
+			name:          "alg confusion",
+			authorization: func(t *testing.T) string { return "Bearer " + algConfusion(t) },
+			wantCode:      "invalid_token",
+		},
+		{
+			name: "missing user_id",
+			authorization: func(t *testing.T) string {
+				return "Bearer " + sign(t, rsaCurrent, claims(func(c *Claims) { c.UserID = 0 }))
+			},
+			wantCode: "invalid_token",
+		},
+		{
+			name: "missing sid",
+			authorization: func(t *testing.T) string {
+				return "Bearer " + sign(t, rsaCurrent, claims(func(c *Claims) { c.SessionID = "" }))
+			},
+			wantCode: "invalid_token",
+		},
+		{
+			name: "revoked session",
+			authorization: func(t *testing.T) string {
+				return "Bearer " + sign(t, rsaCurrent, claims(func(c *Claims) { c.SessionID = "revoked" }))
+			},
+			wantCode: "session_revoked",
+		},
+		{
+			name: "unknown session",
+			authorization: func(t *testing.T) string {
+				return "Bearer " + sign(t, edCurrent, claims(func(c *Claims) { c.SessionID = "forgotten" }))
+			},
+			wantCode: "session_revoked",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, recorder := newContext(test.authorization(t))
+
+			reached := false
+			err := Middleware(tokens, sessions)(func(c echo.Context) error {
+				reached = true
+				return c.NoContent(http.StatusOK)
+			})(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if reached {
+				t.Fatal("handler was reached")
+			}
+			if recorder.Code != http.StatusUnauthorized {
+				t.Fatalf("status = %d, want %d", recorder.Code, http.StatusUnauthorized)
+			}
+			if recorder.Header().Get(echo.HeaderWWWAuthenticate) == "" {
+				t.Error("no WWW-Authenticate challenge")
+			}
+
+			var response ErrorResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if response.Error != test.wantCode {
+				t.Errorf("error = %q, want %q", response.Error, test.wantCode)
+			}
+		})
+	}
+
+	for _, key := range []*Key{rsaCurrent, edCurrent} {
+		t.Run("success "+key.Algorithm, func(t *testing.T) {
+			c, recorder := newContext("Bearer " + sign(t, key, claims(nil)))
+
+			var principal *Principal
+			err := Middleware(tokens, sessions)(func(c echo.Context) error {
+				principal, _ = PrincipalFrom(c)
+				return c.NoContent(http.StatusOK)
+			})(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", recorder.Code, recorder.Body)
+			}
+			if principal == nil {
+				t.Fatal("no principal on the context")
+			}
+			if principal.UserID != 7 || principal.SessionID != "active" || !principal.HasRole(RoleUser) {
+				t.Errorf("principal = %+v", principal)
+			}
+		})
+	}
+
+	t.Run("issued by Tokens", func(t *testing.T) {
+		token, err := tokens.Issue(9, "active", []string{RoleUser})
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, _ := newContext("Bearer " + token)
+
+		var principal *Principal
+		err = Middleware(tokens, sessions)(func(c echo.Context) error {
+			principal, _ = PrincipalFrom(c)
+			return nil
+		})(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if principal == nil || principal.UserID != 9 {
+			t.Errorf("principal = %+v", principal)
+		}
+	})
+}
+
+func newContext(authorization string) (echo.Context, *httptest.ResponseRecorder) {
+	request := httptest.NewRequest(http.MethodGet, "/me", nil)
+	if authorization != "" {
+		request.Header.Set(echo.HeaderAuthorization, authorization)
+	}
+	recorder := httptest.NewRecorder()
+
+	return echo.New().NewContext(request, recorder), recorder
+}
@@ -0,0 +1,34 @@
+package auth
+
+import "github.com/labstack/echo/v4"
+
+const RoleUser = "user"
+
+const principalKey = "principal"
+
+/*
+Principal - the authenticated caller of a request, put on the echo.Context by Middleware
+*/
+type Principal struct {
+	UserID    int
+	Roles     []string
+	SessionID string
+}
+
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+PrincipalFrom - the principal of an authenticated request
+ok is false if the route isn't behind Middleware
+*/
+func PrincipalFrom(c echo.Context) (*Principal, bool) {
+	principal, ok := c.Get(principalKey).(*Principal)
+	return principal, ok && principal != nil
+}
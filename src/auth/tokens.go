@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"dating-app/src/config"
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrTokenExpired = errors.New("token has expired")
+	ErrTokenInvalid = errors.New("token is invalid")
+)
+
+/*
+Claims - the claims in every access token
+SessionID ties the token to the session it can be revoked with
+*/
+type Claims struct {
+	UserID    int      `json:"user_id"`
+	SessionID string   `json:"sid"`
+	Roles     []string `json:"roles"`
+	jwt.RegisteredClaims
+}
+
+/*
+Tokens - issues and verifies access tokens
+*/
+type Tokens struct {
+	keys     *KeySet
+	issuer   string
+	audience string
+	ttl      time.Duration
+	parser   *jwt.Parser
+}
+
+func NewTokens(cfg *config.Config, keys *KeySet) *Tokens {
+	return &Tokens{
+		keys:     keys,
+		issuer:   cfg.JWTIssuer,
+		audience: cfg.JWTAudience,
+		ttl:      cfg.AccessTokenTTL.Duration(),
+		parser:   jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()})),
+	}
+}
+
+func (t *Tokens) TTL() time.Duration {
+	return t.ttl
+}
+
+/*
+Issue - signs an access token for the user's session with the current signing key
+*/
+func (t *Tokens) Issue(userID int, sessionID string, roles []string) (string, error) {
+	key, err := t.keys.SigningKey()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+
+	claims := &Claims{
+		UserID:    userID,
+		SessionID: sessionID,
+		Roles:     roles,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.Itoa(userID),
+			Issuer:    t.issuer,
+			Audience:  jwt.ClaimStrings{t.audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(t.ttl)),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), claims)
+	token.Header["kid"] = key.ID
+
+	return token.SignedString(key.private)
+}
+
+/*
+Parse - verifies the signature, expiry, issuer and audience of an access token and returns its claims
+the signature is checked with the key named by the token's kid, which must not be retired
+the error is ErrTokenExpired for an otherwise valid token that has expired and ErrTokenInvalid for anything else
+*/
+func (t *Tokens) Parse(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := t.parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := t.keys.VerificationKey(kid)
+		if !ok {
+			return nil, ErrTokenInvalid
+		}
+		if token.Method.Alg() != key.Algorithm {
+			return nil, ErrTokenInvalid
+		}
+		return key.Public(), nil
+	})
+	if err != nil {
+		var validationErr *jwt.ValidationError
+		if errors.As(err, &validationErr) && validationErr.Errors == jwt.ValidationErrorExpired {
+			return nil, ErrTokenExpired
+		}
+		return nil, ErrTokenInvalid
+	}
+
+	if claims.ExpiresAt == nil ||
+		!claims.VerifyIssuer(t.issuer, true) ||
+		!claims.VerifyAudience(t.audience, true) ||
+		claims.UserID < 1 ||
+		claims.SessionID == "" {
+		return nil, ErrTokenInvalid
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,271 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	Development = "development"
+	Production  = "production"
+)
+
+/*
+Config - everything the app needs to know about the environment it's running in
+Values are read in order of precedence: environment variables, then the optional config file, then the defaults
+*/
+type Config struct {
+	Environment        string       `json:"environment" yaml:"environment"`
+	Port               int          `json:"port" yaml:"port"`
+	DatabaseDSN        string       `json:"database_dsn" yaml:"database_dsn"`
+	JWTKeys            []SigningKey `json:"jwt_keys" yaml:"jwt_keys"`
+	JWTIssuer          string       `json:"jwt_issuer" yaml:"jwt_issuer"`
+	JWTAudience        string       `json:"jwt_audience" yaml:"jwt_audience"`
+	AccessTokenTTL     Duration     `json:"access_token_ttl" yaml:"access_token_ttl"`
+	RefreshTokenTTL    Duration     `json:"refresh_token_ttl" yaml:"refresh_token_ttl"`
+	PrivacySecret      string       `json:"privacy_secret" yaml:"privacy_secret"`
+	PlaintextPasswords *bool        `json:"plaintext_passwords" yaml:"plaintext_passwords"`
+	Media              Media        `json:"media" yaml:"media"`
+	Ranking            Ranking      `json:"ranking" yaml:"ranking"`
+	Deck               Deck         `json:"deck" yaml:"deck"`
+}
+
+/*
+Default - the configuration used by docker-compose for local development
+*/
+func Default() Config {
+	return Config{
+		Environment:     Development,
+		Port:            8080,
+		DatabaseDSN:     "root:mypassword@tcp(db:3306)/testdb",
+		JWTIssuer:       "dating-app",
+		JWTAudience:     "dating-app",
+		AccessTokenTTL:  Duration(60 * time.Minute),
+		RefreshTokenTTL: Duration(30 * 24 * time.Hour),
+		Media: Media{
+			Storage:  LocalStorage,
+			LocalDir: "media",
+		},
+		Deck: Deck{
+			Store:    MemoryDecks,
+			Capacity: 500,
+			TTL:      Duration(15 * time.Minute),
+		},
+	}
+}
+
+/*
+Load - builds the config from the defaults, the file at path (skipped if path is empty) and the environment,
+then validates it
+*/
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		err := cfg.loadFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := cfg.loadEnv(os.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.PrivacySecret == "" {
+		// only reachable outside of production, jittered distances and cursors change on every restart
+		cfg.PrivacySecret, err = randomSecret()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &cfg, nil
+}
+
+/*
+loadFile - reads a .json, .yaml or .yml file over the top of the current values
+fields missing from the file keep their current value
+*/
+func (c *Config) loadFile(path string) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(contents, c)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(contents, c)
+	default:
+		return fmt.Errorf("config file %s must be .json, .yaml or .yml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("reading config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+/*
+loadEnv - overrides the current values with any of the environment variables that are set
+*/
+func (c *Config) loadEnv(lookup func(string) (string, bool)) error {
+	if value, ok := lookup("APP_ENV"); ok {
+		c.Environment = value
+	}
+	if value, ok := lookup("PORT"); ok {
+		port, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("PORT: %w", err)
+		}
+		c.Port = port
+	}
+	if value, ok := lookup("DATABASE_DSN"); ok {
+		c.DatabaseDSN = value
+	}
+	if value, ok := lookup("JWT_KEYS"); ok {
+		var keys []SigningKey
+		err := json.Unmarshal([]byte(value), &keys)
+		if err != nil {
+			return fmt.Errorf("JWT_KEYS: %w", err)
+		}
+		c.JWTKeys = keys
+	}
+	if value, ok := lookup("JWT_ISSUER"); ok {
+		c.JWTIssuer = value
+	}
+	if value, ok := lookup("JWT_AUDIENCE"); ok {
+		c.JWTAudience = value
+	}
+	if value, ok := lookup("ACCESS_TOKEN_TTL"); ok {
+		ttl, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("ACCESS_TOKEN_TTL: %w", err)
+		}
+		c.AccessTokenTTL = Duration(ttl)
+	}
+	if value, ok := lookup("REFRESH_TOKEN_TTL"); ok {
+		ttl, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("REFRESH_TOKEN_TTL: %w", err)
+		}
+		c.RefreshTokenTTL = Duration(ttl)
+	}
+	if value, ok := lookup("PRIVACY_SECRET"); ok {
+		c.PrivacySecret = value
+	}
+	if value, ok := lookup("PLAINTEXT_PASSWORDS"); ok {
+		plaintext, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("PLAINTEXT_PASSWORDS: %w", err)
+		}
+		c.PlaintextPasswords = &plaintext
+	}
+
+	err := c.loadMediaEnv(lookup)
+	if err != nil {
+		return err
+	}
+
+	err = c.loadRankingEnv(lookup)
+	if err != nil {
+		return err
+	}
+
+	return c.loadDeckEnv(lookup)
+}
+
+/*
+Validate - checks the config is usable, reporting every problem at once
+*/
+func (c *Config) Validate() error {
+	var problems []string
+
+	if c.Environment != Development && c.Environment != Production {
+		problems = append(problems, fmt.Sprintf("environment must be %s or %s", Development, Production))
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		problems = append(problems, "port must be between 1 and 65535")
+	}
+	if c.DatabaseDSN == "" {
+		problems = append(problems, "database_dsn is required")
+	}
+	problems = append(problems, c.validateKeys(time.Now())...)
+	if c.JWTIssuer == "" {
+		problems = append(problems, "jwt_issuer is required")
+	}
+	if c.JWTAudience == "" {
+		problems = append(problems, "jwt_audience is required")
+	}
+	if c.AccessTokenTTL <= 0 {
+		problems = append(problems, "access_token_ttl must be positive")
+	}
+	if c.RefreshTokenTTL < c.AccessTokenTTL {
+		problems = append(problems, "refresh_token_ttl must be at least access_token_ttl")
+	}
+	if c.IsProduction() && c.PrivacySecret == "" {
+		problems = append(problems, "privacy_secret is required in production")
+	} else if c.PrivacySecret != "" && len(c.PrivacySecret) < minPrivacySecretLength {
+		problems = append(problems, fmt.Sprintf("privacy_secret must be at least %d characters", minPrivacySecretLength))
+	}
+	problems = append(problems, c.validateMedia()...)
+	problems = append(problems, c.validateRanking()...)
+	problems = append(problems, c.validateDeck()...)
+
+	if len(problems) > 0 {
+		return errors.New("invalid config: " + strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+const minPrivacySecretLength = 32
+
+func randomSecret() (string, error) {
+	secret := make([]byte, minPrivacySecretLength)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+func (c *Config) IsProduction() bool {
+	return c.Environment == Production
+}
+
+/*
+AcceptsPlaintextPasswords - whether a password stored in plaintext, from before passwords were hashed, can still be used to log in.
+Off by default in production, every plaintext password is hashed in the background on start up so they're only needed
+by a database that hasn't been upgraded yet
+*/
+func (c *Config) AcceptsPlaintextPasswords() bool {
+	if c.PlaintextPasswords != nil {
+		return *c.PlaintextPasswords
+	}
+	return !c.IsProduction()
+}
+
+/*
+Address - the address the server listens on
+*/
+func (c *Config) Address() string {
+	return fmt.Sprintf(":%d", c.Port)
+}
@@ -0,0 +1,279 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+env - a lookup over fixed environment variables, so the tests don't depend on the real environment
+*/
+func env(values map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := values[name]
+		return value, ok
+	}
+}
+
+func writeFile(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+/*
+TestPrecedence - the environment overrides the file and the file overrides the defaults,
+whatever the file leaves out keeps its default, nested sections included
+*/
+func TestPrecedence(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": `
+port: 9000
+jwt_issuer: file-issuer
+access_token_ttl: 30m
+deck:
+  ttl: 5m
+`,
+		"config.json": `{"port": 9000, "jwt_issuer": "file-issuer", "access_token_ttl": "30m", "deck": {"ttl": "5m"}}`,
+	}
+
+	for name, contents := range files {
+		t.Run(name, func(t *testing.T) {
+			cfg := Default()
+			if err := cfg.loadFile(writeFile(t, name, contents)); err != nil {
+				t.Fatal(err)
+			}
+			err := cfg.loadEnv(env(map[string]string{
+				"PORT":             "9100",
+				"ACCESS_TOKEN_TTL": "15m",
+				"DECK_CAPACITY":    "50",
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defaults := Default()
+			if cfg.Port != 9100 {
+				t.Errorf("port = %d, want 9100 from the environment over the file", cfg.Port)
+			}
+			if cfg.AccessTokenTTL.Duration() != 15*time.Minute {
+				t.Errorf("access_token_ttl = %v, want 15m from the environment over the file", cfg.AccessTokenTTL.Duration())
+			}
+			if cfg.JWTIssuer != "file-issuer" {
+				t.Errorf("jwt_issuer = %q, want file-issuer from the file over the default", cfg.JWTIssuer)
+			}
+			if cfg.Deck.TTL.Duration() != 5*time.Minute {
+				t.Errorf("deck.ttl = %v, want 5m from the file over the default", cfg.Deck.TTL.Duration())
+			}
+			if cfg.Deck.Capacity != 50 {
+				t.Errorf("deck.capacity = %d, want 50 from the environment", cfg.Deck.Capacity)
+			}
+			if cfg.JWTAudience != defaults.JWTAudience || cfg.RefreshTokenTTL != defaults.RefreshTokenTTL ||
+				cfg.Deck.Store != defaults.Deck.Store || cfg.Media != defaults.Media {
+				t.Errorf("fields missing from the file and the environment lost their defaults: %+v", cfg)
+			}
+		})
+	}
+}
+
+/*
+TestLoad - Load reads the real environment over the file, validates, and fills in a privacy secret outside of production
+*/
+func TestLoad(t *testing.T) {
+	path := writeFile(t, "config.yaml", "port: 9000\njwt_issuer: file-issuer\n")
+	t.Setenv("PORT", "9100")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != 9100 || cfg.JWTIssuer != "file-issuer" {
+		t.Errorf("port %d and jwt_issuer %q, want 9100 from the environment and file-issuer from the file", cfg.Port, cfg.JWTIssuer)
+	}
+	if len(cfg.PrivacySecret) < minPrivacySecretLength {
+		t.Errorf("privacy_secret %q wasn't generated", cfg.PrivacySecret)
+	}
+
+	if cfg, err = Load(""); err != nil || cfg.JWTIssuer != Default().JWTIssuer {
+		t.Errorf("Load without a file = %+v, %v, want the defaults", cfg, err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		env     map[string]string
+		wantErr string
+	}{
+		{name: "unknown extension", file: "config.toml", wantErr: "must be .json, .yaml or .yml"},
+		{name: "broken yaml", file: "config.yaml", wantErr: "reading config file"},
+		{name: "port not a number", env: map[string]string{"PORT": "eighty"}, wantErr: "PORT"},
+		{name: "ttl not a duration", env: map[string]string{"ACCESS_TOKEN_TTL": "an hour"}, wantErr: "ACCESS_TOKEN_TTL"},
+		{name: "plaintext not a bool", env: map[string]string{"PLAINTEXT_PASSWORDS": "maybe"}, wantErr: "PLAINTEXT_PASSWORDS"},
+		{name: "keys not json", env: map[string]string{"JWT_KEYS": "current.pem"}, wantErr: "JWT_KEYS"},
+		{name: "invalid", env: map[string]string{"PORT": "0"}, wantErr: "port must be between 1 and 65535"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := ""
+			if test.file != "" {
+				path = writeFile(t, test.file, "port: [9000\n")
+			}
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+
+			_, err := Load(path)
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("err = %v, want one mentioning %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+/*
+production - a config that is valid in production
+*/
+func production() Config {
+	cfg := Default()
+	cfg.Environment = Production
+	cfg.PrivacySecret = strings.Repeat("s", minPrivacySecretLength)
+	cfg.JWTKeys = []SigningKey{{ID: "current", PrivateKeyFile: "current.pem", ActivateAt: time.Now().Add(-time.Hour)}}
+	return cfg
+}
+
+func TestProductionRequiresSecrets(t *testing.T) {
+	cfg := production()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("valid production config: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		change  func(cfg *Config)
+		wantErr string
+	}{
+		{name: "no privacy secret", change: func(cfg *Config) { cfg.PrivacySecret = "" }, wantErr: "privacy_secret is required in production"},
+		{name: "no keys", change: func(cfg *Config) { cfg.JWTKeys = nil }, wantErr: "jwt_keys are required in production"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := production()
+			test.change(&cfg)
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("err = %v, want one mentioning %q", err, test.wantErr)
+			}
+
+			// development makes do without them
+			cfg.Environment = Development
+			if err := cfg.Validate(); err != nil {
+				t.Errorf("in development: %v", err)
+			}
+		})
+	}
+
+	t.Run("through Load", func(t *testing.T) {
+		t.Setenv("APP_ENV", Production)
+		_, err := Load("")
+		for _, want := range []string{"privacy_secret is required in production", "jwt_keys are required in production"} {
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("err = %v, want one mentioning %q", err, want)
+			}
+		}
+	})
+}
+
+/*
+TestValidateReportsEveryProblem - every problem is in the one error, so a broken deploy is fixed in one go
+*/
+func TestValidateReportsEveryProblem(t *testing.T) {
+	cfg := production()
+	cfg.Port = 0
+	cfg.DatabaseDSN = ""
+	cfg.PrivacySecret = "short"
+	cfg.JWTKeys = append(cfg.JWTKeys, SigningKey{ID: "current"})
+	cfg.AccessTokenTTL = Duration(time.Hour)
+	cfg.RefreshTokenTTL = Duration(time.Minute)
+	cfg.Deck.TTL = 0
+	cfg.Ranking.Experiments = []RankingExperiment{{Name: "default", Traffic: 101, Weights: map[string]float64{"height": 1}}}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("no error")
+	}
+	for _, want := range []string{
+		"port must be between 1 and 65535",
+		"database_dsn is required",
+		`jwt_keys[1].id "current" is used more than once`,
+		"jwt_keys[1].private_key_file is required",
+		"refresh_token_ttl must be at least access_token_ttl",
+		"privacy_secret must be at least 32 characters",
+		"deck.ttl must be positive",
+		`ranking.experiments[0].name "default" is already used`,
+		"ranking.experiments[0].traffic must be between 0 and 100",
+		`ranking.experiments[0].weights has an unknown feature "height"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("%v\ndoesn't mention %q", err, want)
+		}
+	}
+}
+
+/*
+TestAcceptsPlaintextPasswords - left unset, plaintext passwords are accepted everywhere but production
+*/
+func TestAcceptsPlaintextPasswords(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		environment string
+		plaintext   *bool
+		want        bool
+	}{
+		{environment: Development, plaintext: nil, want: true},
+		{environment: Production, plaintext: nil, want: false},
+		{environment: Development, plaintext: &no, want: false},
+		{environment: Production, plaintext: &yes, want: true},
+		{environment: Development, plaintext: &yes, want: true},
+		{environment: Production, plaintext: &no, want: false},
+	}
+	for _, test := range tests {
+		cfg := Config{Environment: test.environment, PlaintextPasswords: test.plaintext}
+		if got := cfg.AcceptsPlaintextPasswords(); got != test.want {
+			t.Errorf("%s with plaintext_passwords %v = %v, want %v", test.environment, describe(test.plaintext), got, test.want)
+		}
+	}
+
+	// a file that doesn't mention it leaves it unset, rather than false
+	cfg := production()
+	if err := cfg.loadFile(writeFile(t, "config.json", `{"environment": "development"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.PlaintextPasswords != nil || !cfg.AcceptsPlaintextPasswords() {
+		t.Errorf("plaintext_passwords = %s after a file without it, want unset", describe(cfg.PlaintextPasswords))
+	}
+	if err := cfg.loadEnv(env(map[string]string{"PLAINTEXT_PASSWORDS": "false"})); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.AcceptsPlaintextPasswords() {
+		t.Error("PLAINTEXT_PASSWORDS=false is ignored in development")
+	}
+}
+
+func describe(plaintext *bool) string {
+	if plaintext == nil {
+		return "unset"
+	}
+	if *plaintext {
+		return "true"
+	}
+	return "false"
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	MemoryDecks = "memory"
+	RedisDecks  = "redis"
+)
+
+/*
+Deck - where each user's precomputed profile deck is cached.
+memory keeps up to capacity decks in this process, dropping the least recently used, redis shares them between instances.
+Decks are rebuilt once they're older than ttl, so changes to other users' profiles show up within it
+*/
+type Deck struct {
+	Store    string   `json:"store" yaml:"store"`
+	Capacity int      `json:"capacity" yaml:"capacity"`
+	TTL      Duration `json:"ttl" yaml:"ttl"`
+	RedisURL string   `json:"redis_url" yaml:"redis_url"`
+}
+
+func (c *Config) loadDeckEnv(lookup func(string) (string, bool)) error {
+	if value, ok := lookup("DECK_STORE"); ok {
+		c.Deck.Store = value
+	}
+	if value, ok := lookup("DECK_CAPACITY"); ok {
+		capacity, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("DECK_CAPACITY: %w", err)
+		}
+		c.Deck.Capacity = capacity
+	}
+	if value, ok := lookup("DECK_TTL"); ok {
+		ttl, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("DECK_TTL: %w", err)
+		}
+		c.Deck.TTL = Duration(ttl)
+	}
+	if value, ok := lookup("REDIS_URL"); ok {
+		c.Deck.RedisURL = value
+	}
+
+	return nil
+}
+
+func (c *Config) validateDeck() []string {
+	var problems []string
+
+	switch c.Deck.Store {
+	case MemoryDecks:
+		if c.Deck.Capacity < 1 {
+			problems = append(problems, "deck.capacity must be at least 1 with memory decks")
+		}
+	case RedisDecks:
+		redisURL, err := url.Parse(c.Deck.RedisURL)
+		if err != nil || (redisURL.Scheme != "redis" && redisURL.Scheme != "rediss") || redisURL.Host == "" {
+			problems = append(problems, "deck.redis_url must be a redis:// URL with redis decks")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("deck.store must be %s or %s", MemoryDecks, RedisDecks))
+	}
+	if c.Deck.TTL <= 0 {
+		problems = append(problems, "deck.ttl must be positive")
+	}
+
+	return problems
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"encoding/json"
+	"gopkg.in/yaml.v3"
+	"time"
+)
+
+/*
+Duration - time.Duration that reads from config files as a string like "60m" or "1h30m"
+*/
+type Duration time.Duration
+
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var value string
+	err := json.Unmarshal(b, &value)
+	if err != nil {
+		return err
+	}
+
+	return d.parse(value)
+}
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	var value string
+	err := node.Decode(&value)
+	if err != nil {
+		return err
+	}
+
+	return d.parse(value)
+}
+
+func (d *Duration) parse(value string) error {
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
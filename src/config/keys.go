@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+/*
+SigningKey - a private key used to sign access tokens, identified in each token by its kid
+Keys are rotated by adding the next key with a future activate_at, tokens are signed with the most recently
+activated key while every key that hasn't reached its retire_at is still accepted and published in the JWKS.
+Give the old key a retire_at at least access_token_ttl after the new key activates so tokens it signed can expire naturally
+*/
+type SigningKey struct {
+	ID             string     `json:"id" yaml:"id"`
+	PrivateKeyFile string     `json:"private_key_file" yaml:"private_key_file"`
+	ActivateAt     time.Time  `json:"activate_at" yaml:"activate_at"`
+	RetireAt       *time.Time `json:"retire_at,omitempty" yaml:"retire_at"`
+}
+
+/*
+Active - the key can be used to sign tokens at the given time
+*/
+func (k SigningKey) Active(now time.Time) bool {
+	return !now.Before(k.ActivateAt) && !k.Retired(now)
+}
+
+/*
+Retired - tokens signed by the key are no longer accepted
+*/
+func (k SigningKey) Retired(now time.Time) bool {
+	return k.RetireAt != nil && !now.Before(*k.RetireAt)
+}
+
+/*
+validateKeys - outside of production no keys is fine, a temporary key is generated on start up
+*/
+func (c *Config) validateKeys(now time.Time) []string {
+	var problems []string
+
+	ids := map[string]bool{}
+	active := false
+	for i, key := range c.JWTKeys {
+		if key.ID == "" {
+			problems = append(problems, fmt.Sprintf("jwt_keys[%d].id is required", i))
+		}
+		if ids[key.ID] {
+			problems = append(problems, fmt.Sprintf("jwt_keys[%d].id %q is used more than once", i, key.ID))
+		}
+		ids[key.ID] = true
+
+		if key.PrivateKeyFile == "" {
+			problems = append(problems, fmt.Sprintf("jwt_keys[%d].private_key_file is required", i))
+		}
+		if key.RetireAt != nil && !key.RetireAt.After(key.ActivateAt) {
+			problems = append(problems, fmt.Sprintf("jwt_keys[%d].retire_at must be after activate_at", i))
+		}
+		if key.Active(now) {
+			active = true
+		}
+	}
+
+	if len(c.JWTKeys) > 0 && !active {
+		problems = append(problems, "jwt_keys must include a key that is active now")
+	}
+	if c.IsProduction() && len(c.JWTKeys) == 0 {
+		problems = append(problems, "jwt_keys are required in production")
+	}
+
+	return problems
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+const (
+	LocalStorage = "local"
+	S3Storage    = "s3"
+)
+
+/*
+Media - where uploaded photos are stored and the URL they're served from
+public_url is the base of every photo URL returned by the API, with local storage it defaults to /media on this server
+and with S3 to the bucket on the endpoint
+*/
+type Media struct {
+	Storage   string `json:"storage" yaml:"storage"`
+	LocalDir  string `json:"local_dir" yaml:"local_dir"`
+	PublicURL string `json:"public_url" yaml:"public_url"`
+	S3        S3     `json:"s3" yaml:"s3"`
+}
+
+/*
+S3 - an S3 compatible bucket, path_style addresses it as endpoint/bucket rather than bucket.endpoint
+which is what most local stand-ins such as MinIO expect
+*/
+type S3 struct {
+	Endpoint        string `json:"endpoint" yaml:"endpoint"`
+	Region          string `json:"region" yaml:"region"`
+	Bucket          string `json:"bucket" yaml:"bucket"`
+	AccessKeyID     string `json:"access_key_id" yaml:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key" yaml:"secret_access_key"`
+	PathStyle       bool   `json:"path_style" yaml:"path_style"`
+}
+
+func (c *Config) loadMediaEnv(lookup func(string) (string, bool)) error {
+	if value, ok := lookup("MEDIA_STORAGE"); ok {
+		c.Media.Storage = value
+	}
+	if value, ok := lookup("MEDIA_LOCAL_DIR"); ok {
+		c.Media.LocalDir = value
+	}
+	if value, ok := lookup("MEDIA_PUBLIC_URL"); ok {
+		c.Media.PublicURL = value
+	}
+	if value, ok := lookup("S3_ENDPOINT"); ok {
+		c.Media.S3.Endpoint = value
+	}
+	if value, ok := lookup("S3_REGION"); ok {
+		c.Media.S3.Region = value
+	}
+	if value, ok := lookup("S3_BUCKET"); ok {
+		c.Media.S3.Bucket = value
+	}
+	if value, ok := lookup("S3_ACCESS_KEY_ID"); ok {
+		c.Media.S3.AccessKeyID = value
+	}
+	if value, ok := lookup("S3_SECRET_ACCESS_KEY"); ok {
+		c.Media.S3.SecretAccessKey = value
+	}
+	if value, ok := lookup("S3_PATH_STYLE"); ok {
+		pathStyle, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("S3_PATH_STYLE: %w", err)
+		}
+		c.Media.S3.PathStyle = pathStyle
+	}
+
+	return nil
+}
+
+func (c *Config) validateMedia() []string {
+	var problems []string
+
+	switch c.Media.Storage {
+	case LocalStorage:
+		if c.Media.LocalDir == "" {
+			problems = append(problems, "media.local_dir is required with local storage")
+		}
+	case S3Storage:
+		s3 := c.Media.S3
+		if _, err := url.ParseRequestURI(s3.Endpoint); err != nil || s3.Endpoint == "" {
+			problems = append(problems, "media.s3.endpoint must be a URL")
+		}
+		required := []struct{ name, value string }{
+			{"region", s3.Region},
+			{"bucket", s3.Bucket},
+			{"access_key_id", s3.AccessKeyID},
+			{"secret_access_key", s3.SecretAccessKey},
+		}
+		for _, field := range required {
+			if field.value == "" {
+				problems = append(problems, fmt.Sprintf("media.s3.%s is required with s3 storage", field.name))
+			}
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("media.storage must be %s or %s", LocalStorage, S3Storage))
+	}
+
+	return problems
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+/*
+RankingFeatures - the features the ranked profile deck scores candidates on.
+config can't import the ranking package, which imports it, so TestRankingFeatures there checks the two lists match
+*/
+var RankingFeatures = []string{"distance", "age_fit", "shared_interests", "rating", "activity", "new_user"}
+
+/*
+Ranking - experiments with the weights the ranked profile deck gives each feature.
+Each experiment gets its traffic percentage of users, picked by user id so a user stays in the same one.
+Users that aren't in an experiment, and every user when there are none, get the default weights
+*/
+type Ranking struct {
+	Experiments []RankingExperiment `json:"experiments" yaml:"experiments"`
+}
+
+/*
+RankingExperiment - a set of feature weights, features left out of weights aren't scored
+*/
+type RankingExperiment struct {
+	Name    string             `json:"name" yaml:"name"`
+	Traffic int                `json:"traffic" yaml:"traffic"`
+	Weights map[string]float64 `json:"weights" yaml:"weights"`
+}
+
+func (c *Config) loadRankingEnv(lookup func(string) (string, bool)) error {
+	if value, ok := lookup("RANKING_EXPERIMENTS"); ok {
+		var experiments []RankingExperiment
+		err := json.Unmarshal([]byte(value), &experiments)
+		if err != nil {
+			return fmt.Errorf("RANKING_EXPERIMENTS: %w", err)
+		}
+		c.Ranking.Experiments = experiments
+	}
+
+	return nil
+}
+
+func (c *Config) validateRanking() []string {
+	var problems []string
+
+	names := make(map[string]bool)
+	traffic := 0
+	for i, experiment := range c.Ranking.Experiments {
+		if experiment.Name == "" {
+			problems = append(problems, fmt.Sprintf("ranking.experiments[%d].name is required", i))
+		} else if names[experiment.Name] || experiment.Name == "default" {
+			problems = append(problems, fmt.Sprintf("ranking.experiments[%d].name %q is already used", i, experiment.Name))
+		}
+		names[experiment.Name] = true
+
+		if experiment.Traffic < 0 || experiment.Traffic > 100 {
+			problems = append(problems, fmt.Sprintf("ranking.experiments[%d].traffic must be between 0 and 100", i))
+		}
+		traffic += experiment.Traffic
+
+		features := make([]string, 0, len(experiment.Weights))
+		for feature := range experiment.Weights {
+			features = append(features, feature)
+		}
+		// sorted so the problems are reported in the same order every time
+		sort.Strings(features)
+		for _, feature := range features {
+			weight := experiment.Weights[feature]
+			if !knownFeature(feature) {
+				problems = append(problems, fmt.Sprintf("ranking.experiments[%d].weights has an unknown feature %q", i, feature))
+			}
+			if math.IsNaN(weight) || math.IsInf(weight, 0) {
+				problems = append(problems, fmt.Sprintf("ranking.experiments[%d].weights.%s must be a number", i, feature))
+			}
+		}
+	}
+	if traffic > 100 {
+		problems = append(problems, "ranking.experiments traffic adds up to more than 100")
+	}
+
+	return problems
+}
+
+func knownFeature(feature string) bool {
+	for _, known := range RankingFeatures {
+		if feature == known {
+			return true
+		}
+	}
+	return false
+}
@@ -1,9 +1,12 @@
 package controllers
 
 import (
-	"database/sql"
+	"dating-app/src/auth"
+	"dating-app/src/config"
 	"dating-app/src/interactors"
 	"dating-app/src/models"
+	"dating-app/src/repositories"
+	"errors"
 	"github.com/goombaio/namegenerator"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/gommon/log"
@@ -14,22 +17,106 @@ import (
 )
 
 type Auth struct {
-	db *sql.DB
 	authInteractor *interactors.Auth
+	sessionInteractor *interactors.Session
 }
 
-func NewAuth(db *sql.DB) *Auth {
+func NewAuth(cfg *config.Config, tokens *auth.Tokens, users repositories.UserRepository, sessions repositories.SessionRepository) *Auth {
 	return &Auth{
-		db: db,
-		authInteractor:interactors.NewAuth(db),
+		authInteractor:interactors.NewAuth(cfg, users),
+		sessionInteractor: interactors.NewSession(cfg, tokens, sessions),
 	}
 }
 
+type registerRequest struct {
+	Email string `json:"email"`
+	Password string `json:"password"`
+	Name string `json:"name"`
+	Gender models.GenderType `json:"gender"`
+	GenderDescription string `json:"gender_description"`
+	DateOfBirth string `json:"date_of_birth"`
+	Latitude *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+	DistanceUnit models.DistanceUnit `json:"distance_unit"`
+	Bio string `json:"bio"`
+	JobTitle string `json:"job_title"`
+	School string `json:"school"`
+	HeightCM *int `json:"height_cm"`
+}
+
+/*
+Register - self-service sign up with the user's own details
+date_of_birth is expected as YYYY-MM-DD, gender (a code or label from GET /genders) is optional and defaults to not specified,
+gender_description lets the user describe their gender in their own words,
+distance_unit (km or mi) is optional and defaults to km, as are bio, job_title, school and height_cm
+returns 400 with the invalid fields, or 409 if the email is already registered
+ */
+func (a *Auth) Register (c echo.Context) error {
+	request := &registerRequest{
+		Gender: models.NotSpecified,
+	}
+	if err := c.Bind(request); err != nil {
+		return c.JSON(http.StatusBadRequest, nil)
+	}
+
+	formatErrors := &interactors.ValidationError{}
+	dateOfBirth, err := time.Parse("2006-01-02", request.DateOfBirth)
+	if err != nil {
+		formatErrors.Add("date_of_birth", "must be a date in the format YYYY-MM-DD")
+	}
+	if request.Latitude == nil {
+		formatErrors.Add("latitude", "is required")
+	}
+	if request.Longitude == nil {
+		formatErrors.Add("longitude", "is required")
+	}
+	if formatErrors.OrNil() != nil {
+		return c.JSON(http.StatusBadRequest, formatErrors)
+	}
+
+	newUser := models.User{
+		Email: request.Email,
+		Password: request.Password,
+		DistanceUnit: request.DistanceUnit,
+		Profile: models.Profile{
+			Name: request.Name,
+			Gender: request.Gender,
+			GenderDescription: request.GenderDescription,
+			DateOfBirth: dateOfBirth,
+			Latitude: *request.Latitude,
+			Longitude: *request.Longitude,
+			Bio: request.Bio,
+			JobTitle: request.JobTitle,
+			School: request.School,
+			HeightCM: request.HeightCM,
+		},
+	}
+
+	newUser, err = a.authInteractor.Register(newUser)
+	var validationErr *interactors.ValidationError
+	if errors.As(err, &validationErr) {
+		return c.JSON(http.StatusBadRequest, validationErr)
+	}
+	if errors.Is(err, repositories.ErrDuplicateEmail) {
+		return c.JSON(http.StatusConflict, "email already registered")
+	}
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	// the client already knows the password, don't echo it back
+	newUser.Password = ""
+
+	return c.JSON(http.StatusCreated, newUser)
+}
+
 /*
-Create - generates a random user with a birthday, name, login creds, gender, and location
+CreateRandom - generates a random user with a birthday, name, login creds, gender, and location
 when the user is returned, age is calculated from the users date of birth
+only for seeding development environments, it isn't routed in production
  */
-func (a *Auth) Create (c echo.Context)error{
+func (a *Auth) CreateRandom (c echo.Context)error{
 	newUser := models.User{}
 
 	seed := time.Now().UTC().UnixNano()
@@ -46,7 +133,7 @@ func (a *Auth) Create (c echo.Context)error{
 
 	newUser.Password = securePassword
 
-	newUser.Gender = models.GenderType(rand.Intn(3))
+	newUser.Gender = models.Genders[rand.Intn(len(models.Genders))].Code
 
 	currentYear := time.Now().Year() - 18
 	minYear := currentYear - 47
@@ -60,6 +147,9 @@ func (a *Auth) Create (c echo.Context)error{
 	newUser.Longitude = float64(rand.Intn(360)) - 180 // to account for negative values
 
 	newUser, err = a.authInteractor.Create(newUser)
+	if errors.Is(err, repositories.ErrDuplicateEmail) {
+		return c.JSON(http.StatusConflict, "generated name already taken, try again")
+	}
 	if err != nil {
 		log.Error(err)
 		return err
@@ -71,10 +161,12 @@ func (a *Auth) Create (c echo.Context)error{
 type loginRequest struct {
 	Email string `json:"email"`
 	Password string `json:"password"`
+	DeviceName string `json:"device_name"`
 }
 
 /*
-Login - takes the users credentials and if the email/password exists in the DB, generates a token
+Login - takes the users credentials and if the email/password exists in the DB, starts a session
+returns a short lived access token and a refresh token to renew it with
  */
 func (a *Auth) Login (c echo.Context) error {
 	request := &loginRequest{}
@@ -82,20 +174,85 @@ func (a *Auth) Login (c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, nil)
 	}
 
-	token, err := a.authInteractor.Login(request.Email, request.Password)
+	user, err := a.authInteractor.Authenticate(request.Email, request.Password)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, interactors.ErrInvalidCredentials) {
 			return c.JSON(http.StatusUnauthorized, "invalid login credentials")
 		} else {
 			return err
 		}
 	}
 
-	if token != "" {
-		return c.JSON(http.StatusOK, echo.Map{
-			"token": token,
-		})
+	tokens, err := a.sessionInteractor.Start(user.ID, interactors.DeviceInfo{
+		Name: request.DeviceName,
+		UserAgent: c.Request().UserAgent(),
+		IPAddress: c.RealIP(),
+	})
+	if err != nil {
+		log.Error(err)
+		return err
 	}
 
-	return c.JSON(http.StatusInternalServerError, nil)
-}
\ No newline at end of file
+	return c.JSON(http.StatusOK, tokens)
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+/*
+Refresh - exchanges a refresh token for a new token pair, the old refresh token can't be used again
+ */
+func (a *Auth) Refresh (c echo.Context) error {
+	request := &refreshRequest{}
+	if err := c.Bind(request); err != nil || request.RefreshToken == "" {
+		return c.JSON(http.StatusBadRequest, nil)
+	}
+
+	tokens, err := a.sessionInteractor.Refresh(request.RefreshToken)
+	if errors.Is(err, interactors.ErrInvalidRefreshToken) {
+		return c.JSON(http.StatusUnauthorized, "invalid refresh token")
+	}
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+/*
+Logout - revokes the session the request's token belongs to
+ */
+func (a *Auth) Logout (c echo.Context) error {
+	principal, ok := auth.PrincipalFrom(c)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorised access")
+	}
+
+	err := a.sessionInteractor.Revoke(principal.SessionID)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+/*
+LogoutAll - revokes every session the user has, on every device
+ */
+func (a *Auth) LogoutAll (c echo.Context) error {
+	principal, ok := auth.PrincipalFrom(c)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorised access")
+	}
+
+	err := a.sessionInteractor.RevokeAll(principal.UserID)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
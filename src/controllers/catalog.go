@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"dating-app/src/deck"
+	"dating-app/src/interactors"
+	"dating-app/src/models"
+	"dating-app/src/repositories"
+	"dating-app/src/storage"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"net/http"
+)
+
+/*
+Catalog - the genders, interests and prompts users can pick from when filling in their profile
+*/
+type Catalog struct {
+	profileInteractor *interactors.Profile
+}
+
+func NewCatalog(users repositories.UserRepository, profiles repositories.ProfileRepository, photos repositories.PhotoRepository, blobs storage.Blobs,
+	decks deck.Store) *Catalog {
+	return &Catalog{
+		profileInteractor: interactors.NewProfile(users, profiles, photos, blobs, decks),
+	}
+}
+
+/*
+Interests - every interest that can currently be picked
+ */
+func (ca *Catalog) Interests (c echo.Context) error {
+	interests, err := ca.profileInteractor.Interests()
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, interestsResponse{Interests: interests})
+}
+
+type promptCatalogResponse struct {
+	Prompts []models.Prompt `json:"prompts"`
+}
+
+/*
+Prompts - every prompt that can currently be answered
+ */
+func (ca *Catalog) Prompts (c echo.Context) error {
+	prompts, err := ca.profileInteractor.Prompts()
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, promptCatalogResponse{Prompts: prompts})
+}
+
+type gendersResponse struct {
+	Genders []models.GenderOption `json:"genders"`
+	ShowMe []models.ShowMe `json:"show_me"`
+}
+
+/*
+Genders - every gender a user can identify as, along with the show me group it's in, and the show me groups
+ */
+func (ca *Catalog) Genders (c echo.Context) error {
+	return c.JSON(http.StatusOK, gendersResponse{Genders: models.Genders, ShowMe: models.ShowMeOptions})
+}
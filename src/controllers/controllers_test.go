@@ -0,0 +1,500 @@
+package controllers
+
+import (
+	"bytes"
+	"dating-app/src/auth"
+	"dating-app/src/config"
+	"dating-app/src/deck"
+	"dating-app/src/interactors"
+	"dating-app/src/models"
+	"dating-app/src/repositories"
+	"dating-app/src/storage"
+	"encoding/json"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testPassword = "Correct horse 42"
+
+/*
+newTestServer - the auth, session, profiles, swipe and location routes as main wires them, on memory repositories
+*/
+func newTestServer(t *testing.T) *echo.Echo {
+	t.Helper()
+	cfg := config.Default()
+	cfg.PrivacySecret = "test secret"
+
+	keys, err := auth.LoadKeySet(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := auth.NewTokens(&cfg, keys)
+	blobs, err := storage.NewLocal(t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := repositories.NewMemoryUserRepository()
+	matches := repositories.NewMemoryMatchRepository(users)
+	sessions := repositories.NewMemorySessionRepository()
+	profiles := repositories.NewMemoryProfileRepository(nil, nil)
+	photos := repositories.NewMemoryPhotoRepository()
+	decks := deck.NewLRU(100, time.Hour)
+
+	requireAuth := auth.Middleware(tokens, interactors.NewSession(&cfg, tokens, sessions))
+
+	e := echo.New()
+	authController := NewAuth(&cfg, tokens, users, sessions)
+	e.POST("/user/register", authController.Register)
+	e.POST("/login", authController.Login)
+	e.POST("/token/refresh", authController.Refresh)
+	e.POST("/logout", authController.Logout, requireAuth)
+	e.POST("/logout/all", authController.LogoutAll, requireAuth)
+
+	match := NewMatch(&cfg, users, matches, profiles, photos, blobs, decks)
+	e.GET("/profiles", match.Profiles, requireAuth)
+	e.POST("/swipe", match.Swipe, requireAuth)
+
+	me := NewMe(users, profiles, photos, blobs, decks)
+	e.PUT("/me/location", me.UpdateLocation, requireAuth)
+
+	return e
+}
+
+/*
+send - makes the request, body is sent as JSON unless it's nil
+*/
+func send(e *echo.Echo, method, path string, body any, token string) *httptest.ResponseRecorder {
+	var reader bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&reader).Encode(body)
+	}
+	request := httptest.NewRequest(method, path, &reader)
+	request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if token != "" {
+		request.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	e.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func decode(t *testing.T, recorder *httptest.ResponseRecorder, into any) {
+	t.Helper()
+	if err := json.Unmarshal(recorder.Body.Bytes(), into); err != nil {
+		t.Fatalf("response %q isn't JSON: %v", recorder.Body.String(), err)
+	}
+}
+
+func registration(email string) map[string]any {
+	return map[string]any{
+		"email":         email,
+		"password":      testPassword,
+		"name":          "Alex",
+		"gender":        "female",
+		"date_of_birth": "1995-06-15",
+		"latitude":      51.5,
+		"longitude":     -0.12,
+	}
+}
+
+/*
+registerAndLogin - a new user and an access token for them
+*/
+func registerAndLogin(t *testing.T, e *echo.Echo, email string) (models.User, string) {
+	t.Helper()
+	recorder := send(e, http.MethodPost, "/user/register", registration(email), "")
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("register %s = %d %s", email, recorder.Code, recorder.Body)
+	}
+	var user models.User
+	decode(t, recorder, &user)
+
+	recorder = send(e, http.MethodPost, "/login", map[string]string{"email": email, "password": testPassword}, "")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("login %s = %d %s", email, recorder.Code, recorder.Body)
+	}
+	var tokens models.TokenPair
+	decode(t, recorder, &tokens)
+	return user, tokens.AccessToken
+}
+
+func TestRegister(t *testing.T) {
+	e := newTestServer(t)
+
+	recorder := send(e, http.MethodPost, "/user/register", registration("alex@example.com"), "")
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("Register = %d %s, want 201", recorder.Code, recorder.Body)
+	}
+	var created map[string]any
+	decode(t, recorder, &created)
+	if created["id"] != 1.0 || created["email"] != "alex@example.com" || created["gender"] != "Female" {
+		t.Errorf("Register returned %v", created)
+	}
+	if _, ok := created["password"]; ok {
+		t.Error("Register returned the password")
+	}
+
+	recorder = send(e, http.MethodPost, "/user/register", registration("ALEX@example.com"), "")
+	if recorder.Code != http.StatusConflict {
+		t.Errorf("registering the email again = %d, want 409", recorder.Code)
+	}
+
+	invalid := registration("sam@example.com")
+	invalid["password"] = "short"
+	delete(invalid, "latitude")
+	recorder = send(e, http.MethodPost, "/user/register", invalid, "")
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("invalid registration = %d, want 400", recorder.Code)
+	}
+	var errs interactors.ValidationError
+	decode(t, recorder, &errs)
+	if _, ok := errs.Fields["latitude"]; !ok {
+		t.Errorf("invalid registration errors = %v, want latitude", errs.Fields)
+	}
+
+	invalid = registration("sam@example.com")
+	invalid["password"] = "short"
+	recorder = send(e, http.MethodPost, "/user/register", invalid, "")
+	decode(t, recorder, &errs)
+	if _, ok := errs.Fields["password"]; recorder.Code != http.StatusBadRequest || !ok {
+		t.Errorf("short password = %d %v, want 400 with password", recorder.Code, errs.Fields)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	e := newTestServer(t)
+	registerAndLogin(t, e, "alex@example.com")
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		want     int
+	}{
+		{name: "correct password", email: "alex@example.com", password: testPassword, want: http.StatusOK},
+		{name: "email in another case", email: "Alex@Example.com", password: testPassword, want: http.StatusOK},
+		{name: "wrong password", email: "alex@example.com", password: "wrong password", want: http.StatusUnauthorized},
+		{name: "unknown email", email: "sam@example.com", password: testPassword, want: http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := send(e, http.MethodPost, "/login", map[string]string{"email": test.email, "password": test.password}, "")
+			if recorder.Code != test.want {
+				t.Fatalf("Login = %d %s, want %d", recorder.Code, recorder.Body, test.want)
+			}
+			if test.want == http.StatusOK {
+				var tokens models.TokenPair
+				decode(t, recorder, &tokens)
+				if tokens.AccessToken == "" || tokens.RefreshToken == "" {
+					t.Errorf("Login returned %+v", tokens)
+				}
+			}
+		})
+	}
+}
+
+func login(t *testing.T, e *echo.Echo, email string) models.TokenPair {
+	t.Helper()
+	recorder := send(e, http.MethodPost, "/login", map[string]string{"email": email, "password": testPassword}, "")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("login %s = %d %s", email, recorder.Code, recorder.Body)
+	}
+	var tokens models.TokenPair
+	decode(t, recorder, &tokens)
+	return tokens
+}
+
+/*
+rejectedWith - the error code Middleware rejected the access token with, empty if it was accepted
+*/
+func rejectedWith(t *testing.T, e *echo.Echo, token string) string {
+	t.Helper()
+	recorder := send(e, http.MethodGet, "/profiles", nil, token)
+	if recorder.Code == http.StatusOK {
+		return ""
+	}
+	var response auth.ErrorResponse
+	decode(t, recorder, &response)
+	return response.Error
+}
+
+func refresh(e *echo.Echo, refreshToken string) *httptest.ResponseRecorder {
+	return send(e, http.MethodPost, "/token/refresh", map[string]string{"refresh_token": refreshToken}, "")
+}
+
+func TestRefreshReplay(t *testing.T) {
+	e := newTestServer(t)
+	registerAndLogin(t, e, "alex@example.com")
+	phone := login(t, e, "alex@example.com")
+	laptop := login(t, e, "alex@example.com")
+
+	recorder := refresh(e, phone.RefreshToken)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("refresh = %d %s", recorder.Code, recorder.Body)
+	}
+	var rotated models.TokenPair
+	decode(t, recorder, &rotated)
+	if rotated.RefreshToken == phone.RefreshToken {
+		t.Fatal("refresh didn't rotate the refresh token")
+	}
+	if code := rejectedWith(t, e, rotated.AccessToken); code != "" {
+		t.Fatalf("refreshed access token rejected with %s", code)
+	}
+
+	// someone replays the refresh token that was rotated, the whole session is revoked
+	if recorder = refresh(e, phone.RefreshToken); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("replayed refresh = %d, want 401", recorder.Code)
+	}
+	for name, token := range map[string]string{"original": phone.AccessToken, "refreshed": rotated.AccessToken} {
+		if code := rejectedWith(t, e, token); code != "session_revoked" {
+			t.Errorf("%s access token after the replay = %q, want session_revoked", name, code)
+		}
+	}
+	if recorder = refresh(e, rotated.RefreshToken); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("current refresh token after the replay = %d, want 401", recorder.Code)
+	}
+
+	if code := rejectedWith(t, e, laptop.AccessToken); code != "" {
+		t.Errorf("another session was rejected with %s", code)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	e := newTestServer(t)
+	registerAndLogin(t, e, "alex@example.com")
+	registerAndLogin(t, e, "sam@example.com")
+	phone := login(t, e, "alex@example.com")
+	laptop := login(t, e, "alex@example.com")
+	tablet := login(t, e, "alex@example.com")
+	sam := login(t, e, "sam@example.com")
+
+	if recorder := send(e, http.MethodPost, "/logout", nil, phone.AccessToken); recorder.Code != http.StatusNoContent {
+		t.Fatalf("logout = %d %s", recorder.Code, recorder.Body)
+	}
+	if code := rejectedWith(t, e, phone.AccessToken); code != "session_revoked" {
+		t.Errorf("access token after logout = %q, want session_revoked", code)
+	}
+	if recorder := refresh(e, phone.RefreshToken); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("refresh after logout = %d, want 401", recorder.Code)
+	}
+	if code := rejectedWith(t, e, laptop.AccessToken); code != "" {
+		t.Errorf("logging out rejected another session with %s", code)
+	}
+
+	if recorder := send(e, http.MethodPost, "/logout/all", nil, laptop.AccessToken); recorder.Code != http.StatusNoContent {
+		t.Fatalf("logout everywhere = %d %s", recorder.Code, recorder.Body)
+	}
+	for name, tokens := range map[string]models.TokenPair{"laptop": laptop, "tablet": tablet} {
+		if code := rejectedWith(t, e, tokens.AccessToken); code != "session_revoked" {
+			t.Errorf("%s access token after logging out everywhere = %q, want session_revoked", name, code)
+		}
+		if recorder := refresh(e, tokens.RefreshToken); recorder.Code != http.StatusUnauthorized {
+			t.Errorf("%s refresh after logging out everywhere = %d, want 401", name, recorder.Code)
+		}
+	}
+	if code := rejectedWith(t, e, sam.AccessToken); code != "" {
+		t.Errorf("logging out everywhere rejected another user with %s", code)
+	}
+
+	// logging in again starts a new session
+	if code := rejectedWith(t, e, login(t, e, "alex@example.com").AccessToken); code != "" {
+		t.Errorf("new login rejected with %s", code)
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	e := newTestServer(t)
+	alex, token := registerAndLogin(t, e, "alex@example.com")
+	sam, _ := registerAndLogin(t, e, "sam@example.com")
+
+	if recorder := send(e, http.MethodGet, "/profiles", nil, ""); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Profiles without a token = %d, want 401", recorder.Code)
+	}
+	if recorder := send(e, http.MethodGet, "/profiles?limit=1000", nil, token); recorder.Code != http.StatusBadRequest {
+		t.Errorf("Profiles with limit=1000 = %d, want 400", recorder.Code)
+	}
+	if recorder := send(e, http.MethodGet, "/profiles?age_min=30&age_max=20", nil, token); recorder.Code != http.StatusBadRequest {
+		t.Errorf("Profiles with age_min over age_max = %d, want 400", recorder.Code)
+	}
+
+	recorder := send(e, http.MethodGet, "/profiles", nil, token)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Profiles = %d %s, want 200", recorder.Code, recorder.Body)
+	}
+	var page struct {
+		Profiles []map[string]any `json:"profiles"`
+	}
+	decode(t, recorder, &page)
+	if len(page.Profiles) != 1 || page.Profiles[0]["id"] != float64(sam.ID) {
+		t.Fatalf("Profiles for %d = %v, want only %d", alex.ID, page.Profiles, sam.ID)
+	}
+	distance, ok := page.Profiles[0]["distance"].(map[string]any)
+	if !ok || distance["label"] != "less than 1 km" {
+		t.Errorf("Profiles distance = %v, want the approximate distance", page.Profiles[0]["distance"])
+	}
+	for _, field := range []string{"latitude", "longitude", "email", "password"} {
+		if _, ok := page.Profiles[0][field]; ok {
+			t.Errorf("Profiles returned %s", field)
+		}
+	}
+}
+
+/*
+TestProfilesGenderFilter - Not Specified on its own is the legacy any gender filter, unspecified_only is only the users who didn't give one
+*/
+func TestProfilesGenderFilter(t *testing.T) {
+	e := newTestServer(t)
+	_, token := registerAndLogin(t, e, "alex@example.com")
+
+	byGender := map[string]int{}
+	for _, gender := range []string{"male", "non_binary", "not_specified"} {
+		body := registration(gender + "@example.com")
+		body["gender"] = gender
+		recorder := send(e, http.MethodPost, "/user/register", body, "")
+		if recorder.Code != http.StatusCreated {
+			t.Fatalf("register %s = %d %s", gender, recorder.Code, recorder.Body)
+		}
+		var user models.User
+		decode(t, recorder, &user)
+		byGender[gender] = user.ID
+	}
+	everyone := []int{byGender["male"], byGender["non_binary"], byGender["not_specified"]}
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{query: "", want: everyone},
+		{query: "?gender=Not%20Specified", want: everyone},
+		{query: "?gender=not_specified", want: everyone},
+		{query: "?gender=unspecified_only", want: []int{byGender["not_specified"]}},
+		{query: "?gender=Unspecified_Only", want: []int{byGender["not_specified"]}},
+		{query: "?gender=Male", want: []int{byGender["male"]}},
+		{query: "?gender=Male&gender=Not%20Specified", want: []int{byGender["male"], byGender["not_specified"]}},
+		{query: "?gender=Male&gender=unspecified_only", want: []int{byGender["male"], byGender["not_specified"]}},
+	}
+
+	for _, test := range tests {
+		recorder := send(e, http.MethodGet, "/profiles"+test.query, nil, token)
+		if recorder.Code != http.StatusOK {
+			t.Errorf("Profiles%s = %d %s, want 200", test.query, recorder.Code, recorder.Body)
+			continue
+		}
+		var page struct {
+			Profiles []models.Profile `json:"profiles"`
+		}
+		decode(t, recorder, &page)
+		var got []int
+		for _, profile := range page.Profiles {
+			got = append(got, profile.ID)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("Profiles%s = %v, want %v", test.query, got, test.want)
+		}
+	}
+
+	if recorder := send(e, http.MethodGet, "/profiles?gender=robot", nil, token); recorder.Code != http.StatusBadRequest {
+		t.Errorf("Profiles?gender=robot = %d, want 400", recorder.Code)
+	}
+}
+
+func TestSwipe(t *testing.T) {
+	e := newTestServer(t)
+	alex, alexToken := registerAndLogin(t, e, "alex@example.com")
+	sam, samToken := registerAndLogin(t, e, "sam@example.com")
+
+	swipe := func(token string, profileID int, preference string) *httptest.ResponseRecorder {
+		return send(e, http.MethodPost, "/swipe", map[string]any{"profile_id": profileID, "preference": preference}, token)
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		profileID  int
+		preference string
+		want       int
+		matched    bool
+	}{
+		{name: "without a token", profileID: sam.ID, preference: "YES", want: http.StatusUnauthorized},
+		{name: "not yes or no", token: alexToken, profileID: sam.ID, preference: "MAYBE", want: http.StatusBadRequest},
+		{name: "own profile", token: alexToken, profileID: alex.ID, preference: "YES", want: http.StatusBadRequest},
+		{name: "unknown profile", token: alexToken, profileID: 99, preference: "YES", want: http.StatusNotFound},
+		{name: "first yes", token: alexToken, profileID: sam.ID, preference: "YES", want: http.StatusOK},
+		{name: "same swipe again", token: alexToken, profileID: sam.ID, preference: "YES", want: http.StatusConflict},
+		{name: "yes back matches", token: samToken, profileID: alex.ID, preference: "YES", want: http.StatusOK, matched: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := swipe(test.token, test.profileID, test.preference)
+			if recorder.Code != test.want {
+				t.Fatalf("Swipe = %d %s, want %d", recorder.Code, recorder.Body, test.want)
+			}
+			if test.want != http.StatusOK {
+				return
+			}
+			var response swipeResponse
+			decode(t, recorder, &response)
+			if response.Matched != test.matched {
+				t.Errorf("Swipe matched = %v, want %v", response.Matched, test.matched)
+			}
+			if test.matched && (response.MatchID == nil || *response.MatchID != test.profileID) {
+				t.Errorf("Swipe match_id = %v, want %d", response.MatchID, test.profileID)
+			}
+		})
+	}
+
+	// matched users aren't shown to each other again
+	recorder := send(e, http.MethodGet, "/profiles", nil, alexToken)
+	if recorder.Code != http.StatusOK || bytes.Contains(recorder.Body.Bytes(), []byte(fmt.Sprintf(`"id":%d`, sam.ID))) {
+		t.Errorf("Profiles after matching = %d %s", recorder.Code, recorder.Body)
+	}
+}
+
+/*
+TestUpdateLocationTooSoon - moving again within interactors.MinLocationInterval is 429 with a Retry-After in whole seconds,
+reporting the same location again is still fine
+*/
+func TestUpdateLocationTooSoon(t *testing.T) {
+	e := newTestServer(t)
+	_, token := registerAndLogin(t, e, "mover@example.com")
+
+	move := func(latitude float64) *httptest.ResponseRecorder {
+		return send(e, http.MethodPut, "/me/location", map[string]float64{"latitude": latitude, "longitude": -0.12}, token)
+	}
+
+	recorder := move(51.6)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("first move = %d %s", recorder.Code, recorder.Body)
+	}
+	var moved struct {
+		LastLocatedAt time.Time `json:"last_located_at"`
+	}
+	decode(t, recorder, &moved)
+
+	recorder = move(51.7)
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("moving straight away = %d %s, want 429", recorder.Code, recorder.Body)
+	}
+	retryAfter, err := strconv.Atoi(recorder.Header().Get("Retry-After"))
+	if err != nil || retryAfter < 1 || retryAfter > int(interactors.MinLocationInterval.Seconds()) {
+		t.Errorf("Retry-After = %q, want whole seconds up to %v", recorder.Header().Get("Retry-After"), interactors.MinLocationInterval)
+	}
+
+	recorder = move(51.6)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("reporting the same location again = %d %s", recorder.Code, recorder.Body)
+	}
+	var again struct {
+		LastLocatedAt time.Time `json:"last_located_at"`
+	}
+	decode(t, recorder, &again)
+	if !again.LastLocatedAt.Equal(moved.LastLocatedAt) {
+		t.Errorf("last_located_at = %v, want when it was first reported %v", again.LastLocatedAt, moved.LastLocatedAt)
+	}
+}
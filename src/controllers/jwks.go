@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"dating-app/src/auth"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+type JWKS struct {
+	keys *auth.KeySet
+}
+
+func NewJWKS(keys *auth.KeySet) *JWKS {
+	return &JWKS{
+		keys: keys,
+	}
+}
+
+/*
+Get - publishes the public keys access tokens are signed with so other services can verify them without a shared secret
+verifiers can cache it briefly, upcoming keys are published before they're used to sign anything
+*/
+func (j *JWKS) Get(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(http.StatusOK, j.keys.JWKS())
+}
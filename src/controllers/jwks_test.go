@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"dating-app/src/auth"
+	"dating-app/src/config"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeKey(t *testing.T, dir, id string, private any) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, id+".pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+/*
+TestJWKS - the key set's clock is the real one, so the schedule is days either side of now
+*/
+func TestJWKS(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	retireAt := now.Add(-24 * time.Hour)
+
+	rsaPrivate, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, retiredPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Default()
+	cfg.JWTKeys = []config.SigningKey{
+		{ID: "retired", PrivateKeyFile: writeKey(t, dir, "retired", retiredPrivate), ActivateAt: now.Add(-30 * 24 * time.Hour), RetireAt: &retireAt},
+		{ID: "current", PrivateKeyFile: writeKey(t, dir, "current", rsaPrivate), ActivateAt: now.Add(-48 * time.Hour)},
+		{ID: "upcoming", PrivateKeyFile: writeKey(t, dir, "upcoming", edPrivate), ActivateAt: now.Add(48 * time.Hour)},
+	}
+	keys, err := auth.LoadKeySet(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	e.GET("/.well-known/jwks.json", NewJWKS(keys).Get)
+
+	recorder := send(e, http.MethodGet, "/.well-known/jwks.json", nil, "")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d", recorder.Code)
+	}
+	if cache := recorder.Header().Get(echo.HeaderCacheControl); cache != "public, max-age=300" {
+		t.Errorf("Cache-Control = %q", cache)
+	}
+
+	var set auth.JWKSet
+	decode(t, recorder, &set)
+	if len(set.Keys) != 2 {
+		t.Fatalf("published %+v, want upcoming and current", set.Keys)
+	}
+
+	upcoming, current := set.Keys[0], set.Keys[1]
+	if upcoming.KeyID != "upcoming" || upcoming.KeyType != "OKP" || upcoming.Curve != "Ed25519" || upcoming.Algorithm != "EdDSA" || upcoming.X == "" {
+		t.Errorf("upcoming = %+v", upcoming)
+	}
+	if current.KeyID != "current" || current.KeyType != "RSA" || current.Algorithm != "RS256" || current.N == "" || current.E != "AQAB" {
+		t.Errorf("current = %+v", current)
+	}
+
+	// the upcoming key is published but the current one still signs
+	tokens := auth.NewTokens(&cfg, keys)
+	token, err := tokens.Issue(1, "session", []string{auth.RoleUser})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &auth.Claims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := parsed.Header["kid"]; kid != "current" {
+		t.Errorf("signed with %v, want current", kid)
+	}
+}
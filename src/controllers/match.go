@@ -1,121 +1,149 @@
 package controllers
 
 import (
-	"database/sql"
+	"dating-app/src/auth"
+	"dating-app/src/config"
+	"dating-app/src/deck"
 	"dating-app/src/interactors"
 	"dating-app/src/models"
+	"dating-app/src/repositories"
+	"dating-app/src/storage"
+	"errors"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/gommon/log"
-	"math"
 	"net/http"
-	"sort"
-	"time"
 )
 
 type Match struct {
-	authInteractor *interactors.Auth
 	matchInteractor *interactors.Match
+	allowDebug bool
 }
 
-func NewMatch(db *sql.DB) *Match {
+func NewMatch(cfg *config.Config, users repositories.UserRepository, matches repositories.MatchRepository, profiles repositories.ProfileRepository,
+	photos repositories.PhotoRepository, blobs storage.Blobs, decks deck.Store) *Match {
 	return &Match{
-		authInteractor: interactors.NewAuth(db),
-		matchInteractor: interactors.NewMatch(db),
+		matchInteractor: interactors.NewMatch(cfg, users, matches, profiles, photos, blobs, decks),
+		// rank explanations are precise enough to work out distances from, so they're kept out of production
+		allowDebug: !cfg.IsProduction(),
 	}
 }
 
 type getProfilesRequest struct {
-	AgeMin int `json:"age_min"`
-	AgeMax int `json:"age_max"`
-	Gender string `json:"gender"`
-	Sort string `json:"sort"`
+	AgeMin *int `query:"age_min"`
+	AgeMax *int `query:"age_max"`
+	ShowMe []string `query:"show_me"`
+	Gender []string `query:"gender"`
+	Sort *string `query:"sort"`
+	MaxDistance *float64 `query:"max_distance"`
+	Unit models.DistanceUnit `query:"unit"`
+	Limit int `query:"limit"`
+	Cursor string `query:"cursor"`
+	Debug bool `query:"debug"`
 }
+
 /*
-Profiles - returns potential matches for the requesting user
-distance is calculated relative to the requesting user.
-In order to achieve this, we retrieve the users first, then calculate distance for each
+Profiles - returns a page of potential matches for the requesting user
+filters are query parameters, each one given replaces the matching saved discovery preference for this request only.
+show_me (men, women or nonbinary) can be repeated to include several groups, gender narrows it down to exactly the
+genders given and can be repeated too. gender=Not Specified on its own means any gender, as it always has,
+gender=unspecified_only is only the users who didn't give their gender. An empty sort is the default order.
+distance is calculated relative to the requesting user and shown approximately, in unit (km or mi) or the user's own unit.
+max_distance only returns profiles within that many of the same unit.
+limit sets the page size (default 20, max 100) and cursor continues from the next_cursor of the previous page,
+a cursor only works with the sort it was issued for.
+sort=ranked scores the nearest profiles on several features, weighted by the user's ranking experiment,
+and outside of production debug=true explains each profile's score
  */
 func (m *Match) Profiles (c echo.Context) error {
-	userID := m.authInteractor.GetUserFromRequest(c)
-	if userID < 1 {
+	principal, ok := auth.PrincipalFrom(c)
+	if !ok {
 		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorised access")
 	}
+	userID := principal.UserID
 
 	request := &getProfilesRequest{}
-	if err := c.Bind(request); err != nil || request.AgeMax < request.AgeMin {
+	binder := &echo.DefaultBinder{}
+	if err := binder.BindQueryParams(c, request); err != nil || request.Limit < 0 || request.Limit > interactors.MaxPageSize ||
+		(request.Unit != "" && !request.Unit.Valid()) {
 		return c.JSON(http.StatusBadRequest, nil)
 	}
-
-	ageMin := time.Time{}
-	ageMax := time.Time{}
-	currentTime := time.Now()
-
-	// convert number of years to date for db comparison
-	if request.AgeMin > 18 { // 18 is the minimum age
-		ageMin = currentTime.AddDate(-1 * request.AgeMin, 0 , 0)
+	if request.Debug && !m.allowDebug {
+		return c.JSON(http.StatusBadRequest, "debug is only available outside of production")
 	}
 
-	// convert number of years to date for db comparison
-	if request.AgeMax > 18 && request.AgeMax < 65 { // 65 is the maximum age
-		ageMax = currentTime.AddDate(-1 * request.AgeMax, 0 , 0)
+	profilesRequest := interactors.ProfilesRequest{
+		AgeMin: request.AgeMin,
+		AgeMax: request.AgeMax,
+		MaxDistance: request.MaxDistance,
+		Unit: request.Unit,
+		Cursor: request.Cursor,
+		Limit: request.Limit,
+		Debug: request.Debug,
 	}
-
-	filterOpts := interactors.FilterOpts{
-		AgeMin: &ageMin,
-		AgeMax: &ageMax,
-		Gender: models.ToGenderTypeFromString(request.Gender),
-		SortRecommended: false,
+	for _, showMe := range request.ShowMe {
+		profilesRequest.ShowMe = append(profilesRequest.ShowMe, models.ShowMe(showMe))
 	}
-
-	if request.Sort == "recommended" {
-		filterOpts.SortRecommended = true
+	profilesRequest.Genders = models.ParseGenderFilter(request.Gender)
+	if request.Sort != nil {
+		sort := models.ProfileSort(*request.Sort)
+		profilesRequest.Sort = &sort
 	}
 
-	profiles, err := m.matchInteractor.GetProfilesForUser(userID, filterOpts)
+	page, err := m.matchInteractor.GetProfilesForUser(userID, profilesRequest)
+	var validationErr *interactors.ValidationError
+	if errors.As(err, &validationErr) {
+		return c.JSON(http.StatusBadRequest, validationErr)
+	}
+	if errors.Is(err, interactors.ErrInvalidCursor) {
+		return c.JSON(http.StatusBadRequest, "invalid cursor")
+	}
 	if err != nil {
 		log.Error(err)
 		return err
 	}
 
-	requestingUser := m.authInteractor.GetUserByID(userID)
-
-	for _, profile := range profiles {
-		profile.Distance = distance(requestingUser.Latitude, requestingUser.Longitude, profile.Latitude, profile.Longitude)
-	}
-
-	if request.Sort == "distance" {
-		sort.SliceStable(profiles, func(i, j int) bool {
-			return *profiles[i].Distance < *profiles[j].Distance
-		})
-	}
+	return c.JSON(http.StatusOK, page)
+}
 
-	return c.JSON(http.StatusOK, profiles)
+type getMatchesRequest struct {
+	Unit models.DistanceUnit `query:"unit"`
+	Limit int `query:"limit"`
+	Cursor string `query:"cursor"`
 }
 
 /*
-	distance is a helper function for calculating the distance of profiles from the requesting user using latitude and longitude
-	this was a formula I found online to calculate distance between lat and long points
-*/
-func distance(lat1 float64, lng1 float64, lat2 float64, lng2 float64) *float64 {
-	const PI float64 = 3.141592653589793
-
-	radLat1 := PI * lat1 / 180
-	radLat2 := PI * lat2 / 180
-
-	theta := lng1 - lng2
-	radTheta := PI * theta / 180
-
-	dist := math.Sin(radLat1) * math.Sin(radLat2) + math.Cos(radLat1) * math.Cos(radLat2) * math.Cos(radTheta)
+Matches - returns a page of the users the requesting user is matched with, most recently matched first
+each has the other user's profile and when they matched, last_message is reserved for messaging and always null for now.
+distance is shown approximately, in unit (km or mi) or the user's own unit.
+limit sets the page size (default 20, max 100) and cursor continues from the next_cursor of the previous page
+ */
+func (m *Match) Matches (c echo.Context) error {
+	principal, ok := auth.PrincipalFrom(c)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorised access")
+	}
 
-	if dist > 1 {
-		dist = 1
+	request := &getMatchesRequest{}
+	binder := &echo.DefaultBinder{}
+	if err := binder.BindQueryParams(c, request); err != nil || request.Limit < 0 || request.Limit > interactors.MaxPageSize ||
+		(request.Unit != "" && !request.Unit.Valid()) {
+		return c.JSON(http.StatusBadRequest, nil)
 	}
 
-	dist = math.Acos(dist)
-	dist = dist * 180 / PI
-	dist = dist * 60 * 1.1515
+	page, err := m.matchInteractor.GetMatches(principal.UserID, interactors.MatchesRequest{
+		Unit: request.Unit,
+		Cursor: request.Cursor,
+		Limit: request.Limit,
+	})
+	if errors.Is(err, interactors.ErrInvalidCursor) {
+		return c.JSON(http.StatusBadRequest, "invalid cursor")
+	}
+	if err != nil {
+		log.Error(err)
+		return err
+	}
 
-	return &dist
+	return c.JSON(http.StatusOK, page)
 }
 
 type swipeRequest struct {
@@ -135,90 +163,39 @@ Swipe - requesting user can swipe yes or no on the specified user
 		ie a user swiping right twice
 	a user is able to change their swipe at any time (this would allow for rematch or unmatching)
 	if the Swipe action is completed, the user receiving the swipe will get an updated likeability score (used in filtering profile results)
+	the whole swipe is one transaction, so two users swiping yes on each other at the same time are matched once
 */
 func (m *Match) Swipe (c echo.Context) error {
-	userID := m.authInteractor.GetUserFromRequest(c)
-	if userID < 1 {
+	principal, ok := auth.PrincipalFrom(c)
+	if !ok {
 		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorised access")
 	}
+	userID := principal.UserID
 	request := &swipeRequest{}
-	if err := c.Bind(request); err != nil || request.ProfileID < 1 || request.Preference == "" || (request.Preference != "YES" && request.Preference != "NO") {
+	if err := c.Bind(request); err != nil || request.ProfileID < 1 || !models.Preference(request.Preference).Valid() {
 		return c.JSON(http.StatusBadRequest, nil)
 	}
 
-	currentMatch, err := m.matchInteractor.GetRelationship(userID, request.ProfileID)
+	relationship, err := m.matchInteractor.Swipe(userID, request.ProfileID, models.Preference(request.Preference))
+	if errors.Is(err, interactors.ErrAlreadySwiped) {
+		return c.JSON(http.StatusConflict, "already swiped this profile")
+	}
+	if errors.Is(err, interactors.ErrSwipeSelf) {
+		return c.JSON(http.StatusBadRequest, "can't swipe on your own profile")
+	}
+	if errors.Is(err, repositories.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, "profile not found")
+	}
 	if err != nil {
 		log.Error(err)
 		return err
 	}
 
-	if currentMatch == nil {
-		// create new relationship
-		preference := models.Pending
-		if request.Preference == "NO" {
-			preference = models.Unmatched
-		}
-		currentMatch = &models.Match{
-			UserID:  userID,
-			MatchID: request.ProfileID,
-			State:   preference,
-		}
-
-		err = m.matchInteractor.Create(currentMatch)
-		if err != nil {
-			log.Error(err)
-			return err
-		}
-	} else {
-		// user has already swiped
-		if userID == currentMatch.UserID &&
-			(currentMatch.State != models.Unmatched && request.Preference == "YES") ||
-			(currentMatch.State == models.Unmatched && request.Preference == "NO") ||
-			(request.Preference == "YES" && currentMatch.State == models.Matched) {
-			return c.JSON(http.StatusConflict, "already swiped this profile")
-		}
-
-		needsUpdate := false
-
-		// update existing relationship
-		if request.Preference == "NO" {
-			currentMatch.State = models.Unmatched
-			needsUpdate = true
-		}
-
-		if request.Preference == "YES" && currentMatch.State == models.Pending {
-			currentMatch.State = models.Matched
-			needsUpdate = true
-		}
-
-		if needsUpdate {
-			err = m.matchInteractor.Update(currentMatch)
-			if err != nil {
-				log.Error(err)
-				return err
-			}
-		}
-	}
-
-	if request.Preference == "YES" {
-		err = m.authInteractor.UpdateUserLikabilityScore(request.ProfileID, 1)
-		if err != nil {
-			log.Error(err)
-			return err
-		}
-	} else if request.Preference == "NO" {
-		err = m.authInteractor.UpdateUserLikabilityScore(request.ProfileID, -1)
-		if err != nil {
-			log.Error(err)
-			return err
-		}
-	}
-
 	response := swipeResponse{
 		Matched: false,
 		MatchID: nil,
 	}
-	if currentMatch.State == models.Matched {
+	if relationship.State == models.Matched {
 		response.Matched = true
 		response.MatchID = &request.ProfileID
 	}
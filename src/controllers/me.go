@@ -0,0 +1,397 @@
+package controllers
+
+import (
+	"dating-app/src/auth"
+	"dating-app/src/deck"
+	"dating-app/src/interactors"
+	"dating-app/src/models"
+	"dating-app/src/repositories"
+	"dating-app/src/storage"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Me - endpoints for the requesting user's own profile
+*/
+type Me struct {
+	profileInteractor *interactors.Profile
+}
+
+func NewMe(users repositories.UserRepository, profiles repositories.ProfileRepository, photos repositories.PhotoRepository, blobs storage.Blobs,
+	decks deck.Store) *Me {
+	return &Me{
+		profileInteractor: interactors.NewProfile(users, profiles, photos, blobs, decks),
+	}
+}
+
+type meResponse struct {
+	*models.User
+	Location models.Location `json:"location"`
+	LastLocatedAt *time.Time `json:"last_located_at"`
+}
+
+func newMeResponse(user *models.User) meResponse {
+	return meResponse{
+		User: user,
+		Location: models.Location{Latitude: user.Latitude, Longitude: user.Longitude},
+		LastLocatedAt: user.LastLocatedAt,
+	}
+}
+
+/*
+etag - a user's profile is versioned, the ETag is the version
+*/
+func etag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+/*
+ifMatchVersion - reads the version from an If-Match header, only a single ETag as returned by GET /me is accepted
+*/
+func ifMatchVersion(header string) (int, bool) {
+	unquoted, err := strconv.Unquote(strings.TrimSpace(header))
+	if err != nil {
+		return 0, false
+	}
+	version, err := strconv.Atoi(unquoted)
+	if err != nil {
+		return 0, false
+	}
+	return version, true
+}
+
+/*
+Get - the requesting user's own profile, including their email, settings and location
+the ETag header is needed to update it with PATCH /me
+ */
+func (m *Me) Get (c echo.Context) error {
+	principal, ok := auth.PrincipalFrom(c)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorised access")
+	}
+
+	user, err := m.profileInteractor.Get(principal.UserID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, "user not found")
+	}
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	c.Response().Header().Set("ETag", etag(user.Version))
+	return c.JSON(http.StatusOK, newMeResponse(user))
+}
+
+/*
+decodeNullable - decodes a single json field, nil if it was null
+*/
+func decodeNullable[T any](raw json.RawMessage) (*T, error) {
+	var value *T
+	err := json.Unmarshal(raw, &value)
+	return value, err
+}
+
+/*
+orZero - null string fields are cleared rather than left alone
+*/
+func orZero[T any](value *T, err error) (*T, error) {
+	if value == nil {
+		value = new(T)
+	}
+	return value, err
+}
+
+/*
+Patch - updates the fields sent and leaves the rest alone, null clears gender_description, bio, job_title, school and height_cm
+the If-Match header must be the ETag from GET /me (428 without it), if the profile has changed since
+the update is rejected with 412 so edits from another device aren't overwritten
+returns 400 with the invalid fields, including any field that can't be changed here
+ */
+func (m *Me) Patch (c echo.Context) error {
+	principal, ok := auth.PrincipalFrom(c)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorised access")
+	}
+
+	ifMatch := c.Request().Header.Get("If-Match")
+	if ifMatch == "" {
+		return c.JSON(http.StatusPreconditionRequired, "If-Match is required, send the ETag from GET /me")
+	}
+	version, ok := ifMatchVersion(ifMatch)
+	if !ok {
+		return c.JSON(http.StatusPreconditionFailed, "profile has changed, fetch it again")
+	}
+
+	// fields are decoded one at a time so a field left out can be told apart from one sent as null
+	fields := map[string]json.RawMessage{}
+	if err := json.NewDecoder(c.Request().Body).Decode(&fields); err != nil {
+		return c.JSON(http.StatusBadRequest, nil)
+	}
+
+	formatErrors := &interactors.ValidationError{}
+	changes := interactors.ProfileChanges{}
+	for field, raw := range fields {
+		var err error
+		switch field {
+		case "name":
+			changes.Name, err = orZero(decodeNullable[string](raw))
+		case "gender":
+			changes.Gender, err = decodeNullable[models.GenderType](raw)
+			if changes.Gender == nil {
+				notSpecified := models.NotSpecified
+				changes.Gender = &notSpecified
+			}
+		case "gender_description":
+			changes.GenderDescription, err = orZero(decodeNullable[string](raw))
+		case "bio":
+			changes.Bio, err = orZero(decodeNullable[string](raw))
+		case "job_title":
+			changes.JobTitle, err = orZero(decodeNullable[string](raw))
+		case "school":
+			changes.School, err = orZero(decodeNullable[string](raw))
+		case "height_cm":
+			changes.HeightCM, err = decodeNullable[int](raw)
+			changes.ClearHeight = changes.HeightCM == nil
+		case "distance_unit":
+			changes.DistanceUnit, err = orZero(decodeNullable[models.DistanceUnit](raw))
+		default:
+			formatErrors.Add(field, "can't be changed with PATCH /me")
+		}
+		if err != nil {
+			formatErrors.Add(field, "has the wrong type")
+		}
+	}
+	if formatErrors.OrNil() != nil {
+		return c.JSON(http.StatusBadRequest, formatErrors)
+	}
+
+	user, err := m.profileInteractor.Update(principal.UserID, version, changes)
+	var validationErr *interactors.ValidationError
+	if errors.As(err, &validationErr) {
+		return c.JSON(http.StatusBadRequest, validationErr)
+	}
+	if errors.Is(err, repositories.ErrVersionConflict) {
+		return c.JSON(http.StatusPreconditionFailed, "profile has changed, fetch it again")
+	}
+	if errors.Is(err, repositories.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, "user not found")
+	}
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	c.Response().Header().Set("ETag", etag(user.Version))
+	return c.JSON(http.StatusOK, newMeResponse(user))
+}
+
+type locationRequest struct {
+	Latitude *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+}
+
+type locationResponse struct {
+	models.Location
+	LastLocatedAt time.Time `json:"last_located_at"`
+}
+
+/*
+UpdateLocation - the app reports where the user is, both latitude and longitude are required
+ */
+func (m *Me) UpdateLocation (c echo.Context) error {
+	principal, ok := auth.PrincipalFrom(c)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorised access")
+	}
+
+	request := &locationRequest{}
+	if err := c.Bind(request); err != nil {
+		return c.JSON(http.StatusBadRequest, nil)
+	}
+
+	formatErrors := &interactors.ValidationError{}
+	if request.Latitude == nil {
+		formatErrors.Add("latitude", "is required")
+	}
+	if request.Longitude == nil {
+		formatErrors.Add("longitude", "is required")
+	}
+	if formatErrors.OrNil() != nil {
+		return c.JSON(http.StatusBadRequest, formatErrors)
+	}
+
+	location := models.Location{Latitude: *request.Latitude, Longitude: *request.Longitude}
+	locatedAt, err := m.profileInteractor.UpdateLocation(principal.UserID, location)
+	var validationErr *interactors.ValidationError
+	if errors.As(err, &validationErr) {
+		return c.JSON(http.StatusBadRequest, validationErr)
+	}
+	var tooSoonErr *interactors.LocationTooSoonError
+	if errors.As(err, &tooSoonErr) {
+		c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(tooSoonErr.RetryAfter.Seconds()))))
+		return echo.NewHTTPError(http.StatusTooManyRequests, "Location was changed too recently")
+	}
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, locationResponse{
+		Location: location,
+		LastLocatedAt: locatedAt,
+	})
+}
+
+type interestsRequest struct {
+	Interests []string `json:"interests"`
+}
+
+type interestsResponse struct {
+	Interests []models.Interest `json:"interests"`
+}
+
+/*
+UpdateInterests - replaces the user's interests with the slugs given, from GET /interests
+ */
+func (m *Me) UpdateInterests (c echo.Context) error {
+	principal, ok := auth.PrincipalFrom(c)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorised access")
+	}
+
+	request := &interestsRequest{}
+	if err := c.Bind(request); err != nil || request.Interests == nil {
+		return c.JSON(http.StatusBadRequest, nil)
+	}
+
+	interests, err := m.profileInteractor.SetInterests(principal.UserID, request.Interests)
+	var validationErr *interactors.ValidationError
+	if errors.As(err, &validationErr) {
+		return c.JSON(http.StatusBadRequest, validationErr)
+	}
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, interestsResponse{Interests: interests})
+}
+
+type promptsRequest struct {
+	Prompts []models.PromptAnswer `json:"prompts"`
+}
+
+type promptsResponse struct {
+	Prompts []models.PromptAnswer `json:"prompts"`
+}
+
+/*
+UpdatePrompts - replaces the user's prompt answers, up to three prompts from GET /prompts each with an answer
+ */
+func (m *Me) UpdatePrompts (c echo.Context) error {
+	principal, ok := auth.PrincipalFrom(c)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorised access")
+	}
+
+	request := &promptsRequest{}
+	if err := c.Bind(request); err != nil || request.Prompts == nil {
+		return c.JSON(http.StatusBadRequest, nil)
+	}
+
+	prompts, err := m.profileInteractor.SetPrompts(principal.UserID, request.Prompts)
+	var validationErr *interactors.ValidationError
+	if errors.As(err, &validationErr) {
+		return c.JSON(http.StatusBadRequest, validationErr)
+	}
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, promptsResponse{Prompts: prompts})
+}
+
+/*
+Preferences - who the user wants to see in GET /profiles, max_distance is in the user's own unit
+ */
+func (m *Me) Preferences (c echo.Context) error {
+	principal, ok := auth.PrincipalFrom(c)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorised access")
+	}
+
+	preferences, err := m.profileInteractor.GetPreferences(principal.UserID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, "user not found")
+	}
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, preferences)
+}
+
+/*
+preferencesRequest - discovery preferences as they're sent, genders is the older form of show_me
+*/
+type preferencesRequest struct {
+	models.DiscoveryPreferences
+	Genders []models.GenderType `json:"genders"`
+}
+
+/*
+UpdatePreferences - replaces the user's discovery preferences, fields left out or null remove that restriction
+max_distance is in unit if it's given, otherwise the user's own unit.
+Clients that still send genders have each one replaced with its show me group
+ */
+func (m *Me) UpdatePreferences (c echo.Context) error {
+	principal, ok := auth.PrincipalFrom(c)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorised access")
+	}
+
+	request := &preferencesRequest{}
+	if err := c.Bind(request); err != nil {
+		return c.JSON(http.StatusBadRequest, nil)
+	}
+
+	formatErrors := &interactors.ValidationError{}
+	for _, gender := range request.Genders {
+		showMe := gender.ShowMe()
+		if showMe == "" {
+			formatErrors.Add("genders", fmt.Sprintf("%q isn't in a show me group, use show_me instead", gender))
+			continue
+		}
+		request.ShowMe = append(request.ShowMe, showMe)
+	}
+	if formatErrors.OrNil() != nil {
+		return c.JSON(http.StatusBadRequest, formatErrors)
+	}
+
+	preferences, err := m.profileInteractor.SavePreferences(principal.UserID, request.DiscoveryPreferences)
+	var validationErr *interactors.ValidationError
+	if errors.As(err, &validationErr) {
+		return c.JSON(http.StatusBadRequest, validationErr)
+	}
+	if errors.Is(err, repositories.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, "user not found")
+	}
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, preferences)
+}
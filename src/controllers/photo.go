@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"dating-app/src/auth"
+	"dating-app/src/interactors"
+	"dating-app/src/media"
+	"dating-app/src/models"
+	"dating-app/src/repositories"
+	"dating-app/src/storage"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+/*
+Photos - endpoints for the requesting user's profile photos
+*/
+type Photos struct {
+	photoInteractor *interactors.Photos
+}
+
+func NewPhotos(photos repositories.PhotoRepository, blobs storage.Blobs) *Photos {
+	return &Photos{
+		photoInteractor: interactors.NewPhotos(photos, blobs),
+	}
+}
+
+type photosResponse struct {
+	Photos []models.Photo `json:"photos"`
+}
+
+/*
+List - the user's photos in order, the first is their primary photo
+ */
+func (p *Photos) List (c echo.Context) error {
+	principal, ok := auth.PrincipalFrom(c)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorised access")
+	}
+
+	photos, err := p.photoInteractor.List(principal.UserID)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, photosResponse{Photos: photos})
+}
+
+/*
+Upload - adds a photo after the user's others, sent as multipart/form-data in the photo field
+JPEG, PNG and WebP are accepted, see media.Process for the limits. Every photo is stored as a JPEG without its metadata
+returns 201 with the photo, 400 if it isn't acceptable or the user already has as many as they're allowed, 413 if it's too big
+ */
+func (p *Photos) Upload (c echo.Context) error {
+	principal, ok := auth.PrincipalFrom(c)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorised access")
+	}
+
+	file, err := c.FormFile("photo")
+	if err != nil {
+		formatErrors := &interactors.ValidationError{}
+		formatErrors.Add("photo", "is required, send it as multipart/form-data")
+		return c.JSON(http.StatusBadRequest, formatErrors)
+	}
+	if file.Size > media.MaxFileSize {
+		return c.JSON(http.StatusRequestEntityTooLarge, media.ErrTooLarge.Error())
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(io.LimitReader(src, media.MaxFileSize+1))
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	photo, err := p.photoInteractor.Upload(principal.UserID, data)
+	var validationErr *interactors.ValidationError
+	if errors.As(err, &validationErr) {
+		return c.JSON(http.StatusBadRequest, validationErr)
+	}
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, photo)
+}
+
+/*
+Delete - removes one of the user's photos, if it was their primary photo the next one takes its place
+ */
+func (p *Photos) Delete (c echo.Context) error {
+	principal, ok := auth.PrincipalFrom(c)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorised access")
+	}
+
+	photoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, "photo not found")
+	}
+
+	err = p.photoInteractor.Delete(principal.UserID, photoID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, "photo not found")
+	}
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+type orderPhotosRequest struct {
+	PhotoIDs []int `json:"photo_ids"`
+}
+
+/*
+Order - rearranges the user's photos, photo_ids must list each of them once and the first becomes the primary photo
+ */
+func (p *Photos) Order (c echo.Context) error {
+	principal, ok := auth.PrincipalFrom(c)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorised access")
+	}
+
+	request := &orderPhotosRequest{}
+	if err := c.Bind(request); err != nil || request.PhotoIDs == nil {
+		return c.JSON(http.StatusBadRequest, nil)
+	}
+
+	photos, err := p.photoInteractor.Order(principal.UserID, request.PhotoIDs)
+	var validationErr *interactors.ValidationError
+	if errors.As(err, &validationErr) {
+		return c.JSON(http.StatusBadRequest, validationErr)
+	}
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, photosResponse{Photos: photos})
+}
+
+/*
+MakePrimary - moves the photo to the front of the user's photos, the rest keep their order
+ */
+func (p *Photos) MakePrimary (c echo.Context) error {
+	principal, ok := auth.PrincipalFrom(c)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorised access")
+	}
+
+	photoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, "photo not found")
+	}
+
+	photos, err := p.photoInteractor.MakePrimary(principal.UserID, photoID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, "photo not found")
+	}
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, photosResponse{Photos: photos})
+}
@@ -0,0 +1,144 @@
+package deck
+
+import (
+	"bytes"
+	"dating-app/src/config"
+	"dating-app/src/models"
+	"encoding/gob"
+	"fmt"
+	"time"
+)
+
+/*
+Deck - the profiles a user would be shown with their saved discovery preferences, in order, worked out ahead of time.
+Profiles keep the exact values they were sorted on, they're only ever cached, never returned as they are.
+Filters identifies the preferences and location it was generated for, GeneratedAt is the time desirability was decayed to
+and Complete is set when every profile fitted in the deck
+*/
+type Deck struct {
+	Sort        models.ProfileSort
+	Filters     string
+	GeneratedAt time.Time
+	Complete    bool
+	Profiles    []*models.Profile
+}
+
+/*
+Store - somewhere to cache decks, keyed by user.
+Get returns nil when the user has no deck or it has expired, Remove takes a profile out of a user's deck
+and returns how many are left, -1 if they don't have one
+*/
+type Store interface {
+	Get(userID int) (*Deck, error)
+	Put(userID int, deck *Deck) error
+	Remove(userID, profileID int) (int, error)
+	Invalidate(userID int) error
+}
+
+/*
+New - the store the config asks for
+*/
+func New(cfg config.Deck) (Store, error) {
+	switch cfg.Store {
+	case config.MemoryDecks:
+		return NewLRU(cfg.Capacity, cfg.TTL.Duration()), nil
+	case config.RedisDecks:
+		return NewRedis(cfg.RedisURL, cfg.TTL.Duration())
+	default:
+		return nil, fmt.Errorf("unknown deck store %q", cfg.Store)
+	}
+}
+
+/*
+cachedDeck - a deck as it's encoded
+*/
+type cachedDeck struct {
+	Sort        models.ProfileSort
+	Filters     string
+	GeneratedAt time.Time
+	Complete    bool
+	Profiles    []cachedProfile
+}
+
+/*
+cachedProfile - gob leaves out zero values, so a pointer to a zero would come back nil.
+Whether the values profiles are sorted on were set is kept alongside them
+*/
+type cachedProfile struct {
+	Profile         models.Profile
+	HasLikability   bool
+	HasDistance     bool
+	HasDesirability bool
+}
+
+/*
+encode - decks are cached encoded, every field of a profile is kept including the ones its JSON leaves out,
+and decoding gives each caller a copy of its own
+*/
+func encode(deck *Deck) ([]byte, error) {
+	cached := cachedDeck{
+		Sort:        deck.Sort,
+		Filters:     deck.Filters,
+		GeneratedAt: deck.GeneratedAt,
+		Complete:    deck.Complete,
+		Profiles:    make([]cachedProfile, len(deck.Profiles)),
+	}
+	for i, profile := range deck.Profiles {
+		cached.Profiles[i] = cachedProfile{
+			Profile:         *profile,
+			HasLikability:   profile.LikabilityScore != nil,
+			HasDistance:     profile.Distance != nil,
+			HasDesirability: profile.Desirability != nil,
+		}
+	}
+
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(cached)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decode(data []byte) (*Deck, error) {
+	var cached cachedDeck
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&cached)
+	if err != nil {
+		return nil, err
+	}
+
+	deck := &Deck{
+		Sort:        cached.Sort,
+		Filters:     cached.Filters,
+		GeneratedAt: cached.GeneratedAt,
+		Complete:    cached.Complete,
+		Profiles:    make([]*models.Profile, len(cached.Profiles)),
+	}
+	for i := range cached.Profiles {
+		profile := &cached.Profiles[i].Profile
+		if cached.Profiles[i].HasLikability && profile.LikabilityScore == nil {
+			profile.LikabilityScore = new(int)
+		}
+		if cached.Profiles[i].HasDistance && profile.Distance == nil {
+			profile.Distance = new(float64)
+		}
+		if cached.Profiles[i].HasDesirability && profile.Desirability == nil {
+			profile.Desirability = new(float64)
+		}
+		deck.Profiles[i] = profile
+	}
+	return deck, nil
+}
+
+/*
+without - the deck without the profile, and whether it was in it
+*/
+func (d *Deck) without(profileID int) bool {
+	for i, profile := range d.Profiles {
+		if profile.ID == profileID {
+			d.Profiles = append(d.Profiles[:i], d.Profiles[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package deck
+
+import (
+	"dating-app/src/models"
+	"github.com/alicebob/miniredis/v2"
+	"reflect"
+	"testing"
+	"time"
+)
+
+/*
+newRedisStore - a Redis store on a miniredis server that's stopped when the test ends
+*/
+func newRedisStore(t *testing.T, ttl time.Duration) (*Redis, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	store, err := NewRedis("redis://"+server.Addr()+"/0", ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, server
+}
+
+func testDeck(profileIDs ...int) *Deck {
+	zero := 0
+	zeroDistance := 0.0
+	deck := &Deck{
+		Sort:        models.SortDistance,
+		Filters:     `{"Age":30}`,
+		GeneratedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	for _, profileID := range profileIDs {
+		// the first profile's values are all zero, which have to survive being encoded
+		deck.Profiles = append(deck.Profiles, &models.Profile{ID: profileID, Name: "Sam", LikabilityScore: &zero, Distance: &zeroDistance})
+	}
+	return deck
+}
+
+func TestStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"lru": func(t *testing.T) Store {
+			return NewLRU(10, time.Hour)
+		},
+		"redis": func(t *testing.T) Store {
+			store, _ := newRedisStore(t, time.Hour)
+			return store
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+
+			got, err := store.Get(1)
+			if err != nil || got != nil {
+				t.Fatalf("Get() with no deck = %v, %v, want nil", got, err)
+			}
+			if remaining, err := store.Remove(1, 2); err != nil || remaining != -1 {
+				t.Errorf("Remove() with no deck = %d, %v, want -1", remaining, err)
+			}
+
+			want := testDeck(2, 3, 4)
+			if err = store.Put(1, want); err != nil {
+				t.Fatal(err)
+			}
+			got, err = store.Get(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Get() = %+v, want %+v", got, want)
+			}
+
+			remaining, err := store.Remove(1, 3)
+			if err != nil || remaining != 2 {
+				t.Errorf("Remove() = %d, %v, want 2 left", remaining, err)
+			}
+			if remaining, err = store.Remove(1, 3); err != nil || remaining != 2 {
+				t.Errorf("Remove() again = %d, %v, want 2 left", remaining, err)
+			}
+			got, _ = store.Get(1)
+			if got == nil || !reflect.DeepEqual(profileIDs(got), []int{2, 4}) {
+				t.Errorf("Get() after Remove() = %v, want profiles 2 and 4", got)
+			}
+
+			if err = store.Invalidate(1); err != nil {
+				t.Fatal(err)
+			}
+			if got, _ = store.Get(1); got != nil {
+				t.Errorf("Get() after Invalidate() = %v, want nil", got)
+			}
+		})
+	}
+}
+
+func TestRedisDecksExpire(t *testing.T) {
+	store, server := newRedisStore(t, time.Minute)
+	if err := store.Put(1, testDeck(2, 3)); err != nil {
+		t.Fatal(err)
+	}
+
+	server.FastForward(30 * time.Second)
+	// taking a profile out keeps the deck's expiry
+	if _, err := store.Remove(1, 2); err != nil {
+		t.Fatal(err)
+	}
+	server.FastForward(31 * time.Second)
+	if got, _ := store.Get(1); got != nil {
+		t.Errorf("Get() after the ttl = %v, want nil", got)
+	}
+}
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	store := NewLRU(2, time.Hour)
+	for userID := 1; userID <= 2; userID++ {
+		if err := store.Put(userID, testDeck(10)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.Get(1)
+	if err := store.Put(3, testDeck(10)); err != nil {
+		t.Fatal(err)
+	}
+
+	for userID, kept := range map[int]bool{1: true, 2: false, 3: true} {
+		if got, _ := store.Get(userID); (got != nil) != kept {
+			t.Errorf("user %d's deck kept = %v, want %v", userID, got != nil, kept)
+		}
+	}
+}
+
+func profileIDs(deck *Deck) []int {
+	var ids []int
+	for _, profile := range deck.Profiles {
+		ids = append(ids, profile.ID)
+	}
+	return ids
+}
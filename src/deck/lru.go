@@ -0,0 +1,120 @@
+package deck
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+/*
+LRU - thread-safe Store kept in this process, holding up to capacity decks and dropping the least recently used
+*/
+type LRU struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	order    *list.List
+	entries  map[int]*list.Element
+}
+
+type lruEntry struct {
+	userID    int
+	deck      []byte
+	expiresAt time.Time
+}
+
+func NewLRU(capacity int, ttl time.Duration) *LRU {
+	return &LRU{
+		capacity: capacity,
+		ttl:      ttl,
+		order:    list.New(),
+		entries:  make(map[int]*list.Element),
+	}
+}
+
+func (l *LRU) Get(userID int) (*Deck, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.entry(userID)
+	if !ok {
+		return nil, nil
+	}
+	return decode(entry.deck)
+}
+
+func (l *LRU) Put(userID int, deck *Deck) error {
+	encoded, err := encode(deck)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if element, ok := l.entries[userID]; ok {
+		l.order.Remove(element)
+	}
+	l.entries[userID] = l.order.PushFront(&lruEntry{userID: userID, deck: encoded, expiresAt: time.Now().Add(l.ttl)})
+
+	for l.order.Len() > l.capacity {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*lruEntry).userID)
+	}
+
+	return nil
+}
+
+func (l *LRU) Remove(userID, profileID int) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.entry(userID)
+	if !ok {
+		return -1, nil
+	}
+	deck, err := decode(entry.deck)
+	if err != nil {
+		return -1, err
+	}
+	if deck.without(profileID) {
+		entry.deck, err = encode(deck)
+		if err != nil {
+			return -1, err
+		}
+	}
+
+	return len(deck.Profiles), nil
+}
+
+func (l *LRU) Invalidate(userID int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if element, ok := l.entries[userID]; ok {
+		l.order.Remove(element)
+		delete(l.entries, userID)
+	}
+
+	return nil
+}
+
+/*
+entry - the user's deck, marked as the most recently used, expired decks are dropped
+*/
+func (l *LRU) entry(userID int) (*lruEntry, bool) {
+	element, ok := l.entries[userID]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*lruEntry)
+	if time.Now().After(entry.expiresAt) {
+		l.order.Remove(element)
+		delete(l.entries, userID)
+		return nil, false
+	}
+
+	l.order.MoveToFront(element)
+	return entry, true
+}
@@ -0,0 +1,117 @@
+package deck
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"time"
+)
+
+/*
+Redis - Store kept in Redis, or anything that speaks its protocol, so every instance of the app shares the same decks.
+Each deck is one key that expires after the ttl
+*/
+type Redis struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+/*
+removeAttempts - how many times Remove is tried when the deck changes while it's being updated
+*/
+const removeAttempts = 3
+
+/*
+NewRedis - a store on the server at a redis:// or rediss:// URL, redis://[username:password@]host:port[/db]
+*/
+func NewRedis(url string, ttl time.Duration) (*Redis, error) {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Redis{
+		client: redis.NewClient(options),
+		ttl:    ttl,
+	}, nil
+}
+
+func deckKey(userID int) string {
+	return "deck:" + strconv.Itoa(userID)
+}
+
+func (r *Redis) Get(userID int) (*Deck, error) {
+	encoded, err := r.client.Get(context.Background(), deckKey(userID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decode(encoded)
+}
+
+func (r *Redis) Put(userID int, deck *Deck) error {
+	encoded, err := encode(deck)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Set(context.Background(), deckKey(userID), encoded, r.ttl).Err()
+}
+
+/*
+Remove - takes the profile out of the deck with a WATCH, so a deck put while it's being changed isn't overwritten
+*/
+func (r *Redis) Remove(userID, profileID int) (int, error) {
+	ctx := context.Background()
+	key := deckKey(userID)
+
+	remaining := -1
+	for attempt := 0; attempt < removeAttempts; attempt++ {
+		err := r.client.Watch(ctx, func(tx *redis.Tx) error {
+			encoded, err := tx.Get(ctx, key).Bytes()
+			if errors.Is(err, redis.Nil) {
+				remaining = -1
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			deck, err := decode(encoded)
+			if err != nil {
+				return err
+			}
+			if !deck.without(profileID) {
+				remaining = len(deck.Profiles)
+				return nil
+			}
+			encoded, err = encode(deck)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.SetArgs(ctx, key, encoded, redis.SetArgs{KeepTTL: true})
+				return nil
+			})
+			if err == nil {
+				remaining = len(deck.Profiles)
+			}
+			return err
+		}, key)
+		// the transaction fails when the key changed after the WATCH
+		if !errors.Is(err, redis.TxFailedErr) {
+			return remaining, err
+		}
+	}
+
+	// the deck keeps changing under us, dropping it is always safe
+	return -1, r.Invalidate(userID)
+}
+
+func (r *Redis) Invalidate(userID int) error {
+	return r.client.Del(context.Background(), deckKey(userID)).Err()
+}
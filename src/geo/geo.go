@@ -0,0 +1,104 @@
+package geo
+
+import "math"
+
+/*
+EarthRadiusMiles - mean radius of the earth, distances are in statute miles throughout the app
+*/
+const EarthRadiusMiles = 3958.8
+
+/*
+MilesPerDegreeLatitude - the length of one degree of latitude, which is the same everywhere on a sphere
+*/
+const MilesPerDegreeLatitude = EarthRadiusMiles * math.Pi / 180
+
+/*
+Haversine - great circle distance in miles between two points
+the MySQL repository computes the same formula in SQL, keep the two in step
+*/
+func Haversine(lat1, lng1, lat2, lng2 float64) float64 {
+	dLat := radians(lat2 - lat1)
+	dLng := radians(lng2 - lng1)
+
+	a := math.Pow(math.Sin(dLat/2), 2) + math.Cos(radians(lat1))*math.Cos(radians(lat2))*math.Pow(math.Sin(dLng/2), 2)
+
+	return EarthRadiusMiles * 2 * math.Asin(math.Sqrt(math.Min(1, a)))
+}
+
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+/*
+BoundingBox - a latitude/longitude rectangle containing every point within some distance of a centre
+It's a cheap prefilter that can use an index, points inside it still need checking with Haversine.
+When the box crosses the antimeridian MinLng is greater than MaxLng and longitudes outside [MaxLng, MinLng] match.
+When it reaches a pole every longitude matches and AllLongitudes is set
+*/
+type BoundingBox struct {
+	MinLat, MaxLat float64
+	MinLng, MaxLng float64
+	AllLongitudes  bool
+}
+
+func NewBoundingBox(lat, lng, radiusMiles float64) BoundingBox {
+	dLat := radiusMiles / MilesPerDegreeLatitude
+
+	box := BoundingBox{
+		MinLat: lat - dLat,
+		MaxLat: lat + dLat,
+	}
+
+	if box.MinLat <= -90 || box.MaxLat >= 90 {
+		box.MinLat = math.Max(box.MinLat, -90)
+		box.MaxLat = math.Min(box.MaxLat, 90)
+		box.AllLongitudes = true
+		return box
+	}
+
+	// the widest point of the circle is at the latitude furthest from the equator
+	widest := math.Max(math.Abs(box.MinLat), math.Abs(box.MaxLat))
+	dLng := dLat / math.Cos(radians(widest))
+	if dLng >= 180 {
+		box.AllLongitudes = true
+		return box
+	}
+
+	box.MinLng = normaliseLongitude(lng - dLng)
+	box.MaxLng = normaliseLongitude(lng + dLng)
+
+	return box
+}
+
+/*
+CrossesAntimeridian - the box wraps from 180 to -180
+*/
+func (b BoundingBox) CrossesAntimeridian() bool {
+	return !b.AllLongitudes && b.MinLng > b.MaxLng
+}
+
+/*
+Contains - whether the point is inside the box
+*/
+func (b BoundingBox) Contains(lat, lng float64) bool {
+	if lat < b.MinLat || lat > b.MaxLat {
+		return false
+	}
+	if b.AllLongitudes {
+		return true
+	}
+	if b.CrossesAntimeridian() {
+		return lng >= b.MinLng || lng <= b.MaxLng
+	}
+	return lng >= b.MinLng && lng <= b.MaxLng
+}
+
+func normaliseLongitude(lng float64) float64 {
+	for lng > 180 {
+		lng -= 360
+	}
+	for lng < -180 {
+		lng += 360
+	}
+	return lng
+}
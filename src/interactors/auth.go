@@ -1,115 +1,272 @@
 package interactors
 
 import (
-	"database/sql"
+	"dating-app/src/config"
 	"dating-app/src/models"
-	"github.com/golang-jwt/jwt"
-	jwtv4 "github.com/golang-jwt/jwt/v4"
-	"github.com/labstack/echo/v4"
-	"math"
+	"dating-app/src/passwords"
+	"dating-app/src/repositories"
+	"errors"
+	"github.com/labstack/gommon/log"
+	"net/mail"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
+var ErrInvalidCredentials = errors.New("invalid login credentials")
+
+/*
+dummyHash - verified against when the email doesn't exist so a failed login takes the same time either way
+*/
+var dummyHash, _ = passwords.Hash("dating-app-dummy-password")
+
+/*
+verifyPassword - passwords.Verify, a variable so tests can see which hash a login was checked against
+*/
+var verifyPassword = passwords.Verify
+
 type Auth struct {
-	db *sql.DB
+	cfg *config.Config
+	users repositories.UserRepository
 }
 
-func NewAuth(db *sql.DB) *Auth {
+func NewAuth(cfg *config.Config, users repositories.UserRepository) *Auth {
 	return &Auth{
-		db: db,
+		cfg: cfg,
+		users: users,
 	}
 }
 
 /*
 GetUserByID - returns a user by the provided id
 */
-func (a *Auth) GetUserByID (userID int) *models.User {
-	relationshipQuery := `SELECT * FROM users WHERE id = ?;`
-
-	row := a.db.QueryRow(relationshipQuery, userID)
-	user := new(models.User)
-	row.Scan(&user.ID, &user.Email, &user.Password, &user.Name, &user.Gender, &user.DateOfBirth, &user.Latitude, &user.Longitude)
-	return user
+func (a *Auth) GetUserByID (userID int) (*models.User, error) {
+	return a.users.GetByID(userID)
 }
 
 /*
-Create - add a new user row in the db with the random data generated
+Create - add a new user, callers are expected to have validated it
+only the hash of the password is stored, the returned user still carries the plaintext so it can be shown once
 */
 func (a *Auth) Create(user models.User) (models.User, error) {
-	result, err := a.db.Exec("INSERT INTO users (email, password, name, gender, date_of_birth, latitude, longitude) VALUES (?,?,?,?,?,?,?)",
-		user.Email, user.Password, user.Name, user.Gender, user.DateOfBirth, user.Latitude, user.Longitude)
+	plaintext := user.Password
+	if user.DistanceUnit == "" {
+		user.DistanceUnit = models.Kilometres
+	}
+	if user.Gender == "" {
+		user.Gender = models.NotSpecified
+	}
+
+	hash, err := passwords.Hash(plaintext)
 	if err != nil {
 		return user, err
 	}
+	user.Password = hash
 
-	id, err := result.LastInsertId()
+	user, err = a.users.Create(user)
+	user.Password = plaintext
 	if err != nil {
 		return user, err
 	}
 
-	user.ID = int(id)
-	user.Age = int(math.Floor(time.Since(user.DateOfBirth).Hours() / 24 / 365))
+	user.Age = ageFrom(user.DateOfBirth)
+	// interests, prompts and photos are added once the account exists
+	user.Interests = []models.Interest{}
+	user.Prompts = []models.PromptAnswer{}
+	user.Photos = []models.Photo{}
 
 	return user, nil
 }
 
 /*
-Login - check the provided credentials. If they are correct generate a token
+Register - validates the details a user signed up with and creates their account
 */
-func (a *Auth) Login(email, password string) (string, error) {
-	userQuery := `SELECT id, email, password FROM users 
-WHERE email = ? AND password = ?`
-	row := a.db.QueryRow(userQuery, email, password)
-	user := new(models.User)
-	err := row.Scan(&user.ID, &user.Email, &user.Password)
+func (a *Auth) Register(user models.User) (models.User, error) {
+	user.Email = strings.ToLower(strings.TrimSpace(user.Email))
+	user.Name = strings.TrimSpace(user.Name)
+	user.GenderDescription = strings.TrimSpace(user.GenderDescription)
+	user.Bio = strings.TrimSpace(user.Bio)
+	user.JobTitle = strings.TrimSpace(user.JobTitle)
+	user.School = strings.TrimSpace(user.School)
+
+	err := validateRegistration(user)
 	if err != nil {
-		return "", err
+		return user, err
+	}
+
+	return a.Create(user)
+}
+
+const (
+	minimumAge        = 18
+	maximumAge        = 120
+	minPasswordLength = 10
+	maxPasswordLength = 128
+	maxNameLength     = 255
+	maxEmailLength    = 254
+)
+
+func validateRegistration(user models.User) error {
+	v := &ValidationError{}
+
+	if len(user.Email) > maxEmailLength {
+		v.Add("email", "must be at most 254 characters")
+	}
+	address, err := mail.ParseAddress(user.Email)
+	if err != nil || address.Address != user.Email {
+		v.Add("email", "must be a valid email address")
 	}
 
-	expirationTime := time.Now().Add(60 * time.Minute)
+	validatePassword(v, user.Password)
 
-	claims := &models.Claims{
-		UserID: user.ID,
-		RegisteredClaims: jwtv4.RegisteredClaims{
-			// In JWT, the expiry time is expressed as unix milliseconds
-			ExpiresAt: jwtv4.NewNumericDate(expirationTime),
-		},
+	validateName(v, user.Name)
+
+	if user.DateOfBirth.IsZero() {
+		v.Add("date_of_birth", "is required")
+	} else if user.DateOfBirth.After(time.Now().AddDate(-minimumAge, 0, 0)) {
+		v.Add("date_of_birth", "you must be at least 18 to register")
+	} else if user.DateOfBirth.Before(time.Now().AddDate(-maximumAge, 0, 0)) {
+		v.Add("date_of_birth", "must be a real date of birth")
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	// Create the JWT string
-	tokenString, err := token.SignedString(models.JWT_KEY)
-	if err != nil {
-		return "", err
+	validateLocation(v, models.Location{Latitude: user.Latitude, Longitude: user.Longitude})
+	validateDetails(v, user.Profile)
+
+	if user.DistanceUnit != "" && !user.DistanceUnit.Valid() {
+		v.Add("distance_unit", "must be km or mi")
+	}
+
+	return v.OrNil()
+}
+
+func validateName(v *ValidationError, name string) {
+	if name == "" {
+		v.Add("name", "is required")
+	}
+	if utf8.RuneCountInString(name) > maxNameLength {
+		v.Add("name", "must be at most 255 characters")
 	}
-	return tokenString, err
 }
 
 /*
-GetUserFromRequest - read the header token to get the user's id
+validatePassword - passwords must be a reasonable length and use at least three of
+lower case, upper case, digits and symbols
 */
-func (a *Auth) GetUserFromRequest (c echo.Context) int {
-	token := c.Get("token")
-	if token == nil {
-		return -1
-	}
-	user := token.(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	if val, ok := claims["user_id"]; ok {
-		floatUserId := val.(float64)
-		return int(floatUserId)
-	}
-	return -1
+func validatePassword(v *ValidationError, password string) {
+	length := utf8.RuneCountInString(password)
+	if length < minPasswordLength {
+		v.Add("password", "must be at least 10 characters")
+		return
+	}
+	if length > maxPasswordLength {
+		v.Add("password", "must be at most 128 characters")
+		return
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	classes := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			classes++
+		}
+	}
+	if classes < 3 {
+		v.Add("password", "must use at least three of lower case letters, upper case letters, digits and symbols")
+	}
 }
 
 /*
-UpdateUserLikabilityScore - increasing the likability for the provided user by the modifier
+Authenticate - check the provided credentials and return the user they belong to
+a successful login with a password stored in an outdated format upgrades it to the current hash.
+Passwords still stored in plaintext are only accepted while the config allows them, see config.AcceptsPlaintextPasswords
 */
-func (a *Auth) UpdateUserLikabilityScore(userID, modifier int) error {
-	_, err := a.db.Exec("UPDATE users set likability = likability + (?) WHERE id = ? ", modifier, userID)
+func (a *Auth) Authenticate(email, password string) (*models.User, error) {
+	user, err := a.users.GetByEmail(email)
+	if errors.Is(err, repositories.ErrNotFound) {
+		verifyPassword(password, dummyHash)
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	match, needsRehash, err := verifyPassword(password, user.Password)
+	if errors.Is(err, passwords.ErrPlaintext) {
+		if !a.cfg.AcceptsPlaintextPasswords() {
+			// the row is hashed by UpgradeLegacyPasswords, until then it can't be used
+			verifyPassword(password, dummyHash)
+			return nil, ErrInvalidCredentials
+		}
+		match, needsRehash, err = passwords.VerifyPlaintext(password, user.Password), true, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !match {
+		return nil, ErrInvalidCredentials
+	}
+
+	if needsRehash {
+		err = a.rehash(user.ID, password)
+		if err != nil {
+			// the user has proven who they are, a failed upgrade shouldn't stop them logging in
+			log.Error(err)
+		}
+	}
+
+	return user, nil
+}
+
+func (a *Auth) rehash(userID int, password string) error {
+	hash, err := passwords.Hash(password)
 	if err != nil {
 		return err
 	}
 
-	return nil
-}
\ No newline at end of file
+	return a.users.UpdatePassword(userID, hash)
+}
+
+/*
+UpgradeLegacyPasswords - hashes every password still stored in plaintext from before passwords were hashed
+safe to run repeatedly and alongside logins, rows that are already hashed are skipped
+*/
+func (a *Auth) UpgradeLegacyPasswords() error {
+	const batchSize = 100
+
+	afterID := 0
+	for {
+		users, err := a.users.GetPasswordsAfter(afterID, batchSize)
+		if err != nil {
+			return err
+		}
+		if len(users) == 0 {
+			return nil
+		}
+
+		for _, user := range users {
+			afterID = user.ID
+			if passwords.IsHashed(user.Password) {
+				continue
+			}
+
+			err = a.rehash(user.ID, user.Password)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package interactors
+
+import (
+	"dating-app/src/config"
+	"dating-app/src/models"
+	"dating-app/src/passwords"
+	"dating-app/src/repositories"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+const testPassword = "Correct horse 42"
+
+/*
+recordVerify - records every stored value a password is verified against until the test ends
+*/
+func recordVerify(t *testing.T) *[]string {
+	t.Helper()
+	var verified []string
+	original := verifyPassword
+	verifyPassword = func(password, stored string) (bool, bool, error) {
+		verified = append(verified, stored)
+		return original(password, stored)
+	}
+	t.Cleanup(func() { verifyPassword = original })
+	return &verified
+}
+
+func newTestAuth(plaintext *bool) (*Auth, *repositories.MemoryUserRepository) {
+	cfg := config.Default()
+	cfg.PlaintextPasswords = plaintext
+	users := repositories.NewMemoryUserRepository()
+	return NewAuth(&cfg, users), users
+}
+
+/*
+storeUser - a user whose password is stored exactly as given, as a legacy row would be
+*/
+func storeUser(t *testing.T, users *repositories.MemoryUserRepository, email, stored string) models.User {
+	t.Helper()
+	user, err := users.Create(models.User{Email: email, Password: stored, Profile: models.Profile{Name: "Test"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func storedPassword(t *testing.T, users *repositories.MemoryUserRepository, userID int) string {
+	t.Helper()
+	user, err := users.GetByID(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := users.GetByEmail(user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stored.Password
+}
+
+func TestAuthenticate(t *testing.T) {
+	auth, users := newTestAuth(nil)
+
+	user, err := auth.Create(models.User{Email: "alex@example.com", Password: testPassword, Profile: models.Profile{Name: "Alex"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Password != testPassword {
+		t.Error("Create didn't return the plaintext password")
+	}
+	hash := storedPassword(t, users, user.ID)
+	if !passwords.IsHashed(hash) {
+		t.Fatalf("stored password %q isn't hashed", hash)
+	}
+
+	authenticated, err := auth.Authenticate("alex@example.com", testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authenticated.ID != user.ID {
+		t.Errorf("authenticated %d, want %d", authenticated.ID, user.ID)
+	}
+	if storedPassword(t, users, user.ID) != hash {
+		t.Error("a current hash was rehashed")
+	}
+
+	_, err = auth.Authenticate("alex@example.com", "Correct horse 43")
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("wrong password err = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestAuthenticateUnknownEmail(t *testing.T) {
+	auth, users := newTestAuth(nil)
+	storeUser(t, users, "alex@example.com", testPassword)
+	verified := recordVerify(t)
+
+	_, err := auth.Authenticate("nobody@example.com", testPassword)
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("err = %v, want ErrInvalidCredentials", err)
+	}
+	if len(*verified) != 1 || (*verified)[0] != dummyHash {
+		t.Errorf("verified against %q, want only the dummy hash", *verified)
+	}
+}
+
+func TestAuthenticatePlaintext(t *testing.T) {
+	accept, reject := true, false
+
+	t.Run("rejected", func(t *testing.T) {
+		auth, users := newTestAuth(&reject)
+		user := storeUser(t, users, "alex@example.com", testPassword)
+		verified := recordVerify(t)
+
+		_, err := auth.Authenticate("alex@example.com", testPassword)
+		if !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("err = %v, want ErrInvalidCredentials", err)
+		}
+		// the plaintext row is left for UpgradeLegacyPasswords, and the time taken matches an unknown email
+		if storedPassword(t, users, user.ID) != testPassword {
+			t.Error("a rejected login rehashed the password")
+		}
+		if len(*verified) != 2 || (*verified)[1] != dummyHash {
+			t.Errorf("verified against %q, want the row then the dummy hash", *verified)
+		}
+	})
+
+	t.Run("accepted and rehashed", func(t *testing.T) {
+		auth, users := newTestAuth(&accept)
+		user := storeUser(t, users, "alex@example.com", testPassword)
+
+		_, err := auth.Authenticate("alex@example.com", "Correct horse 43")
+		if !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("wrong password err = %v, want ErrInvalidCredentials", err)
+		}
+		if storedPassword(t, users, user.ID) != testPassword {
+			t.Error("a failed login rehashed the password")
+		}
+
+		authenticated, err := auth.Authenticate("alex@example.com", testPassword)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if authenticated.ID != user.ID {
+			t.Errorf("authenticated %d, want %d", authenticated.ID, user.ID)
+		}
+
+		stored := storedPassword(t, users, user.ID)
+		if !passwords.IsHashed(stored) {
+			t.Fatalf("stored password %q wasn't rehashed", stored)
+		}
+		if match, needsRehash, err := passwords.Verify(testPassword, stored); !match || needsRehash || err != nil {
+			t.Errorf("rehashed Verify = %v, %v, %v", match, needsRehash, err)
+		}
+
+		// the password still works once it's hashed, whether or not plaintext is accepted
+		reject := false
+		auth.cfg.PlaintextPasswords = &reject
+		if _, err := auth.Authenticate("alex@example.com", testPassword); err != nil {
+			t.Errorf("login after rehash err = %v", err)
+		}
+	})
+}
+
+func TestUpgradeLegacyPasswords(t *testing.T) {
+	auth, users := newTestAuth(nil)
+
+	hash, err := passwords.Hash(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// more than one batch, mostly hashed rows as every rehash takes a while
+	legacy := map[int]string{}
+	hashed := map[int]string{}
+	for i := 0; i < 120; i++ {
+		email := fmt.Sprintf("user%d@example.com", i)
+		if i%20 != 0 && i != 119 {
+			hashed[storeUser(t, users, email, hash).ID] = hash
+			continue
+		}
+		password := fmt.Sprintf("Legacy password %d", i)
+		legacy[storeUser(t, users, email, password).ID] = password
+	}
+
+	if err := auth.UpgradeLegacyPasswords(); err != nil {
+		t.Fatal(err)
+	}
+
+	for id, stored := range hashed {
+		if storedPassword(t, users, id) != stored {
+			t.Errorf("user %d was already hashed but was rehashed", id)
+		}
+	}
+	upgraded := map[int]string{}
+	for id, password := range legacy {
+		stored := storedPassword(t, users, id)
+		if !passwords.IsHashed(stored) {
+			t.Fatalf("user %d is still plaintext", id)
+		}
+		upgraded[id] = stored
+		if match, _, err := passwords.Verify(password, stored); !match || err != nil {
+			t.Errorf("user %d's password doesn't verify after the upgrade: %v", id, err)
+		}
+	}
+
+	// running it again leaves every row alone
+	if err := auth.UpgradeLegacyPasswords(); err != nil {
+		t.Fatal(err)
+	}
+	for id, stored := range upgraded {
+		if storedPassword(t, users, id) != stored {
+			t.Errorf("user %d was rehashed by the second run", id)
+		}
+	}
+}
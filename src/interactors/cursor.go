@@ -0,0 +1,96 @@
+package interactors
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"dating-app/src/models"
+	"encoding/base64"
+	"encoding/json"
+)
+
+/*
+cursorSealer - encrypts profile cursors
+a distance cursor holds the exact distance to the last profile on the page, which clients must never see,
+and sealing them also means a client can't forge a position to start from
+*/
+type cursorSealer struct {
+	aead cipher.AEAD
+}
+
+func newCursorSealer(secret string) *cursorSealer {
+	// a 32 byte key always makes a valid AES-256 block, and GCM accepts any block cipher with a 16 byte block size
+	block, _ := aes.NewCipher(deriveKey(secret, "profile-cursor"))
+	aead, _ := cipher.NewGCM(block)
+
+	return &cursorSealer{
+		aead: aead,
+	}
+}
+
+func (s *cursorSealer) seal(cursor *models.ProfileCursor) (string, error) {
+	plaintext, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, s.aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(s.aead.Seal(nonce, nonce, plaintext, nil)), nil
+}
+
+func (s *cursorSealer) open(cursor string, sort models.ProfileSort) (*models.ProfileCursor, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(sealed) < s.aead.NonceSize() {
+		return nil, ErrInvalidCursor
+	}
+
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	plaintext, err := s.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	after := new(models.ProfileCursor)
+	err = json.Unmarshal(plaintext, after)
+	if err != nil || after.Sort != sort || after.ID < 1 {
+		return nil, ErrInvalidCursor
+	}
+	// the scores of these sorts depend on the time, the next page has to be sorted at the same one
+	if (sort == models.SortDesirability || sort == models.SortRanked) && after.RatedAt == nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return after, nil
+}
+
+/*
+encodeMatchCursor - match cursors hold nothing the user can't already see, and only ever page through their own matches,
+so they're encoded rather than sealed
+*/
+func encodeMatchCursor(cursor *models.MatchCursor) (string, error) {
+	plaintext, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(plaintext), nil
+}
+
+func decodeMatchCursor(cursor string) (*models.MatchCursor, error) {
+	plaintext, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	after := new(models.MatchCursor)
+	err = json.Unmarshal(plaintext, after)
+	if err != nil || after.UserID < 1 || after.MatchedAt.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+
+	return after, nil
+}
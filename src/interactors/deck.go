@@ -0,0 +1,242 @@
+package interactors
+
+import (
+	"dating-app/src/deck"
+	"dating-app/src/models"
+	"dating-app/src/repositories"
+	"encoding/json"
+	"github.com/labstack/gommon/log"
+	"sync"
+	"time"
+)
+
+/*
+deckSize - how many profiles are worked out ahead of time for each user, also how many of the nearest candidates
+are scored for the ranked deck
+*/
+const deckSize = 200
+
+/*
+deckRefreshAt - a new deck is generated in the background once there are fewer than this many profiles left in it
+*/
+const deckRefreshAt = 50
+
+/*
+decks - each user's discovery deck, the profiles their saved preferences would return, generated in the background and
+cached in the store so most requests don't have to run the profiles query.
+Decks are only ever a cache, the profiles in them are checked against everyone's current swipes, preferences and
+locations before they're shown
+*/
+type decks struct {
+	store    deck.Store
+	users    repositories.UserRepository
+	matches  repositories.MatchRepository
+	blur     *distanceBlur
+	mu       sync.Mutex
+	building map[int]bool
+}
+
+func newDecks(store deck.Store, users repositories.UserRepository, matches repositories.MatchRepository, blur *distanceBlur) *decks {
+	return &decks{
+		store:    store,
+		users:    users,
+		matches:  matches,
+		blur:     blur,
+		building: map[int]bool{},
+	}
+}
+
+/*
+get - the user's deck if it was generated for these preferences and location, otherwise nil and a new one is generated
+in the background. The store being unavailable is logged rather than returned, the profiles can still be queried
+*/
+func (d *decks) get(user *models.User, saved models.DiscoveryPreferences) *deck.Deck {
+	userDeck, err := d.store.Get(user.ID)
+	if err != nil {
+		log.Error(err)
+		return nil
+	}
+	if userDeck == nil || userDeck.Filters != deckFilters(user, saved) {
+		d.refresh(user.ID)
+		return nil
+	}
+	return userDeck
+}
+
+/*
+refresh - generates the user's deck in the background, unless it's already being generated
+*/
+func (d *decks) refresh(userID int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.building[userID] {
+		return
+	}
+	d.building[userID] = true
+
+	go func() {
+		err := d.build(userID)
+		if err != nil {
+			log.Error(err)
+		}
+
+		d.mu.Lock()
+		delete(d.building, userID)
+		d.mu.Unlock()
+	}()
+}
+
+/*
+build - runs the profiles query with the user's saved preferences and caches the first deckSize profiles
+*/
+func (d *decks) build(userID int) error {
+	user, err := d.users.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	saved, err := d.users.GetPreferences(userID)
+	if err != nil {
+		return err
+	}
+
+	preferences := saved.In(user.DistanceUnit)
+	opts := discoveryOpts(user, preferences, time.Now())
+	opts.Limit = deckSize
+	profiles, err := queryProfiles(d.matches, d.blur, userID, preferences, opts)
+	if err != nil {
+		return err
+	}
+
+	return d.store.Put(userID, &deck.Deck{
+		Sort:        opts.Sort,
+		Filters:     deckFilters(user, saved),
+		GeneratedAt: opts.Now,
+		Complete:    len(profiles) < deckSize,
+		Profiles:    profiles,
+	})
+}
+
+/*
+consume - takes the profile out of the user's deck once it can't be shown to them again
+*/
+func (d *decks) consume(userID, profileID int) {
+	_, err := d.store.Remove(userID, profileID)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+/*
+deckFilters - identifies what a deck was generated for, the user's saved preferences, location, age, gender and unit.
+A deck generated for anything else is out of date even if it wasn't invalidated, e.g. by another instance of the app
+*/
+func deckFilters(user *models.User, saved models.DiscoveryPreferences) string {
+	filters, _ := json.Marshal(struct {
+		Preferences models.DiscoveryPreferences
+		Origin      models.Location
+		Age         int
+		Gender      models.GenderType
+		Unit        models.DistanceUnit
+	}{
+		Preferences: saved,
+		Origin:      models.Location{Latitude: user.Latitude, Longitude: user.Longitude},
+		Age:         ageFrom(user.DateOfBirth),
+		Gender:      user.Gender,
+		Unit:        user.DistanceUnit,
+	})
+	return string(filters)
+}
+
+/*
+usesSavedPreferences - whether the request is for the user's saved preferences, only those are kept in a deck
+*/
+func (r ProfilesRequest) usesSavedPreferences(saved models.DiscoveryPreferences) bool {
+	return r.AgeMin == nil && r.AgeMax == nil && len(r.ShowMe) == 0 && len(r.Genders) == 0 && r.MaxDistance == nil &&
+		(r.Sort == nil || *r.Sort == saved.Sort)
+}
+
+/*
+fromDeck - the profiles after the cursor from the user's deck that would still be returned by the profiles query.
+Only the user's own changes throw their deck away, so the query is run again for just the profiles in it, which leaves out
+anyone either of them has swiped since, and candidates whose preferences no longer include the user or who have moved
+out of range. Those are taken out of the deck, they keep the order and values the deck was sorted on.
+Returns false when the deck can't be used - there isn't one for the saved preferences, the pages so far came from
+a different deck, or there aren't enough profiles left in it - and the profiles should be queried instead.
+The deck's desirability was decayed to when it was generated, so opts.Now is moved back to it
+*/
+func (m *Match) fromDeck(user *models.User, saved models.DiscoveryPreferences, opts *models.FilterOpts) ([]*models.Profile, bool, error) {
+	userDeck := m.decks.get(user, saved)
+	if userDeck == nil || userDeck.Sort != opts.Sort {
+		return nil, false, nil
+	}
+	if opts.After != nil && opts.After.RatedAt != nil && !opts.After.RatedAt.Equal(userDeck.GeneratedAt) {
+		return nil, false, nil
+	}
+
+	var profiles []*models.Profile
+	var profileIDs []int
+	for _, profile := range userDeck.Profiles {
+		if opts.After == nil || opts.After.Precedes(profile) {
+			profiles = append(profiles, profile)
+			profileIDs = append(profileIDs, profile.ID)
+		}
+	}
+
+	stillShown, err := m.stillShown(user, saved, *opts, profileIDs)
+	if err != nil {
+		return nil, false, err
+	}
+	var remaining []*models.Profile
+	for _, profile := range profiles {
+		if !stillShown[profile.ID] {
+			m.decks.consume(user.ID, profile.ID)
+			continue
+		}
+		remaining = append(remaining, profile)
+	}
+
+	if !userDeck.Complete {
+		// paging past the end of the deck doesn't need a new one, swiping through it does
+		if len(userDeck.Profiles)-(len(profiles)-len(remaining)) < deckRefreshAt {
+			m.decks.refresh(user.ID)
+		}
+		// the ranked deck is the pool that's scored, the other sorts need a full page
+		needed := opts.Limit
+		if opts.Sort == models.SortRanked {
+			needed = deckRefreshAt
+		}
+		if len(remaining) < needed {
+			return nil, false, nil
+		}
+	}
+
+	if opts.Sort != models.SortRanked && len(remaining) > opts.Limit {
+		remaining = remaining[:opts.Limit]
+	}
+	opts.Now = userDeck.GeneratedAt
+
+	return remaining, true, nil
+}
+
+/*
+stillShown - which of the profiles the profiles query returns for the user now, changes since the deck was generated
+included, e.g. swipes made while it was being generated
+*/
+func (m *Match) stillShown(user *models.User, saved models.DiscoveryPreferences, opts models.FilterOpts, profileIDs []int) (map[int]bool, error) {
+	shown := make(map[int]bool, len(profileIDs))
+	if len(profileIDs) == 0 {
+		return shown, nil
+	}
+
+	opts.ProfileIDs = profileIDs
+	opts.After = nil
+	opts.Limit = 0
+	profiles, err := queryProfiles(m.matches, m.blur, user.ID, saved.In(user.DistanceUnit), opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, profile := range profiles {
+		shown[profile.ID] = true
+	}
+	return shown, nil
+}
@@ -0,0 +1,195 @@
+package interactors
+
+import (
+	"dating-app/src/deck"
+	"dating-app/src/models"
+	"github.com/alicebob/miniredis/v2"
+	"testing"
+	"time"
+)
+
+/*
+deckStores - the stores decks are tested with, each test gets a new one
+*/
+var deckStores = map[string]func(t *testing.T) deck.Store{
+	"lru": func(t *testing.T) deck.Store {
+		return deck.NewLRU(100, time.Hour)
+	},
+	"redis": func(t *testing.T) deck.Store {
+		store, err := deck.NewRedis("redis://"+miniredis.RunT(t).Addr(), time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	},
+}
+
+/*
+deckApp - a viewer who wants to see men within 50 km and count men near them, with the viewer's deck built
+*/
+func deckApp(t *testing.T, store deck.Store, count int) (*testApp, models.User, []models.User) {
+	t.Helper()
+	app := newTestApp(t, store)
+	viewer := app.createUser(t, models.Female, 30, 0)
+	maxDistance := 50.0
+	_, err := app.profile.SavePreferences(viewer.ID, models.DiscoveryPreferences{
+		ShowMe:      []models.ShowMe{models.ShowMen},
+		MaxDistance: &maxDistance,
+		Sort:        models.SortDistance,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var candidates []models.User
+	for i := 0; i < count; i++ {
+		candidates = append(candidates, app.createUser(t, models.Male, 30, float64(i+1)))
+	}
+	if err = app.match.decks.build(viewer.ID); err != nil {
+		t.Fatal(err)
+	}
+	return app, viewer, candidates
+}
+
+/*
+shownFromDeck - every profile the user is shown, a page of limit at a time, failing if any page didn't come from their deck
+*/
+func shownFromDeck(t *testing.T, app *testApp, userID, limit int) map[int]bool {
+	t.Helper()
+	user, err := app.users.GetByID(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved, err := app.users.GetPreferences(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userDeck, err := app.decks.Get(userID)
+	if err != nil || userDeck == nil {
+		t.Fatalf("user %d has no deck: %v", userID, err)
+	}
+
+	shown := map[int]bool{}
+	request := ProfilesRequest{Limit: limit}
+	for page := 0; page < 100; page++ {
+		opts := discoveryOpts(user, saved.In(user.DistanceUnit), time.Now())
+		opts.Limit = limit + 1
+		if request.Cursor != "" {
+			opts.After, err = app.match.cursors.open(request.Cursor, opts.Sort)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, ok, err := app.match.fromDeck(user, saved, &opts); err != nil || !ok {
+			t.Fatalf("page %d didn't come from the deck: %v", page, err)
+		}
+
+		profiles, err := app.match.GetProfilesForUser(userID, request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, profile := range profiles.Profiles {
+			if shown[profile.ID] {
+				t.Errorf("profile %d was shown twice", profile.ID)
+			}
+			shown[profile.ID] = true
+		}
+		if profiles.NextCursor == nil {
+			return shown
+		}
+		request.Cursor = *profiles.NextCursor
+	}
+	t.Fatal("too many pages")
+	return nil
+}
+
+func TestSwipedProfilesNeverComeBackFromTheDeck(t *testing.T) {
+	for name, newStore := range deckStores {
+		t.Run(name, func(t *testing.T) {
+			app, viewer, candidates := deckApp(t, newStore(t), 10)
+
+			if shown := shownFromDeck(t, app, viewer.ID, 3); len(shown) != len(candidates) {
+				t.Fatalf("shown %d profiles from the deck, want %d", len(shown), len(candidates))
+			}
+
+			// a yes and a no through the interactor, which takes them out of the deck
+			if _, err := app.match.Swipe(viewer.ID, candidates[0].ID, models.Yes); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := app.match.Swipe(viewer.ID, candidates[1].ID, models.No); err != nil {
+				t.Fatal(err)
+			}
+			// a swipe the deck never heard about, e.g. made by another instance while the deck was being built
+			_, err := app.matches.Swipe(viewer.ID, candidates[2].ID, func(relationship *models.Match) (int, error) {
+				return applySwipe(relationship, viewer.ID, models.Yes, time.Now())
+			}, func(swiped, swiper models.Rating) models.Rating {
+				return swiped
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// a no from the other side
+			if _, err = app.match.Swipe(candidates[3].ID, viewer.ID, models.No); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, limit := range []int{1, 3, 20} {
+				shown := shownFromDeck(t, app, viewer.ID, limit)
+				for _, swiped := range candidates[:4] {
+					if shown[swiped.ID] {
+						t.Errorf("limit %d: swiped profile %d came back", limit, swiped.ID)
+					}
+				}
+				if len(shown) != len(candidates)-4 {
+					t.Errorf("limit %d: shown %d profiles, want the %d that weren't swiped", limit, len(shown), len(candidates)-4)
+				}
+			}
+
+			userDeck, err := app.decks.Get(viewer.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, profile := range userDeck.Profiles {
+				for _, swiped := range candidates[:4] {
+					if profile.ID == swiped.ID {
+						t.Errorf("swiped profile %d is still in the deck", swiped.ID)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDeckRechecksCandidates(t *testing.T) {
+	for name, newStore := range deckStores {
+		t.Run(name, func(t *testing.T) {
+			app, viewer, candidates := deckApp(t, newStore(t), 6)
+
+			// only wants to see men now
+			if _, err := app.profile.SavePreferences(candidates[0].ID, models.DiscoveryPreferences{ShowMe: []models.ShowMe{models.ShowMen}}); err != nil {
+				t.Fatal(err)
+			}
+			// only wants to see people under 25
+			ageMax := 25
+			if _, err := app.profile.SavePreferences(candidates[1].ID, models.DiscoveryPreferences{AgeMax: &ageMax}); err != nil {
+				t.Fatal(err)
+			}
+			// only wants to see people within 1 km, they're 3 km away
+			maxDistance := 1.0
+			if _, err := app.profile.SavePreferences(candidates[2].ID, models.DiscoveryPreferences{MaxDistance: &maxDistance}); err != nil {
+				t.Fatal(err)
+			}
+			// moved out of the viewer's 50 km
+			if _, err := app.profile.UpdateLocation(candidates[3].ID, models.Location{Latitude: north(200), Longitude: origin.Longitude}); err != nil {
+				t.Fatal(err)
+			}
+
+			shown := shownFromDeck(t, app, viewer.ID, 2)
+			for i, candidate := range candidates {
+				if want := i >= 4; shown[candidate.ID] != want {
+					t.Errorf("candidate %d shown = %v, want %v", i, shown[candidate.ID], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package interactors
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"dating-app/src/models"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+/*
+distanceBlur - turns exact distances into the approximate ones users are shown
+Jitter is derived from the secret, the two users and the viewer's rough position. Asking again from the same place
+gives the same answer, so averaging repeated requests doesn't reveal the exact distance
+*/
+type distanceBlur struct {
+	key []byte
+}
+
+func newDistanceBlur(secret string) *distanceBlur {
+	return &distanceBlur{
+		key: deriveKey(secret, "distance-jitter"),
+	}
+}
+
+/*
+approximate - buckets the distance in miles between the viewer and a profile into the viewer's unit
+distances under 1 are shown as less than 1, further away the buckets get wider
+*/
+func (b *distanceBlur) approximate(viewerID int, origin models.Location, profileID int, miles float64, unit models.DistanceUnit) *models.ApproximateDistance {
+	distance := unit.FromMiles(miles)
+	step := bucketSize(distance)
+	jittered := distance + (b.jitter(viewerID, origin, profileID)-0.5)*step
+
+	approximate := &models.ApproximateDistance{Unit: unit}
+	if jittered < 1 {
+		approximate.Value = 1
+		approximate.LessThan = true
+		approximate.Label = fmt.Sprintf("less than 1 %s", unit)
+		return approximate
+	}
+
+	approximate.Value = math.Max(1, math.Round(jittered/step)*step)
+	approximate.Label = fmt.Sprintf("%s %s", strconv.FormatFloat(approximate.Value, 'f', -1, 64), unit)
+
+	return approximate
+}
+
+/*
+within - whether the viewer is shown the profile as at most maxDistance away, in unit rounded to a whole number.
+Profiles are filtered on the distance users see rather than the exact one, otherwise moving max_distance a little at a time
+would show exactly where it crosses the profile's distance
+*/
+func (b *distanceBlur) within(viewerID int, origin models.Location, profileID int, miles, maxDistance float64, unit models.DistanceUnit) bool {
+	return b.approximate(viewerID, origin, profileID, miles, unit).Value <= wholeDistance(maxDistance)
+}
+
+/*
+searchRadius - the furthest away a profile can be, in the same unit, and still be shown as within maxDistance.
+Jitter and rounding move a distance by less than its bucket, and a distance is never more than twice maxDistance
+(or maxDistance + 1) before its bucket is wider than the gap
+*/
+func searchRadius(maxDistance float64) float64 {
+	maxDistance = wholeDistance(maxDistance)
+	return maxDistance + bucketSize(math.Max(2*maxDistance, maxDistance+1))
+}
+
+/*
+wholeDistance - max distances are whole numbers of the unit, at least 1, the distances they're compared with are never finer
+*/
+func wholeDistance(distance float64) float64 {
+	return math.Max(1, math.Round(distance))
+}
+
+func bucketSize(distance float64) float64 {
+	switch {
+	case distance < 10:
+		return 1
+	case distance < 50:
+		return 5
+	case distance < 200:
+		return 10
+	default:
+		return 50
+	}
+}
+
+/*
+jitter - a value in [0, 1) that is fixed for a viewer and profile while the viewer stays within roughly 1km
+*/
+func (b *distanceBlur) jitter(viewerID int, origin models.Location, profileID int) float64 {
+	mac := hmac.New(sha256.New, b.key)
+	fmt.Fprintf(mac, "%d:%d:%.2f:%.2f", viewerID, profileID, origin.Latitude, origin.Longitude)
+	sum := mac.Sum(nil)
+
+	return float64(binary.BigEndian.Uint64(sum[:8])>>11) / (1 << 53)
+}
+
+/*
+deriveKey - a separate key for each use of the privacy secret
+*/
+func deriveKey(secret, purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,58 @@
+package interactors
+
+import (
+	"dating-app/src/models"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestSearchRadiusCoversWithin(t *testing.T) {
+	blur := newDistanceBlur("test secret")
+	random := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 100000; i++ {
+		maxDistance := random.Float64() * 300
+		distance := random.Float64() * 1000
+		if blur.within(1, origin, i, models.Kilometres.ToMiles(distance), maxDistance, models.Kilometres) && distance > searchRadius(maxDistance) {
+			t.Fatalf("%.3f km is shown within %.3f km but is outside the search radius %.3f km", distance, maxDistance, searchRadius(maxDistance))
+		}
+	}
+}
+
+func TestGetProfilesForUserMaxDistance(t *testing.T) {
+	app := newTestApp(t, nil)
+	viewer := app.createUser(t, models.Female, 30, 0)
+	for km := 0.25; km < 30; km += 0.25 {
+		app.createUser(t, models.Male, 30, km)
+	}
+
+	profilesWithin := func(maxDistance float64) []int {
+		t.Helper()
+		sort := models.SortDistance
+		page, err := app.match.GetProfilesForUser(viewer.ID, ProfilesRequest{MaxDistance: &maxDistance, Sort: &sort, Limit: MaxPageSize})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.NextCursor != nil {
+			t.Fatalf("max distance %v didn't fit on a page", maxDistance)
+		}
+		for _, profile := range page.Profiles {
+			if profile.ApproximateDistance.Value > math.Round(maxDistance) {
+				t.Errorf("max distance %v returned a profile shown %v away", maxDistance, profile.ApproximateDistance.Label)
+			}
+		}
+		return ids(page.Profiles)
+	}
+
+	ten := profilesWithin(10)
+	if len(ten) == 0 {
+		t.Fatal("no profiles within 10 km")
+	}
+	for _, maxDistance := range []float64{9.6, 10.01, 10.3, 10.49} {
+		if got := profilesWithin(maxDistance); !reflect.DeepEqual(got, ten) {
+			t.Errorf("max distance %v returned %v, want the same as 10: %v", maxDistance, got, ten)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package interactors
+
+import (
+	"dating-app/src/config"
+	"dating-app/src/deck"
+	"dating-app/src/models"
+	"dating-app/src/repositories"
+	"dating-app/src/storage"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// origin - where test users are, others are placed due north of it
+var origin = models.Location{Latitude: 51.5, Longitude: -0.12}
+
+/*
+testApp - the interactors wired to memory repositories, an in-process deck store and blobs in a temporary directory
+*/
+type testApp struct {
+	users   *repositories.MemoryUserRepository
+	matches *repositories.MemoryMatchRepository
+	decks   deck.Store
+	match   *Match
+	profile *Profile
+}
+
+func newTestApp(t *testing.T, decks deck.Store) *testApp {
+	t.Helper()
+	blobs, err := storage.NewLocal(t.TempDir(), "http://localhost/media")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decks == nil {
+		decks = deck.NewLRU(100, time.Hour)
+	}
+
+	cfg := config.Default()
+	cfg.PrivacySecret = "test secret"
+	users := repositories.NewMemoryUserRepository()
+	matches := repositories.NewMemoryMatchRepository(users)
+	profiles := repositories.NewMemoryProfileRepository(nil, nil)
+	photos := repositories.NewMemoryPhotoRepository()
+	return &testApp{
+		users:   users,
+		matches: matches,
+		decks:   decks,
+		match:   NewMatch(&cfg, users, matches, profiles, photos, blobs, decks),
+		profile: NewProfile(users, profiles, photos, blobs, decks),
+	}
+}
+
+/*
+createUser - stores a user of the gender and age km due north of origin, who wants to see everyone
+*/
+func (a *testApp) createUser(t *testing.T, gender models.GenderType, age int, km float64) models.User {
+	t.Helper()
+	user, err := a.users.Create(models.User{
+		Email:        fmt.Sprintf("user%d@example.com", time.Now().UnixNano()),
+		DistanceUnit: models.Kilometres,
+		Profile: models.Profile{
+			Name:        "Test",
+			Gender:      gender,
+			DateOfBirth: time.Now().UTC().AddDate(-age, 0, -1),
+			Latitude:    north(km),
+			Longitude:   origin.Longitude,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+/*
+north - the latitude km due north of origin
+*/
+func north(km float64) float64 {
+	return origin.Latitude + km/111.195
+}
+
+func ids(profiles []*models.Profile) []int {
+	ids := make([]int, len(profiles))
+	for i, profile := range profiles {
+		ids[i] = profile.ID
+	}
+	return ids
+}
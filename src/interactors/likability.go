@@ -0,0 +1,46 @@
+package interactors
+
+import (
+	"dating-app/src/repositories"
+	"github.com/labstack/gommon/log"
+)
+
+/*
+Likability - upkeep of the likability scores, each user's score is cached on their row and recorded in full in the ledger
+*/
+type Likability struct {
+	likability repositories.LikabilityRepository
+}
+
+func NewLikability(likability repositories.LikabilityRepository) *Likability {
+	return &Likability{
+		likability: likability,
+	}
+}
+
+/*
+Repair - resets every cached score that doesn't match the user's ledger to the ledger's total and returns how many were reset.
+The ledger is always right, a cached score only drifts if it's changed outside of a swipe.
+Safe to run repeatedly and alongside swipes, each user is locked while they're checked
+*/
+func (l *Likability) Repair() (int, error) {
+	const batchSize = 100
+
+	repaired := 0
+	afterID := 0
+	for {
+		lastID, repairs, err := l.likability.Repair(afterID, batchSize)
+		if err != nil {
+			return repaired, err
+		}
+		for _, repair := range repairs {
+			log.Warnf("likability for user %d was %d, reset to %d from the ledger", repair.UserID, repair.Cached, repair.Ledger)
+		}
+		repaired += len(repairs)
+
+		if lastID == 0 {
+			return repaired, nil
+		}
+		afterID = lastID
+	}
+}
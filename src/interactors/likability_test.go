@@ -0,0 +1,103 @@
+package interactors
+
+import (
+	"dating-app/src/models"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+/*
+TestSwipeLikability - changing a swipe moves the swiped user's likability to what the latest swipe is worth,
+repeating one is rejected and changes nothing
+*/
+func TestSwipeLikability(t *testing.T) {
+	app := newTestApp(t, nil)
+	swiper := app.createUser(t, models.Female, 30, 0)
+	swiped := app.createUser(t, models.Male, 30, 1)
+
+	likability := func() int {
+		t.Helper()
+		user, err := app.users.GetByID(swiped.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *user.LikabilityScore
+	}
+
+	steps := []struct {
+		preference models.Preference
+		wantErr    error
+		want       int
+	}{
+		{preference: models.Yes, want: 1},
+		{preference: models.Yes, wantErr: ErrAlreadySwiped, want: 1},
+		{preference: models.No, want: -1},
+		{preference: models.No, wantErr: ErrAlreadySwiped, want: -1},
+		{preference: models.Yes, want: 1},
+		{preference: models.Yes, wantErr: ErrAlreadySwiped, want: 1},
+	}
+	for i, step := range steps {
+		_, err := app.match.Swipe(swiper.ID, swiped.ID, step.preference)
+		if !errors.Is(err, step.wantErr) {
+			t.Fatalf("swipe %d (%s) err = %v, want %v", i+1, step.preference, err, step.wantErr)
+		}
+		if got := likability(); got != step.want {
+			t.Errorf("after swipe %d (%s) likability = %d, want %d", i+1, step.preference, got, step.want)
+		}
+	}
+}
+
+/*
+batchedLikability - a LikabilityRepository over fixed batches, recording where each one was asked to start
+*/
+type batchedLikability struct {
+	batches [][]models.LikabilityRepair
+	lastIDs []int
+	afters  []int
+	err     error
+}
+
+func (l *batchedLikability) Repair(afterID, limit int) (int, []models.LikabilityRepair, error) {
+	l.afters = append(l.afters, afterID)
+	call := len(l.afters) - 1
+	if call >= len(l.batches) {
+		return 0, nil, l.err
+	}
+	return l.lastIDs[call], l.batches[call], nil
+}
+
+/*
+TestLikabilityRepair - the -repair-likability flag, every batch is repaired in turn and the resets are counted
+*/
+func TestLikabilityRepair(t *testing.T) {
+	repository := &batchedLikability{
+		batches: [][]models.LikabilityRepair{
+			{{UserID: 3, Cached: 5, Ledger: 2}},
+			nil,
+			{{UserID: 201, Cached: 0, Ledger: -1}, {UserID: 250, Cached: 1, Ledger: 4}},
+		},
+		lastIDs: []int{100, 200, 250},
+	}
+
+	repaired, err := NewLikability(repository).Repair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repaired != 3 {
+		t.Errorf("repaired %d, want 3", repaired)
+	}
+	if want := []int{0, 100, 200, 250}; !reflect.DeepEqual(repository.afters, want) {
+		t.Errorf("batches started after %v, want %v", repository.afters, want)
+	}
+
+	failing := &batchedLikability{
+		batches: [][]models.LikabilityRepair{{{UserID: 3, Cached: 5, Ledger: 2}}},
+		lastIDs: []int{100},
+		err:     errors.New("connection lost"),
+	}
+	repaired, err = NewLikability(failing).Repair()
+	if err == nil || repaired != 1 {
+		t.Errorf("Repair = %d, %v, want the 1 repaired before the error", repaired, err)
+	}
+}
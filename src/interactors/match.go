@@ -1,126 +1,526 @@
 package interactors
 
 import (
-	"database/sql"
+	"dating-app/src/config"
+	"dating-app/src/deck"
+	"dating-app/src/geo"
 	"dating-app/src/models"
+	"dating-app/src/ranking"
+	"dating-app/src/rating"
+	"dating-app/src/repositories"
+	"dating-app/src/storage"
 	"errors"
 	"fmt"
 	"math"
 	"time"
 )
 
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+var ErrInvalidDistanceUnit = errors.New("distance unit must be km or mi")
+
 type Match struct {
-	db *sql.DB
+	users    repositories.UserRepository
+	matches  repositories.MatchRepository
+	profiles repositories.ProfileRepository
+	photos   *Photos
+	blur     *distanceBlur
+	cursors  *cursorSealer
+	rankers  *ranking.Experiments
+	decks    *decks
+	now      func() time.Time
 }
 
-func NewMatch(db *sql.DB) *Match {
+func NewMatch(cfg *config.Config, users repositories.UserRepository, matches repositories.MatchRepository, profiles repositories.ProfileRepository,
+	photos repositories.PhotoRepository, blobs storage.Blobs, decks deck.Store) *Match {
+	blur := newDistanceBlur(cfg.PrivacySecret)
 	return &Match{
-		db: db,
+		users:    users,
+		matches:  matches,
+		profiles: profiles,
+		photos:   NewPhotos(photos, blobs),
+		blur:     blur,
+		cursors:  newCursorSealer(cfg.PrivacySecret),
+		rankers:  ranking.NewExperiments(cfg.Ranking),
+		decks:    newDecks(decks, users, matches, blur),
+		now:      time.Now,
 	}
 }
 
-type FilterOpts struct {
-	AgeMin *time.Time
-	AgeMax *time.Time
-	Gender models.GenderType
-	SortRecommended bool
-}
 /*
-GetProfilesForUser - gets the profiles for a requesting user within filtering options
-convert date of birth to age
+ProfilesRequest - a request for a page of profiles
+the filters replace the user's saved discovery preferences for this request only, nil filters and an empty ShowMe keep
+the saved ones. MaxDistance is in Unit, which defaults to the user's own unit.
+Genders narrows the request down to exactly those genders, in place of the show me groups.
+Debug keeps each profile's explanation of its rank when the deck is ranked
 */
-func (m *Match) GetProfilesForUser (userID int, opts FilterOpts) ([]*models.Profile, error) {
-	profileQuery := `SELECT id, name, gender, date_of_birth, latitude, longitude, likability FROM users 
-WHERE
-id NOT IN (SELECT match_user_id FROM matches WHERE user_id = ?)
-AND id NOT IN (SELECT user_id FROM matches WHERE match_user_id = ? AND state != 0)
-AND id != ?`
+type ProfilesRequest struct {
+	AgeMin      *int
+	AgeMax      *int
+	ShowMe      []models.ShowMe
+	Genders     []models.GenderType
+	MaxDistance *float64
+	Sort        *models.ProfileSort
+	Unit        models.DistanceUnit
+	Cursor      string
+	Limit       int
+	Debug       bool
+}
 
-	if !opts.AgeMin.IsZero() {
-		profileQuery += fmt.Sprintf(" AND date_of_birth < '%s'", opts.AgeMin)
+/*
+GetProfilesForUser - gets a page of profiles for a requesting user, filtered by their discovery preferences
+convert date of birth to age, distances are measured from the requesting user's location.
+Exact distances are replaced with approximate ones before the page is returned, along with each profile's interests, prompts and photos.
+Requests for the saved preferences are served from the user's deck while it has enough profiles left.
+Returns a ValidationError if the filters are invalid and ErrInvalidCursor if the cursor wasn't issued for the same sort
+*/
+func (m *Match) GetProfilesForUser (userID int, request ProfilesRequest) (*models.ProfilePage, error) {
+	requestingUser, err := m.users.GetByID(userID)
+	if err != nil {
+		return nil, err
 	}
 
-	if !opts.AgeMax.IsZero() {
-		profileQuery += fmt.Sprintf(" AND date_of_birth > '%s'", opts.AgeMax)
+	limit := request.Limit
+	if limit < 1 || limit > MaxPageSize {
+		limit = DefaultPageSize
 	}
 
-	if opts.Gender != models.NotSpecified {
-		profileQuery += fmt.Sprintf(" AND gender = %d", opts.Gender)
+	unit := request.Unit
+	if unit == "" {
+		unit = requestingUser.DistanceUnit
 	}
-
-	if opts.SortRecommended {
-		profileQuery += " ORDER BY likability DESC"
+	if !unit.Valid() {
+		return nil, ErrInvalidDistanceUnit
 	}
 
-	rows, err := m.db.Query(profileQuery, userID, userID, userID)
+	saved, err := m.users.GetPreferences(userID)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-	var profiles []*models.Profile
+	preferences := request.override(saved.In(unit))
 
-	for rows.Next() {
-		profile := new(models.Profile)
+	v := &ValidationError{}
+	validatePreferences(v, preferences)
+	for _, gender := range request.Genders {
+		if !gender.Valid() {
+			v.Add("gender", fmt.Sprintf("%q isn't a gender, see GET /genders", gender))
+		}
+	}
+	if err = v.OrNil(); err != nil {
+		return nil, err
+	}
 
-		var dateOfBirth string
+	opts := discoveryOpts(requestingUser, preferences, m.now())
+	if len(request.Genders) > 0 {
+		opts.Genders = request.Genders
+	}
+	if request.Cursor != "" {
+		opts.After, err = m.cursors.open(request.Cursor, opts.Sort)
+		if err != nil {
+			return nil, err
+		}
+		// desirability is decayed, and ranked profiles are scored, at the same time as the previous page
+		// so the order doesn't shift between pages
+		if opts.After.RatedAt != nil {
+			opts.Now = *opts.After.RatedAt
+		}
+	}
+
+	origin := opts.Origin
+	// one extra profile tells us whether there is another page
+	opts.Limit = limit + 1
+	if opts.Sort == models.SortRanked {
+		opts.Limit = deckSize
+	}
 
-		err = rows.Scan(&profile.ID, &profile.Name, &profile.Gender, &dateOfBirth, &profile.Latitude, &profile.Longitude, &profile.LikabilityScore)
+	var profiles []*models.Profile
+	fromDeck := false
+	// decks are filtered on the distances shown in the user's own unit
+	if request.usesSavedPreferences(saved) && unit == requestingUser.DistanceUnit {
+		profiles, fromDeck, err = m.fromDeck(requestingUser, saved, &opts)
 		if err != nil {
 			return nil, err
 		}
-		profile.DateOfBirth, err = time.Parse("2006-01-02 15:04:05", dateOfBirth)
+	}
+	if !fromDeck {
+		profiles, err = queryProfiles(m.matches, m.blur, userID, preferences, opts)
 		if err != nil {
 			return nil, err
 		}
-		profile.Age = int(math.Floor(time.Since(profile.DateOfBirth).Hours() / 24 / 365))
+	}
 
-		profiles = append(profiles, profile)
+	for _, profile := range profiles {
+		profile.Age = ageFrom(profile.DateOfBirth)
 	}
-	return profiles, nil
+
+	if opts.Sort == models.SortRanked {
+		viewer := ranking.Viewer{Age: opts.ViewerAge, AgeMin: preferences.AgeMin, AgeMax: preferences.AgeMax}
+		profiles, err = m.rank(userID, viewer, profiles, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	page := &models.ProfilePage{Profiles: profiles}
+	if len(profiles) > limit {
+		page.Profiles = profiles[:limit]
+
+		cursor, err := m.cursors.seal(cursorAfter(page.Profiles[limit-1], opts))
+		if err != nil {
+			return nil, err
+		}
+		page.NextCursor = &cursor
+	}
+	for _, profile := range page.Profiles {
+		if profile.Distance != nil {
+			profile.ApproximateDistance = m.blur.approximate(userID, origin, profile.ID, *profile.Distance, unit)
+			profile.Distance = nil
+		}
+		profile.Desirability = nil
+		if !request.Debug {
+			profile.Ranking = nil
+		}
+	}
+	page.Profiles = orEmpty(page.Profiles)
+
+	err = attachDetails(m.profiles, m.photos, page.Profiles)
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+/*
+MatchesRequest - a request for a page of the user's matches, distances are shown in Unit which defaults to the user's own unit
+*/
+type MatchesRequest struct {
+	Unit   models.DistanceUnit
+	Cursor string
+	Limit  int
 }
 
 /*
-GetRelationship - gets the current match status between two users.
-This allows for swiping back if there is a pending match
+GetMatches - a page of the users the requesting user is matched with, most recently matched first.
+LastMessage is reserved for when users can message each other and is always nil.
+Each profile is returned like the ones from GetProfilesForUser, with its age, approximate distance, interests, prompts and photos.
+Returns ErrInvalidCursor if the cursor can't be read
 */
-func (m *Match) GetRelationship (userID, profileID int) (*models.Match, error) {
-	relationshipQuery := `SELECT * FROM matches 
-WHERE (user_id = ? AND match_user_id = ?)
-OR (user_id = ? AND match_user_id = ?);`
+func (m *Match) GetMatches(userID int, request MatchesRequest) (*models.MatchPage, error) {
+	requestingUser, err := m.users.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := request.Limit
+	if limit < 1 || limit > MaxPageSize {
+		limit = DefaultPageSize
+	}
+
+	unit := request.Unit
+	if unit == "" {
+		unit = requestingUser.DistanceUnit
+	}
+	if !unit.Valid() {
+		return nil, ErrInvalidDistanceUnit
+	}
+
+	var after *models.MatchCursor
+	if request.Cursor != "" {
+		after, err = decodeMatchCursor(request.Cursor)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// one extra match tells us whether there is another page
+	matches, err := m.matches.GetMatches(userID, after, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.MatchPage{Matches: matches}
+	if len(matches) > limit {
+		page.Matches = matches[:limit]
+
+		last := page.Matches[limit-1]
+		cursor, err := encodeMatchCursor(&models.MatchCursor{MatchedAt: last.MatchedAt, UserID: last.Profile.ID})
+		if err != nil {
+			return nil, err
+		}
+		page.NextCursor = &cursor
+	}
+	page.Matches = orEmpty(page.Matches)
 
-	row := m.db.QueryRow(relationshipQuery, userID, profileID, profileID, userID)
-	relationship := new(models.Match)
-	err := row.Scan(&relationship.UserID, &relationship.MatchID, &relationship.State)
-	if errors.Is(err, sql.ErrNoRows) {
-		return nil, nil
+	origin := models.Location{Latitude: requestingUser.Latitude, Longitude: requestingUser.Longitude}
+	profiles := make([]*models.Profile, len(page.Matches))
+	for i, match := range page.Matches {
+		profile := match.Profile
+		profile.Age = ageFrom(profile.DateOfBirth)
+		distance := geo.Haversine(origin.Latitude, origin.Longitude, profile.Latitude, profile.Longitude)
+		profile.ApproximateDistance = m.blur.approximate(userID, origin, profile.ID, distance, unit)
+		profiles[i] = profile
 	}
+
+	err = attachDetails(m.profiles, m.photos, profiles)
 	if err != nil {
 		return nil, err
 	}
-	return relationship, nil
+
+	return page, nil
 }
 
 /*
-Create - new match when a user is swiped and no current relationship exists
+rank - scores the candidates with the ranker for the user's experiment and returns them best first, starting after the cursor
 */
-func (m *Match) Create(newMatch *models.Match) error {
-	_, err := m.db.Exec("INSERT INTO matches (user_id, match_user_id, state) VALUES (?,?,?)", newMatch.UserID, newMatch.MatchID, newMatch.State)
+func (m *Match) rank(userID int, viewer ranking.Viewer, profiles []*models.Profile, opts models.FilterOpts) ([]*models.Profile, error) {
+	userIDs := []int{userID}
+	for _, profile := range profiles {
+		userIDs = append(userIDs, profile.ID)
+	}
+	interests, err := m.profiles.GetInterestsForUsers(userIDs)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	viewer.Interests = interests[userID]
+
+	candidates := make([]ranking.Candidate, len(profiles))
+	for i, profile := range profiles {
+		candidates[i] = ranking.Candidate{Profile: profile, Interests: interests[profile.ID]}
 	}
 
-	return nil
+	var ranked []*models.Profile
+	for _, candidate := range m.rankers.For(userID).Rank(viewer, candidates, opts.Now) {
+		profile := candidate.Profile
+		if opts.After != nil && profile.Ranking.Score >= opts.After.Score &&
+			(profile.Ranking.Score != opts.After.Score || profile.ID <= opts.After.ID) {
+			continue
+		}
+		ranked = append(ranked, profile)
+	}
+
+	return ranked, nil
 }
 
 /*
-Update - existing relationship state change
-ie pending to matched or unmatched
+override - the preferences with this request's filters in place of the saved ones
 */
-func (m *Match) Update(newMatch *models.Match) error {
-	_, err := m.db.Exec("UPDATE matches set state = ? WHERE user_id = ? AND match_user_id = ?", newMatch.State, newMatch.UserID, newMatch.MatchID)
+func (r ProfilesRequest) override(preferences models.DiscoveryPreferences) models.DiscoveryPreferences {
+	if r.AgeMin != nil {
+		preferences.AgeMin = r.AgeMin
+	}
+	if r.AgeMax != nil {
+		preferences.AgeMax = r.AgeMax
+	}
+	if len(r.ShowMe) > 0 {
+		preferences.ShowMe = r.ShowMe
+	}
+	if r.MaxDistance != nil {
+		preferences.MaxDistance = r.MaxDistance
+	}
+	if r.Sort != nil {
+		preferences.Sort = *r.Sort
+	}
+	return preferences
+}
+
+/*
+cursorAfter - the position of the profile in the sort
+*/
+func cursorAfter(profile *models.Profile, opts models.FilterOpts) *models.ProfileCursor {
+	after := &models.ProfileCursor{
+		Sort:       opts.Sort,
+		ID:         profile.ID,
+		Likability: valueOrZero(profile.LikabilityScore),
+		Distance:   valueOrZero(profile.Distance),
+	}
+	switch opts.Sort {
+	case models.SortDesirability:
+		after.Desirability = valueOrZero(profile.Desirability)
+		after.RatedAt = &opts.Now
+	case models.SortRanked:
+		if profile.Ranking != nil {
+			after.Score = profile.Ranking.Score
+		}
+		after.RatedAt = &opts.Now
+	}
+	return after
+}
+
+/*
+queryProfiles - the profiles for the filters, keeping the ones the user is shown as within the preferences' max distance.
+The repository only compares exact distances with the search radius, so it's asked for more until there are Limit profiles
+*/
+func queryProfiles(matches repositories.MatchRepository, blur *distanceBlur, userID int, preferences models.DiscoveryPreferences,
+	opts models.FilterOpts) ([]*models.Profile, error) {
+	var profiles []*models.Profile
+	for {
+		fetched, err := matches.GetProfilesForUser(userID, opts)
+		if err != nil {
+			return nil, err
+		}
+		if preferences.MaxDistance == nil {
+			return fetched, nil
+		}
+
+		for _, profile := range fetched {
+			if profile.Distance != nil && blur.within(userID, opts.Origin, profile.ID, *profile.Distance, *preferences.MaxDistance, preferences.Unit) {
+				profiles = append(profiles, profile)
+			}
+		}
+
+		// the ranked profiles are the nearest Limit, the repository doesn't page through them
+		if opts.Limit == 0 || len(profiles) >= opts.Limit || len(fetched) < opts.Limit || opts.Sort == models.SortRanked {
+			break
+		}
+		opts.After = cursorAfter(fetched[len(fetched)-1], opts)
+	}
+
+	if opts.Limit > 0 && len(profiles) > opts.Limit {
+		profiles = profiles[:opts.Limit]
+	}
+	return profiles, nil
+}
+
+/*
+discoveryOpts - the repository filters for the user looking for profiles with these preferences, from their location
+*/
+func discoveryOpts(user *models.User, preferences models.DiscoveryPreferences, now time.Time) models.FilterOpts {
+	opts := filterOpts(preferences, now)
+	opts.Now = now.UTC().Truncate(time.Second)
+	opts.Origin = models.Location{Latitude: user.Latitude, Longitude: user.Longitude}
+	opts.ViewerAge = ageFrom(user.DateOfBirth)
+	opts.ViewerGender = user.Gender
+	return opts
+}
+
+/*
+filterOpts - the repository filters for the preferences, ages become the range of dates of birth, the show me groups
+become the genders in them and distances are in miles
+age limits are inclusive, someone who is age_max today is shown until their next birthday.
+The max distance becomes the search radius, see queryProfiles for how profiles are kept within it
+*/
+func filterOpts(preferences models.DiscoveryPreferences, now time.Time) models.FilterOpts {
+	opts := models.FilterOpts{
+		AgeMin:  &time.Time{},
+		AgeMax:  &time.Time{},
+		Genders: models.GendersFor(preferences.ShowMe),
+		Sort:    preferences.Sort,
+	}
+
+	if preferences.AgeMin != nil {
+		bornBefore := now.AddDate(-*preferences.AgeMin, 0, 0)
+		opts.AgeMin = &bornBefore
+	}
+	if preferences.AgeMax != nil {
+		bornAfter := now.AddDate(-*preferences.AgeMax-1, 0, 0)
+		opts.AgeMax = &bornAfter
+	}
+	if preferences.MaxDistance != nil {
+		opts.MaxDistance = preferences.Unit.ToMiles(searchRadius(*preferences.MaxDistance))
+	}
+
+	return opts
+}
+
+/*
+ageFrom - whole years since the date of birth
+*/
+func ageFrom(dateOfBirth time.Time) int {
+	return int(math.Floor(time.Since(dateOfBirth).Hours() / 24 / 365))
+}
+
+func valueOrZero[T int | float64](value *T) T {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
+
+var (
+	ErrAlreadySwiped = errors.New("already swiped this profile")
+	ErrSwipeSelf     = errors.New("users can't swipe on themselves")
+)
+
+/*
+Swipe - records the user's swipe on the profile and returns the pair's relationship afterwards.
+The relationship is locked while the swipe is applied, so when two users swipe yes on each other at the same time
+one of them sees the other's swipe and they're matched exactly once.
+A user can change their swipe at any time, a no unmatches them and a yes rematches them if the other user still says yes.
+The profile is taken out of the user's deck, and the user out of theirs once the pair is matched or unmatched.
+A yes is worth one to the swiped user's likability and a no minus one, only the user's latest swipe counts.
+It's recorded in the likability ledger in the same transaction, reversing what their previous swipe was worth,
+and the first time the user swipes on the profile its desirability rating is updated against the user's.
+Returns ErrAlreadySwiped if it's the same as their current swipe, repositories.ErrNotFound if the profile doesn't exist
+and ErrSwipeSelf for their own profile
+*/
+func (m *Match) Swipe(userID, profileID int, preference models.Preference) (*models.Match, error) {
+	if userID == profileID {
+		return nil, ErrSwipeSelf
+	}
+
+	_, err := m.users.GetByID(profileID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	relationship, err := m.matches.Swipe(userID, profileID, func(relationship *models.Match) (int, error) {
+		return applySwipe(relationship, userID, preference, now)
+	}, func(swiped, swiper models.Rating) models.Rating {
+		return rating.Swipe(swiped, swiper, preference == models.Yes, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m.decks.consume(userID, profileID)
+	if relationship.State != models.Pending {
+		m.decks.consume(profileID, userID)
+	}
+
+	return relationship, nil
+}
+
+/*
+applySwipe - the swipe state machine, sets the user's side of the relationship and works out the state from both sides.
+Any no is Unmatched, a yes from both is Matched and anything else is Pending.
+Returns what the swipe is worth to the swiped user's likability
+*/
+func applySwipe(relationship *models.Match, userID int, preference models.Preference, now time.Time) (int, error) {
+	current := relationship.SwipeOf(userID)
+	if current != nil && *current == preference {
+		return 0, ErrAlreadySwiped
+	}
+
+	if userID == relationship.LowUserID {
+		relationship.LowSwipe = &preference
+	} else {
+		relationship.HighSwipe = &preference
 	}
 
-	return nil
+	low, high := relationship.LowSwipe, relationship.HighSwipe
+	switch {
+	case (low != nil && *low == models.No) || (high != nil && *high == models.No):
+		relationship.State = models.Unmatched
+		relationship.MatchedAt = nil
+	case low != nil && high != nil:
+		if relationship.State != models.Matched {
+			relationship.MatchedAt = &now
+		}
+		relationship.State = models.Matched
+	default:
+		relationship.State = models.Pending
+	}
+	relationship.UpdatedAt = now
+
+	if preference == models.Yes {
+		return 1, nil
+	}
+	return -1, nil
 }
@@ -0,0 +1,93 @@
+package interactors
+
+import (
+	"dating-app/src/models"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+/*
+TestRankedPagingFreezesNow - the ranked scores that depend on the time are worked out at the time of the first page
+on every page after it, so pages requested much later still carry on from the cursor's score.
+New users' scores have fallen to nothing a fortnight on, had the second page been scored then every profile
+would fall below the cursor and the first page would be shown again
+*/
+func TestRankedPagingFreezesNow(t *testing.T) {
+	app := newTestApp(t, nil)
+	user := app.createUser(t, models.Female, 30, 0)
+	for i := 0; i < 5; i++ {
+		app.createUser(t, models.Male, 30, float64(i+1))
+	}
+
+	start := time.Now()
+	app.match.now = func() time.Time { return start }
+	ranked := models.SortRanked
+
+	all, err := app.match.GetProfilesForUser(user.ID, ProfilesRequest{Sort: &ranked})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ids(all.Profiles)
+	if len(want) != 5 {
+		t.Fatalf("ranked %d profiles, want 5", len(want))
+	}
+
+	var got []int
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > len(want) {
+			t.Fatal("next_cursor never ran out")
+		}
+		// every page is requested a fortnight after the one before it
+		requestedAt := start.Add(time.Duration(pages) * 15 * 24 * time.Hour)
+		app.match.now = func() time.Time { return requestedAt }
+
+		page, err := app.match.GetProfilesForUser(user.ID, ProfilesRequest{Sort: &ranked, Cursor: cursor, Limit: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, ids(page.Profiles)...)
+		if page.NextCursor == nil {
+			break
+		}
+		cursor = *page.NextCursor
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("paged through %v, want %v", got, want)
+	}
+}
+
+/*
+TestCursorNeedsRatedAt - a cursor for a sort whose scores depend on the time has to say which time it was sorted at
+*/
+func TestCursorNeedsRatedAt(t *testing.T) {
+	app := newTestApp(t, nil)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		cursor  models.ProfileCursor
+		wantErr error
+	}{
+		{cursor: models.ProfileCursor{Sort: models.SortRanked, ID: 3, Score: 1.5, RatedAt: &now}},
+		{cursor: models.ProfileCursor{Sort: models.SortRanked, ID: 3, Score: 1.5}, wantErr: ErrInvalidCursor},
+		{cursor: models.ProfileCursor{Sort: models.SortDesirability, ID: 3, Desirability: 1500, RatedAt: &now}},
+		{cursor: models.ProfileCursor{Sort: models.SortDesirability, ID: 3, Desirability: 1500}, wantErr: ErrInvalidCursor},
+		{cursor: models.ProfileCursor{Sort: models.SortDistance, ID: 3, Distance: 2}},
+	}
+	for _, test := range tests {
+		sealed, err := app.match.cursors.seal(&test.cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opened, err := app.match.cursors.open(sealed, test.cursor.Sort)
+		if !errors.Is(err, test.wantErr) {
+			t.Errorf("%s cursor with RatedAt %v err = %v, want %v", test.cursor.Sort, test.cursor.RatedAt, err, test.wantErr)
+			continue
+		}
+		if err == nil && opened.Score != test.cursor.Score {
+			t.Errorf("%s cursor score = %v, want %v", test.cursor.Sort, opened.Score, test.cursor.Score)
+		}
+	}
+}
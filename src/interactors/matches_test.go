@@ -0,0 +1,91 @@
+package interactors
+
+import (
+	"dating-app/src/models"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+/*
+TestGetMatchesCursor - paging through GetMatches with each page's next_cursor returns every match once, in order
+*/
+func TestGetMatchesCursor(t *testing.T) {
+	app := newTestApp(t, nil)
+	user := app.createUser(t, models.Female, 30, 0)
+
+	base := time.Now().UTC().Truncate(time.Second).Add(-time.Hour)
+	var want []int
+	for i := 0; i < 7; i++ {
+		other := app.createUser(t, models.Male, 30, float64(i+1))
+		// pairs of matches at the same time, so pages split ties
+		matchedAt := base.Add(-time.Duration(i/2) * time.Minute)
+		_, err := app.matches.Swipe(user.ID, other.ID, func(relationship *models.Match) (int, error) {
+			yes := models.Yes
+			relationship.LowSwipe, relationship.HighSwipe = &yes, &yes
+			relationship.State = models.Matched
+			relationship.MatchedAt = &matchedAt
+			return 0, nil
+		}, func(swiped, swiper models.Rating) models.Rating { return swiped })
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, other.ID)
+	}
+	// within each tie the higher id comes first
+	for i := 0; i+1 < len(want); i += 2 {
+		want[i], want[i+1] = want[i+1], want[i]
+	}
+
+	var got []int
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > len(want) {
+			t.Fatal("next_cursor never ran out")
+		}
+		page, err := app.match.GetMatches(user.ID, MatchesRequest{Cursor: cursor, Limit: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, match := range page.Matches {
+			got = append(got, match.Profile.ID)
+			if match.Profile.Age != 30 || match.Profile.ApproximateDistance == nil {
+				t.Errorf("%d's profile is missing its age or distance: %+v", match.Profile.ID, match.Profile)
+			}
+		}
+		if page.NextCursor == nil {
+			if len(page.Matches) == 0 {
+				t.Error("the last page is empty, next_cursor should have been nil on the page before")
+			}
+			break
+		}
+		cursor = *page.NextCursor
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("paged through %v, want %v", got, want)
+	}
+
+	for _, cursor := range []string{"not a cursor", "bm90IGpzb24", "e30"} {
+		_, err := app.match.GetMatches(user.ID, MatchesRequest{Cursor: cursor})
+		if !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("cursor %q err = %v, want ErrInvalidCursor", cursor, err)
+		}
+	}
+}
+
+func TestMatchCursorRoundTrip(t *testing.T) {
+	cursor := &models.MatchCursor{MatchedAt: time.Date(2024, 6, 1, 12, 30, 15, 0, time.UTC), UserID: 42}
+
+	encoded, err := encodeMatchCursor(cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := decodeMatchCursor(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.MatchedAt.Equal(cursor.MatchedAt) || decoded.UserID != cursor.UserID {
+		t.Errorf("decoded %+v, want %+v", decoded, cursor)
+	}
+}
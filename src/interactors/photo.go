@@ -0,0 +1,229 @@
+package interactors
+
+import (
+	"crypto/rand"
+	"dating-app/src/media"
+	"dating-app/src/models"
+	"dating-app/src/repositories"
+	"dating-app/src/storage"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/labstack/gommon/log"
+	"time"
+)
+
+const maxPhotos = 6
+
+/*
+Photos - the photos on users' profiles, the files are kept in blob storage and the rows in the photo repository
+*/
+type Photos struct {
+	photos repositories.PhotoRepository
+	blobs  storage.Blobs
+}
+
+func NewPhotos(photos repositories.PhotoRepository, blobs storage.Blobs) *Photos {
+	return &Photos{
+		photos: photos,
+		blobs:  blobs,
+	}
+}
+
+/*
+List - the user's photos in the order they arranged them, the first is their primary photo
+*/
+func (p *Photos) List(userID int) ([]models.Photo, error) {
+	photos, err := p.forUsers([]int{userID})
+	if err != nil {
+		return nil, err
+	}
+	return orEmpty(photos[userID]), nil
+}
+
+/*
+forUsers - each of the users' photos with the URLs they can be fetched from, keyed by user id
+*/
+func (p *Photos) forUsers(userIDs []int) (map[int][]models.Photo, error) {
+	photos, err := p.photos.GetPhotosForUsers(userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, userPhotos := range photos {
+		for i := range userPhotos {
+			p.withURLs(&userPhotos[i])
+		}
+	}
+
+	return photos, nil
+}
+
+func (p *Photos) withURLs(photo *models.Photo) {
+	photo.URL = p.blobs.URL(photo.Key)
+	photo.ThumbnailURL = p.blobs.URL(photo.ThumbnailKey)
+	photo.Primary = photo.Position == 0
+}
+
+/*
+Upload - adds a JPEG, PNG or WebP photo after the user's others, see media.Process for what's done to it
+Returns a ValidationError if the file isn't an acceptable photo or the user already has as many as they're allowed
+*/
+func (p *Photos) Upload(userID int, data []byte) (models.Photo, error) {
+	count, err := p.photos.CountPhotos(userID)
+	if err != nil {
+		return models.Photo{}, err
+	}
+	// checked again when the photo is added, this just saves processing one that can't be kept
+	if count >= maxPhotos {
+		return models.Photo{}, photoLimitError()
+	}
+
+	processed, err := media.Process(data)
+	if errors.Is(err, media.ErrTooLarge) || errors.Is(err, media.ErrUnsupportedFormat) || errors.Is(err, media.ErrDimensions) {
+		v := &ValidationError{}
+		v.Add("photo", err.Error())
+		return models.Photo{}, v
+	}
+	if err != nil {
+		return models.Photo{}, err
+	}
+
+	name, err := randomName()
+	if err != nil {
+		return models.Photo{}, err
+	}
+	photo := models.Photo{
+		UserID:       userID,
+		Key:          fmt.Sprintf("photos/%d/%s%s", userID, name, media.FileExtension),
+		ThumbnailKey: fmt.Sprintf("photos/%d/%s-thumb%s", userID, name, media.FileExtension),
+		Width:        processed.Width,
+		Height:       processed.Height,
+		CreatedAt:    time.Now().UTC().Truncate(time.Second),
+	}
+
+	err = p.blobs.Put(photo.Key, media.ContentType, processed.Photo)
+	if err != nil {
+		return models.Photo{}, err
+	}
+	err = p.blobs.Put(photo.ThumbnailKey, media.ContentType, processed.Thumbnail)
+	if err != nil {
+		p.deleteFiles(photo)
+		return models.Photo{}, err
+	}
+
+	added, err := p.photos.AddPhoto(photo, maxPhotos)
+	if err != nil {
+		p.deleteFiles(photo)
+		if errors.Is(err, repositories.ErrPhotoLimit) {
+			return models.Photo{}, photoLimitError()
+		}
+		return models.Photo{}, err
+	}
+
+	p.withURLs(&added)
+
+	return added, nil
+}
+
+func photoLimitError() error {
+	v := &ValidationError{}
+	v.Add("photo", fmt.Sprintf("you can have at most %d photos, delete one first", maxPhotos))
+	return v
+}
+
+/*
+randomName - file names are random so photo URLs can't be guessed from the user id
+*/
+func randomName() (string, error) {
+	name := make([]byte, 16)
+	_, err := rand.Read(name)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(name), nil
+}
+
+/*
+Delete - removes one of the user's photos, returns repositories.ErrNotFound if it isn't theirs
+*/
+func (p *Photos) Delete(userID, photoID int) error {
+	photo, err := p.photos.DeletePhoto(userID, photoID)
+	if err != nil {
+		return err
+	}
+
+	p.deleteFiles(photo)
+
+	return nil
+}
+
+/*
+deleteFiles - the photo is already gone from the profile, files that fail to delete are only orphaned so they're logged rather than returned
+*/
+func (p *Photos) deleteFiles(photo models.Photo) {
+	for _, key := range []string{photo.Key, photo.ThumbnailKey} {
+		err := p.blobs.Delete(key)
+		if err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+/*
+Order - rearranges the user's photos, photoIDs must list each of them once. The first becomes their primary photo
+*/
+func (p *Photos) Order(userID int, photoIDs []int) ([]models.Photo, error) {
+	photos, err := p.List(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[int]bool{}
+	for _, photoID := range photoIDs {
+		seen[photoID] = true
+	}
+	valid := len(photoIDs) == len(photos) && len(seen) == len(photos)
+	for _, photo := range photos {
+		valid = valid && seen[photo.ID]
+	}
+	if !valid {
+		v := &ValidationError{}
+		v.Add("photo_ids", "must list each of your photos once")
+		return nil, v
+	}
+
+	err = p.photos.OrderPhotos(userID, photoIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.List(userID)
+}
+
+/*
+MakePrimary - moves the photo to the front, the others keep their order. Returns repositories.ErrNotFound if it isn't the user's
+*/
+func (p *Photos) MakePrimary(userID, photoID int) ([]models.Photo, error) {
+	photos, err := p.List(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	photoIDs := []int{photoID}
+	for _, photo := range photos {
+		if photo.ID != photoID {
+			photoIDs = append(photoIDs, photo.ID)
+		}
+	}
+	if len(photoIDs) != len(photos) {
+		return nil, repositories.ErrNotFound
+	}
+
+	err = p.photos.OrderPhotos(userID, photoIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.List(userID)
+}
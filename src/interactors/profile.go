@@ -0,0 +1,506 @@
+package interactors
+
+import (
+	"dating-app/src/deck"
+	"dating-app/src/models"
+	"dating-app/src/repositories"
+	"dating-app/src/storage"
+	"fmt"
+	"github.com/labstack/gommon/log"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxGenderDescriptionLength = 50
+	maxBioLength               = 500
+	maxJobTitleLength          = 100
+	maxSchoolLength            = 100
+	minHeightCM                = 90
+	maxHeightCM                = 250
+	maxInterests               = 10
+	maxPrompts                 = 3
+	maxAnswerLength            = 300
+)
+
+/*
+MinLocationInterval - how long a user has to wait between moving. Distances are blurred, but reporting a run of locations
+close together would let someone narrow down where another user is from how their distance changes
+*/
+const MinLocationInterval = 5 * time.Minute
+
+/*
+LocationTooSoonError - the user moved less than MinLocationInterval ago, they can move again after RetryAfter
+*/
+type LocationTooSoonError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LocationTooSoonError) Error() string {
+	return fmt.Sprintf("location can't be changed for another %s", e.RetryAfter)
+}
+
+/*
+Profile - the requesting user managing their own profile
+*/
+type Profile struct {
+	users    repositories.UserRepository
+	profiles repositories.ProfileRepository
+	photos   *Photos
+	decks    deck.Store
+}
+
+func NewProfile(users repositories.UserRepository, profiles repositories.ProfileRepository, photos repositories.PhotoRepository, blobs storage.Blobs,
+	decks deck.Store) *Profile {
+	return &Profile{
+		users:    users,
+		profiles: profiles,
+		photos:   NewPhotos(photos, blobs),
+		decks:    decks,
+	}
+}
+
+/*
+Get - the user's own profile, everything they've filled in along with their private details
+the password hash is never returned
+*/
+func (p *Profile) Get(userID int) (*models.User, error) {
+	user, err := p.users.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	user.Password = ""
+	user.Age = ageFrom(user.DateOfBirth)
+
+	err = attachDetails(p.profiles, p.photos, []*models.Profile{&user.Profile})
+	if err != nil {
+		return nil, err
+	}
+
+	preferences, err := p.users.GetPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+	preferences = preferences.In(user.DistanceUnit)
+	preferences.ShowMe = orEmpty(preferences.ShowMe)
+	user.Preferences = &preferences
+
+	return user, nil
+}
+
+/*
+ProfileChanges - a partial update to the user's profile, nil fields are left as they are
+a nil HeightCM also means leave it alone, so ClearHeight removes it
+*/
+type ProfileChanges struct {
+	Name              *string
+	Gender            *models.GenderType
+	GenderDescription *string
+	Bio               *string
+	JobTitle          *string
+	School            *string
+	HeightCM          *int
+	ClearHeight       bool
+	DistanceUnit      *models.DistanceUnit
+}
+
+/*
+Update - applies the changes to the user's profile, as long as it's still at version
+returns repositories.ErrVersionConflict if it has changed since, the client should fetch it again and reapply their changes
+a new gender changes who the user can be shown, so their deck is thrown away
+*/
+func (p *Profile) Update(userID, version int, changes ProfileChanges) (*models.User, error) {
+	user, err := p.users.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Version != version {
+		return nil, repositories.ErrVersionConflict
+	}
+
+	if changes.Name != nil {
+		user.Name = strings.TrimSpace(*changes.Name)
+	}
+	if changes.Gender != nil {
+		user.Gender = *changes.Gender
+	}
+	if changes.GenderDescription != nil {
+		user.GenderDescription = strings.TrimSpace(*changes.GenderDescription)
+	}
+	if changes.Bio != nil {
+		user.Bio = strings.TrimSpace(*changes.Bio)
+	}
+	if changes.JobTitle != nil {
+		user.JobTitle = strings.TrimSpace(*changes.JobTitle)
+	}
+	if changes.School != nil {
+		user.School = strings.TrimSpace(*changes.School)
+	}
+	if changes.HeightCM != nil || changes.ClearHeight {
+		user.HeightCM = changes.HeightCM
+	}
+	if changes.DistanceUnit != nil {
+		user.DistanceUnit = *changes.DistanceUnit
+	}
+
+	v := &ValidationError{}
+	validateName(v, user.Name)
+	validateDetails(v, user.Profile)
+	if !user.DistanceUnit.Valid() {
+		v.Add("distance_unit", "must be km or mi")
+	}
+	if err = v.OrNil(); err != nil {
+		return nil, err
+	}
+
+	_, err = p.users.UpdateProfile(*user, version)
+	if err != nil {
+		return nil, err
+	}
+	if changes.Gender != nil {
+		p.invalidateDeck(userID)
+	}
+
+	return p.Get(userID)
+}
+
+/*
+GetPreferences - the user's discovery preferences, with max distance in their own unit
+*/
+func (p *Profile) GetPreferences(userID int) (models.DiscoveryPreferences, error) {
+	user, err := p.users.GetByID(userID)
+	if err != nil {
+		return models.DiscoveryPreferences{}, err
+	}
+
+	preferences, err := p.users.GetPreferences(userID)
+	if err != nil {
+		return models.DiscoveryPreferences{}, err
+	}
+
+	preferences = preferences.In(user.DistanceUnit)
+	preferences.ShowMe = orEmpty(preferences.ShowMe)
+
+	return preferences, nil
+}
+
+/*
+SavePreferences - replaces the user's discovery preferences, max distance is in preferences.Unit or the user's own unit
+the user's deck was generated for the old ones, so it's thrown away
+*/
+func (p *Profile) SavePreferences(userID int, preferences models.DiscoveryPreferences) (models.DiscoveryPreferences, error) {
+	user, err := p.users.GetByID(userID)
+	if err != nil {
+		return preferences, err
+	}
+	if preferences.Unit == "" {
+		preferences.Unit = user.DistanceUnit
+	}
+
+	var showMe []models.ShowMe
+	for _, group := range preferences.ShowMe {
+		if !containsShowMe(showMe, group) {
+			showMe = append(showMe, group)
+		}
+	}
+	preferences.ShowMe = showMe
+
+	v := &ValidationError{}
+	validatePreferences(v, preferences)
+	if err = v.OrNil(); err != nil {
+		return preferences, err
+	}
+
+	err = p.users.SavePreferences(userID, preferences)
+	if err != nil {
+		return preferences, err
+	}
+	p.invalidateDeck(userID)
+
+	return p.GetPreferences(userID)
+}
+
+/*
+validatePreferences - checks discovery preferences, whether they're being saved or were given for a single request
+*/
+func validatePreferences(v *ValidationError, preferences models.DiscoveryPreferences) {
+	if preferences.AgeMin != nil && (*preferences.AgeMin < minimumAge || *preferences.AgeMin > maximumAge) {
+		v.Add("age_min", fmt.Sprintf("must be between %d and %d", minimumAge, maximumAge))
+	}
+	if preferences.AgeMax != nil && (*preferences.AgeMax < minimumAge || *preferences.AgeMax > maximumAge) {
+		v.Add("age_max", fmt.Sprintf("must be between %d and %d", minimumAge, maximumAge))
+	}
+	if preferences.AgeMin != nil && preferences.AgeMax != nil && *preferences.AgeMin > *preferences.AgeMax {
+		v.Add("age_max", "must be at least age_min")
+	}
+	if !preferences.Unit.Valid() {
+		v.Add("unit", "must be km or mi")
+	}
+	if preferences.MaxDistance != nil && (math.IsNaN(*preferences.MaxDistance) || *preferences.MaxDistance <= 0) {
+		v.Add("max_distance", "must be more than 0")
+	}
+	for _, group := range preferences.ShowMe {
+		if !group.Valid() {
+			v.Add("show_me", "must be men, women or nonbinary")
+		}
+	}
+	switch preferences.Sort {
+	case models.SortDefault, models.SortRecommended, models.SortDistance, models.SortDesirability, models.SortRanked:
+	default:
+		v.Add("sort", "must be recommended, distance, desirability or ranked, or empty for the default order")
+	}
+}
+
+func containsShowMe(showMe []models.ShowMe, group models.ShowMe) bool {
+	for _, g := range showMe {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+UpdateLocation - records the user's current position, distances to other users are measured from here
+the user's deck was generated for where they were, so it's thrown away.
+Returns a LocationTooSoonError if the user moved less than MinLocationInterval ago, reporting the same location again
+is fine and keeps the time it was first reported
+*/
+func (p *Profile) UpdateLocation(userID int, location models.Location) (time.Time, error) {
+	v := &ValidationError{}
+	validateLocation(v, location)
+	if err := v.OrNil(); err != nil {
+		return time.Time{}, err
+	}
+
+	user, err := p.users.GetByID(userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	locatedAt := time.Now().UTC().Truncate(time.Second)
+	if user.LastLocatedAt != nil {
+		if sameLocation(models.Location{Latitude: user.Latitude, Longitude: user.Longitude}, location) {
+			return *user.LastLocatedAt, nil
+		}
+		if wait := user.LastLocatedAt.Add(MinLocationInterval).Sub(locatedAt); wait > 0 {
+			return time.Time{}, &LocationTooSoonError{RetryAfter: wait}
+		}
+	}
+
+	err = p.users.UpdateLocation(userID, location, locatedAt)
+	if err != nil {
+		return time.Time{}, err
+	}
+	p.invalidateDeck(userID)
+
+	return locatedAt, nil
+}
+
+/*
+sameLocation - whether the locations are the same to the 6 decimal places they're stored to
+*/
+func sameLocation(a, b models.Location) bool {
+	return math.Round(a.Latitude*1e6) == math.Round(b.Latitude*1e6) && math.Round(a.Longitude*1e6) == math.Round(b.Longitude*1e6)
+}
+
+/*
+invalidateDeck - throws away the user's deck, a new one is generated the next time they ask for profiles.
+Decks are checked against what they were generated for before they're used, so a failure is only logged
+*/
+func (p *Profile) invalidateDeck(userID int) {
+	err := p.decks.Invalidate(userID)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+/*
+Interests - the vocabulary users pick their interests from
+*/
+func (p *Profile) Interests() ([]models.Interest, error) {
+	interests, err := p.profiles.GetInterests()
+	return orEmpty(interests), err
+}
+
+/*
+Prompts - the catalog of prompts users can answer
+*/
+func (p *Profile) Prompts() ([]models.Prompt, error) {
+	prompts, err := p.profiles.GetPrompts()
+	return orEmpty(prompts), err
+}
+
+/*
+SetInterests - replaces the user's interests with the ones named by slug, duplicates are ignored
+*/
+func (p *Profile) SetInterests(userID int, slugs []string) ([]models.Interest, error) {
+	vocabulary, err := p.profiles.GetInterests()
+	if err != nil {
+		return nil, err
+	}
+	bySlug := make(map[string]int, len(vocabulary))
+	for _, interest := range vocabulary {
+		bySlug[interest.Slug] = interest.ID
+	}
+
+	v := &ValidationError{}
+	var interestIDs []int
+	picked := map[string]bool{}
+	for _, slug := range slugs {
+		slug = strings.ToLower(strings.TrimSpace(slug))
+		if picked[slug] {
+			continue
+		}
+		picked[slug] = true
+
+		id, ok := bySlug[slug]
+		if !ok {
+			v.Add("interests", fmt.Sprintf("%q is not a known interest", slug))
+			continue
+		}
+		interestIDs = append(interestIDs, id)
+	}
+	if len(picked) > maxInterests {
+		v.Add("interests", fmt.Sprintf("pick at most %d interests", maxInterests))
+	}
+	if err = v.OrNil(); err != nil {
+		return nil, err
+	}
+
+	err = p.profiles.SetInterests(userID, interestIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	interests, err := p.profiles.GetInterestsForUsers([]int{userID})
+	if err != nil {
+		return nil, err
+	}
+
+	return orEmpty(interests[userID]), nil
+}
+
+/*
+SetPrompts - replaces the user's prompt answers, they're shown on the profile in the order given
+each prompt can only be answered once and only prompts still in the catalog can be picked
+*/
+func (p *Profile) SetPrompts(userID int, answers []models.PromptAnswer) ([]models.PromptAnswer, error) {
+	catalog, err := p.profiles.GetPrompts()
+	if err != nil {
+		return nil, err
+	}
+	active := make(map[int]bool, len(catalog))
+	for _, prompt := range catalog {
+		active[prompt.ID] = true
+	}
+
+	v := &ValidationError{}
+	if len(answers) > maxPrompts {
+		v.Add("prompts", fmt.Sprintf("answer at most %d prompts", maxPrompts))
+	}
+
+	answered := map[int]bool{}
+	for i := range answers {
+		field := fmt.Sprintf("prompts[%d]", i)
+		answers[i].Answer = strings.TrimSpace(answers[i].Answer)
+
+		if !active[answers[i].PromptID] {
+			v.Add(field+".prompt_id", "is not a prompt that can be answered")
+		} else if answered[answers[i].PromptID] {
+			v.Add(field+".prompt_id", "has already been answered")
+		}
+		answered[answers[i].PromptID] = true
+
+		if answers[i].Answer == "" {
+			v.Add(field+".answer", "is required")
+		} else if utf8.RuneCountInString(answers[i].Answer) > maxAnswerLength {
+			v.Add(field+".answer", fmt.Sprintf("must be at most %d characters", maxAnswerLength))
+		}
+	}
+	if err = v.OrNil(); err != nil {
+		return nil, err
+	}
+
+	err = p.profiles.SetPrompts(userID, answers)
+	if err != nil {
+		return nil, err
+	}
+
+	saved, err := p.profiles.GetPromptsForUsers([]int{userID})
+	if err != nil {
+		return nil, err
+	}
+
+	return orEmpty(saved[userID]), nil
+}
+
+/*
+validateDetails - the optional details a user describes themselves with
+*/
+func validateDetails(v *ValidationError, profile models.Profile) {
+	if !profile.Gender.Valid() {
+		v.Add("gender", fmt.Sprintf("%q isn't a gender, see GET /genders", profile.Gender))
+	}
+	if utf8.RuneCountInString(profile.GenderDescription) > maxGenderDescriptionLength {
+		v.Add("gender_description", fmt.Sprintf("must be at most %d characters", maxGenderDescriptionLength))
+	}
+	if utf8.RuneCountInString(profile.Bio) > maxBioLength {
+		v.Add("bio", fmt.Sprintf("must be at most %d characters", maxBioLength))
+	}
+	if utf8.RuneCountInString(profile.JobTitle) > maxJobTitleLength {
+		v.Add("job_title", fmt.Sprintf("must be at most %d characters", maxJobTitleLength))
+	}
+	if utf8.RuneCountInString(profile.School) > maxSchoolLength {
+		v.Add("school", fmt.Sprintf("must be at most %d characters", maxSchoolLength))
+	}
+	if profile.HeightCM != nil && (*profile.HeightCM < minHeightCM || *profile.HeightCM > maxHeightCM) {
+		v.Add("height_cm", fmt.Sprintf("must be between %d and %d", minHeightCM, maxHeightCM))
+	}
+}
+
+/*
+attachDetails - loads the interests, prompt answers and photos for a page of profiles in one query each
+*/
+func attachDetails(profiles repositories.ProfileRepository, photos *Photos, page []*models.Profile) error {
+	if len(page) == 0 {
+		return nil
+	}
+
+	userIDs := make([]int, len(page))
+	for i, profile := range page {
+		userIDs[i] = profile.ID
+	}
+
+	interests, err := profiles.GetInterestsForUsers(userIDs)
+	if err != nil {
+		return err
+	}
+	answers, err := profiles.GetPromptsForUsers(userIDs)
+	if err != nil {
+		return err
+	}
+	userPhotos, err := photos.forUsers(userIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, profile := range page {
+		profile.Interests = orEmpty(interests[profile.ID])
+		profile.Prompts = orEmpty(answers[profile.ID])
+		profile.Photos = orEmpty(userPhotos[profile.ID])
+	}
+
+	return nil
+}
+
+/*
+orEmpty - so lists are returned as [] rather than null
+*/
+func orEmpty[T any](values []T) []T {
+	if values == nil {
+		return []T{}
+	}
+	return values
+}
@@ -0,0 +1,43 @@
+package interactors
+
+import (
+	"dating-app/src/models"
+	"errors"
+	"testing"
+)
+
+func TestUpdateLocationRateLimit(t *testing.T) {
+	app := newTestApp(t, nil)
+	user := app.createUser(t, models.Female, 30, 0)
+
+	here := models.Location{Latitude: 51.5, Longitude: -0.12}
+	locatedAt, err := app.profile.UpdateLocation(user.ID, here)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := app.profile.UpdateLocation(user.ID, here)
+	if err != nil {
+		t.Fatalf("reporting the same location again: %v", err)
+	}
+	if !again.Equal(locatedAt) {
+		t.Errorf("reporting the same location again returned %v, want when it was first reported %v", again, locatedAt)
+	}
+
+	_, err = app.profile.UpdateLocation(user.ID, models.Location{Latitude: 51.51, Longitude: -0.12})
+	var tooSoon *LocationTooSoonError
+	if !errors.As(err, &tooSoon) {
+		t.Fatalf("moving straight away returned %v, want a LocationTooSoonError", err)
+	}
+	if tooSoon.RetryAfter <= 0 || tooSoon.RetryAfter > MinLocationInterval {
+		t.Errorf("RetryAfter = %v, want up to %v", tooSoon.RetryAfter, MinLocationInterval)
+	}
+
+	stored, err := app.users.GetByID(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Latitude != here.Latitude {
+		t.Errorf("latitude = %v after a rejected move, want %v", stored.Latitude, here.Latitude)
+	}
+}
@@ -0,0 +1,200 @@
+package interactors
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"dating-app/src/auth"
+	"dating-app/src/config"
+	"dating-app/src/models"
+	"dating-app/src/repositories"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/labstack/gommon/log"
+	"time"
+)
+
+var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+
+/*
+DeviceInfo - what we record about the device a session was started on so users can recognise their sessions
+*/
+type DeviceInfo struct {
+	Name      string
+	UserAgent string
+	IPAddress string
+}
+
+type Session struct {
+	cfg      *config.Config
+	tokens   *auth.Tokens
+	sessions repositories.SessionRepository
+}
+
+func NewSession(cfg *config.Config, tokens *auth.Tokens, sessions repositories.SessionRepository) *Session {
+	return &Session{
+		cfg:      cfg,
+		tokens:   tokens,
+		sessions: sessions,
+	}
+}
+
+/*
+Start - creates a session for a user who has just logged in and issues its first tokens
+*/
+func (s *Session) Start(userID int, device DeviceInfo) (models.TokenPair, error) {
+	sessionID, err := randomHex(16)
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	refreshToken, refreshHash, err := newRefreshToken()
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	now := time.Now()
+	err = s.sessions.Create(&models.Session{
+		ID:               sessionID,
+		UserID:           userID,
+		RefreshTokenHash: refreshHash,
+		DeviceName:       truncate(device.Name, 255),
+		UserAgent:        truncate(device.UserAgent, 512),
+		IPAddress:        truncate(device.IPAddress, 45),
+		CreatedAt:        now,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(s.cfg.RefreshTokenTTL.Duration()),
+	})
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	return s.tokenPair(userID, sessionID, refreshToken)
+}
+
+/*
+Refresh - exchanges a refresh token for a new access token and a new refresh token
+Every refresh token can only be used once. If one that has already been rotated is presented again
+it has most likely been stolen, so the whole session is revoked to lock out whoever holds it
+*/
+func (s *Session) Refresh(refreshToken string) (models.TokenPair, error) {
+	oldHash := hashRefreshToken(refreshToken)
+
+	session, err := s.sessions.GetByRefreshTokenHash(oldHash)
+	if errors.Is(err, repositories.ErrNotFound) {
+		reused, err := s.sessions.GetByPreviousTokenHash(oldHash)
+		if err == nil {
+			log.Warnf("refresh token reused for session %s, revoking it", reused.ID)
+			err = s.sessions.Revoke(reused.ID, time.Now())
+		}
+		if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+			return models.TokenPair{}, err
+		}
+		return models.TokenPair{}, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	now := time.Now()
+	if !session.Active(now) {
+		return models.TokenPair{}, ErrInvalidRefreshToken
+	}
+
+	newToken, newHash, err := newRefreshToken()
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	rotated, err := s.sessions.Rotate(session.ID, oldHash, newHash, now, now.Add(s.cfg.RefreshTokenTTL.Duration()))
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+	if !rotated {
+		// a concurrent request used the same token first
+		return models.TokenPair{}, ErrInvalidRefreshToken
+	}
+
+	return s.tokenPair(session.UserID, session.ID, newToken)
+}
+
+/*
+IsActive - whether access tokens issued for the session should still be accepted
+*/
+func (s *Session) IsActive(sessionID string) (bool, error) {
+	session, err := s.sessions.GetByID(sessionID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return session.Active(time.Now()), nil
+}
+
+/*
+Revoke - logs out a single session, its access and refresh tokens stop working immediately
+*/
+func (s *Session) Revoke(sessionID string) error {
+	return s.sessions.Revoke(sessionID, time.Now())
+}
+
+/*
+RevokeAll - logs the user out of every device
+*/
+func (s *Session) RevokeAll(userID int) error {
+	return s.sessions.RevokeAllForUser(userID, time.Now())
+}
+
+func (s *Session) tokenPair(userID int, sessionID, refreshToken string) (models.TokenPair, error) {
+	accessToken, err := s.tokens.Issue(userID, sessionID, []string{auth.RoleUser})
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	return models.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(s.tokens.TTL().Seconds()),
+	}, nil
+}
+
+/*
+newRefreshToken - an opaque random token and the hash that gets stored in its place
+*/
+func newRefreshToken() (string, string, error) {
+	token := make([]byte, 32)
+	_, err := rand.Read(token)
+	if err != nil {
+		return "", "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(token)
+	return encoded, hashRefreshToken(encoded), nil
+}
+
+/*
+hashRefreshToken - refresh tokens are long and random so a fast unsalted hash is enough
+*/
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(length int) (string, error) {
+	b := make([]byte, length)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func truncate(value string, length int) string {
+	runes := []rune(value)
+	if len(runes) > length {
+		return string(runes[:length])
+	}
+	return value
+}
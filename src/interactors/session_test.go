@@ -0,0 +1,167 @@
+package interactors
+
+import (
+	"dating-app/src/auth"
+	"dating-app/src/config"
+	"dating-app/src/models"
+	"dating-app/src/repositories"
+	"errors"
+	"testing"
+)
+
+func newTestSession(t *testing.T) (*Session, *auth.Tokens) {
+	t.Helper()
+	cfg := config.Default()
+	keys, err := auth.LoadKeySet(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := auth.NewTokens(&cfg, keys)
+	return NewSession(&cfg, tokens, repositories.NewMemorySessionRepository()), tokens
+}
+
+/*
+sessionOf - the session an access token belongs to
+*/
+func sessionOf(t *testing.T, tokens *auth.Tokens, pair models.TokenPair) string {
+	t.Helper()
+	claims, err := tokens.Parse(pair.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return claims.SessionID
+}
+
+func isActive(t *testing.T, session *Session, sessionID string) bool {
+	t.Helper()
+	active, err := session.IsActive(sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return active
+}
+
+func TestSessionRefreshRotates(t *testing.T) {
+	session, tokens := newTestSession(t)
+
+	first, err := session.Start(7, DeviceInfo{Name: "phone"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionID := sessionOf(t, tokens, first)
+	if !isActive(t, session, sessionID) {
+		t.Fatal("a new session isn't active")
+	}
+
+	second, err := session.Refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.RefreshToken == first.RefreshToken || second.RefreshToken == "" {
+		t.Error("refresh didn't rotate the refresh token")
+	}
+	if sessionOf(t, tokens, second) != sessionID {
+		t.Error("refresh started a new session")
+	}
+
+	third, err := session.Refresh(second.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.RefreshToken == second.RefreshToken {
+		t.Error("the second refresh didn't rotate the refresh token")
+	}
+	if !isActive(t, session, sessionID) {
+		t.Error("refreshing ended the session")
+	}
+
+	if _, err = session.Refresh("not a refresh token"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("unknown refresh token err = %v, want ErrInvalidRefreshToken", err)
+	}
+	if !isActive(t, session, sessionID) {
+		t.Error("an unknown refresh token ended the session")
+	}
+}
+
+/*
+TestSessionRefreshReplay - presenting a refresh token that has already been rotated revokes the whole session,
+so the current refresh token and every access token issued for it stop working too
+*/
+func TestSessionRefreshReplay(t *testing.T) {
+	session, tokens := newTestSession(t)
+
+	first, err := session.Start(7, DeviceInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := session.Start(7, DeviceInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionID, otherID := sessionOf(t, tokens, first), sessionOf(t, tokens, other)
+
+	second, err := session.Refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = session.Refresh(first.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("replayed refresh token err = %v, want ErrInvalidRefreshToken", err)
+	}
+	if isActive(t, session, sessionID) {
+		t.Error("replaying a rotated refresh token didn't revoke the session")
+	}
+	if _, err = session.Refresh(second.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("current refresh token of a revoked session err = %v, want ErrInvalidRefreshToken", err)
+	}
+
+	if !isActive(t, session, otherID) {
+		t.Error("the replay revoked the user's other session")
+	}
+}
+
+func TestSessionLogout(t *testing.T) {
+	session, tokens := newTestSession(t)
+
+	start := func(userID int) (models.TokenPair, string) {
+		t.Helper()
+		pair, err := session.Start(userID, DeviceInfo{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pair, sessionOf(t, tokens, pair)
+	}
+	phone, phoneID := start(7)
+	_, laptopID := start(7)
+	_, tabletID := start(7)
+	_, someoneElseID := start(8)
+
+	if err := session.Revoke(phoneID); err != nil {
+		t.Fatal(err)
+	}
+	if isActive(t, session, phoneID) {
+		t.Error("the session is still active after logging out")
+	}
+	if !isActive(t, session, laptopID) || !isActive(t, session, tabletID) {
+		t.Error("logging out of one session ended the others")
+	}
+	if _, err := session.Refresh(phone.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("refresh after logging out err = %v, want ErrInvalidRefreshToken", err)
+	}
+
+	if err := session.RevokeAll(7); err != nil {
+		t.Fatal(err)
+	}
+	for _, sessionID := range []string{phoneID, laptopID, tabletID} {
+		if isActive(t, session, sessionID) {
+			t.Errorf("session %s is still active after logging out everywhere", sessionID)
+		}
+	}
+	if !isActive(t, session, someoneElseID) {
+		t.Error("logging out everywhere ended another user's session")
+	}
+
+	if isActive(t, session, "unknown") {
+		t.Error("an unknown session is active")
+	}
+}
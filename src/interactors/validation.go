@@ -0,0 +1,57 @@
+package interactors
+
+import (
+	"dating-app/src/models"
+	"math"
+	"sort"
+	"strings"
+)
+
+/*
+ValidationError - returned when a request is well formed but one or more fields have unacceptable values
+Fields maps the json name of each invalid field to a message the client can show
+*/
+type ValidationError struct {
+	Fields map[string]string `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	fields := make([]string, 0, len(e.Fields))
+	for field, message := range e.Fields {
+		fields = append(fields, field+": "+message)
+	}
+	sort.Strings(fields)
+
+	return "invalid fields: " + strings.Join(fields, ", ")
+}
+
+/*
+Add - records the first problem found with a field
+*/
+func (e *ValidationError) Add(field, message string) {
+	if e.Fields == nil {
+		e.Fields = map[string]string{}
+	}
+	if _, ok := e.Fields[field]; !ok {
+		e.Fields[field] = message
+	}
+}
+
+/*
+OrNil - returns nil when nothing was added so validators can end with return v.OrNil()
+*/
+func (e *ValidationError) OrNil() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+func validateLocation(v *ValidationError, location models.Location) {
+	if math.IsNaN(location.Latitude) || location.Latitude < -90 || location.Latitude > 90 {
+		v.Add("latitude", "must be between -90 and 90")
+	}
+	if math.IsNaN(location.Longitude) || location.Longitude < -180 || location.Longitude > 180 {
+		v.Add("longitude", "must be between -180 and 180")
+	}
+}
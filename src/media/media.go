@@ -0,0 +1,134 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png"
+)
+
+const (
+	MaxFileSize   = 10 << 20
+	MinDimension  = 200
+	MaxMegapixels = 36
+	PhotoSize     = 1600
+	ThumbnailSize = 320
+	photoQuality  = 85
+	thumbQuality  = 80
+	ContentType   = "image/jpeg"
+	FileExtension = ".jpg"
+)
+
+var (
+	ErrTooLarge          = fmt.Errorf("photos must be at most %d MB", MaxFileSize>>20)
+	ErrUnsupportedFormat = errors.New("photos must be JPEG, PNG or WebP")
+	ErrDimensions        = fmt.Errorf("photos must be at least %d pixels wide and high and at most %d megapixels", MinDimension, MaxMegapixels)
+)
+
+/*
+Processed - an uploaded photo ready to be stored, both images are JPEGs
+*/
+type Processed struct {
+	Photo     []byte
+	Thumbnail []byte
+	Width     int
+	Height    int
+}
+
+/*
+Process - checks an uploaded JPEG, PNG or WebP and re-encodes it as a JPEG at most PhotoSize pixels on its longest side,
+along with a square ThumbnailSize thumbnail cropped from the middle.
+Re-encoding drops all of the original's metadata, including the EXIF GPS position phones add to photos,
+so the EXIF orientation is applied to the pixels first. Transparency is flattened onto white.
+The dimensions are checked before the image is decoded so a small file can't claim to be enormous
+*/
+func Process(data []byte) (*Processed, error) {
+	if len(data) > MaxFileSize {
+		return nil, ErrTooLarge
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+	if config.Width < MinDimension || config.Height < MinDimension || config.Width*config.Height > MaxMegapixels*1000000 {
+		return nil, ErrDimensions
+	}
+
+	original, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+
+	orientation := 1
+	if format == "jpeg" {
+		orientation = jpegOrientation(data)
+	}
+
+	photo := orient(fit(original, original.Bounds(), PhotoSize), orientation)
+	bounds := photo.Bounds()
+
+	// the thumbnail is cut from the resized photo, it's already far bigger than it needs
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	square := image.Rect(0, 0, side, side).Add(image.Pt((bounds.Dx()-side)/2, (bounds.Dy()-side)/2))
+	thumbnail := fit(photo, square, ThumbnailSize)
+
+	processed := &Processed{
+		Width:  bounds.Dx(),
+		Height: bounds.Dy(),
+	}
+	processed.Photo, err = encode(photo, photoQuality)
+	if err != nil {
+		return nil, err
+	}
+	processed.Thumbnail, err = encode(thumbnail, thumbQuality)
+	if err != nil {
+		return nil, err
+	}
+
+	return processed, nil
+}
+
+/*
+fit - the src rectangle of the image scaled down, never up, so its longest side is at most size, on a white background
+*/
+func fit(src image.Image, rect image.Rectangle, size int) *image.RGBA {
+	width, height := rect.Dx(), rect.Dy()
+	if width > size || height > size {
+		if width >= height {
+			width, height = size, max(1, height*size/width)
+		} else {
+			width, height = max(1, width*size/height), size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, rect, draw.Over, nil)
+
+	return dst
+}
+
+func encode(img image.Image, quality int) ([]byte, error) {
+	var encoded bytes.Buffer
+	err := jpeg.Encode(&encoded, img, &jpeg.Options{Quality: quality})
+	if err != nil {
+		return nil, err
+	}
+	return encoded.Bytes(), nil
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
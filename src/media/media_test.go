@@ -0,0 +1,127 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// secret - written into the GPS and description tags, none of it can survive processing
+const secret = "51.501364N 0.141890W"
+
+func TestProcessDropsMetadata(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 400, 300))
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 400; x++ {
+			src.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, src, nil); err != nil {
+		t.Fatal(err)
+	}
+	// the phone held on its side, orientation 6 turns it a quarter clockwise
+	data := withSegments(encoded.Bytes(), exifSegment(6), []byte{0xFF, 0xFE, 0x00, byte(2 + len(secret))}, []byte(secret))
+	if jpegOrientation(data) != 6 {
+		t.Fatal("test photo's EXIF isn't readable")
+	}
+
+	processed, err := Process(data)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if processed.Width != 300 || processed.Height != 400 {
+		t.Errorf("Process() = %dx%d, want the orientation applied, 300x400", processed.Width, processed.Height)
+	}
+
+	for name, output := range map[string][]byte{"photo": processed.Photo, "thumbnail": processed.Thumbnail} {
+		for _, marker := range markers(t, output) {
+			// APP1 holds EXIF and XMP, COM holds comments
+			if marker == 0xE1 || marker == 0xFE {
+				t.Errorf("%s still has a %#x segment", name, marker)
+			}
+		}
+		for _, leak := range []string{"Exif", secret, "GPS"} {
+			if bytes.Contains(output, []byte(leak)) {
+				t.Errorf("%s still contains %q", name, leak)
+			}
+		}
+		if jpegOrientation(output) != 1 {
+			t.Errorf("%s still has an orientation", name)
+		}
+	}
+}
+
+/*
+withSegments - the JPEG with the segments inserted straight after the start of image marker
+*/
+func withSegments(jpegData []byte, segments ...[]byte) []byte {
+	data := append([]byte(nil), jpegData[:2]...)
+	for _, segment := range segments {
+		data = append(data, segment...)
+	}
+	return append(data, jpegData[2:]...)
+}
+
+/*
+exifSegment - an APP1 segment with the orientation, an image description and a GPS IFD holding the secret
+*/
+func exifSegment(orientation uint16) []byte {
+	order := binary.BigEndian
+	var tiff bytes.Buffer
+	tiff.WriteString("MM")
+	binary.Write(&tiff, order, uint16(42))
+	binary.Write(&tiff, order, uint32(8))
+
+	// IFD0: orientation, description and the GPS IFD pointer, the values that don't fit follow it
+	const ifd0Entries = 3
+	descriptionAt := uint32(8 + 2 + ifd0Entries*12 + 4)
+	gpsAt := descriptionAt + uint32(len(secret)+1)
+	binary.Write(&tiff, order, uint16(ifd0Entries))
+	writeEntry(&tiff, 0x010E, 2, uint32(len(secret)+1), descriptionAt)
+	writeEntry(&tiff, 0x0112, 3, 1, uint32(orientation)<<16)
+	writeEntry(&tiff, 0x8825, 4, 1, gpsAt)
+	binary.Write(&tiff, order, uint32(0))
+	tiff.WriteString(secret + "\x00")
+
+	// GPS IFD: latitude ref and longitude ref
+	binary.Write(&tiff, order, uint16(2))
+	writeEntry(&tiff, 0x0001, 2, 2, uint32('N')<<24)
+	writeEntry(&tiff, 0x0003, 2, 2, uint32('W')<<24)
+	binary.Write(&tiff, order, uint32(0))
+
+	payload := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+func writeEntry(tiff *bytes.Buffer, tag, kind uint16, count, value uint32) {
+	binary.Write(tiff, binary.BigEndian, tag)
+	binary.Write(tiff, binary.BigEndian, kind)
+	binary.Write(tiff, binary.BigEndian, count)
+	binary.Write(tiff, binary.BigEndian, value)
+}
+
+/*
+markers - the markers of the segments before the image data
+*/
+func markers(t *testing.T, data []byte) []byte {
+	t.Helper()
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		t.Fatal("not a JPEG")
+	}
+	var found []byte
+	for offset := 2; offset+4 <= len(data); {
+		marker := data[offset+1]
+		if data[offset] != 0xFF || marker == 0xDA {
+			break
+		}
+		found = append(found, marker)
+		offset += 2 + int(binary.BigEndian.Uint16(data[offset+2:]))
+	}
+	return found
+}
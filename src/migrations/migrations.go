@@ -0,0 +1,140 @@
+package migrations
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+/*
+Migration - a single versioned schema change
+Each migration lives in sql/ as a pair of files named <version>_<name>.up.sql and <version>_<name>.down.sql
+Once a migration has been released it must never be edited, add a new one instead.
+The checksum of the up and down steps is recorded when it's applied so edits to either are detected on the next start up
+*/
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+
+	// upChecksum - what was recorded before the down step was checksummed too, see Runner.verify
+	upChecksum string
+}
+
+var fileNameRegex = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+/*
+Load - reads the embedded migrations ordered by version
+fails if a version is used twice or a migration is missing its up step
+*/
+func Load() ([]Migration, error) {
+	return load(files)
+}
+
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		parts := fileNameRegex.FindStringSubmatch(entry.Name())
+		if parts == nil {
+			return nil, fmt.Errorf("migration file %s does not match <version>_<name>.<up|down>.sql", entry.Name())
+		}
+
+		version, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, err
+		}
+
+		contents, err := fs.ReadFile(fsys, path.Join("sql", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: parts[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != parts[2] {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", version, migration.Name, parts[2])
+		}
+
+		if parts[3] == "up" {
+			migration.Up = string(contents)
+		} else {
+			migration.Down = string(contents)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up step", migration.Version, migration.Name)
+		}
+		migration.Checksum = checksum(migration.Up, migration.Down)
+		migration.upChecksum = sum(migration.Up)
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+/*
+checksum - covers both steps, hashing each first so moving text from the end of one to the start of the other is still a change
+*/
+func checksum(up, down string) string {
+	return sum(sum(up) + sum(down))
+}
+
+func sum(contents string) string {
+	sum := sha256.Sum256([]byte(contents))
+	return hex.EncodeToString(sum[:])
+}
+
+/*
+statements - splits a migration into the individual statements the driver can execute
+the mysql driver only runs one statement per Exec unless multiStatements is enabled on the DSN.
+Statements are split on a semicolon at the end of a line, so keep semicolons inside strings off line endings
+*/
+func statements(script string) []string {
+	var result []string
+	var current strings.Builder
+
+	for _, line := range strings.Split(script, "\n") {
+		current.WriteString(line)
+		current.WriteString("\n")
+
+		if strings.HasSuffix(strings.TrimSpace(line), ";") {
+			if statement := strings.TrimSpace(current.String()); statement != ";" {
+				result = append(result, statement)
+			}
+			current.Reset()
+		}
+	}
+
+	if statement := strings.TrimSpace(current.String()); statement != "" {
+		result = append(result, statement)
+	}
+
+	return result
+}
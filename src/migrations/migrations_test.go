@@ -0,0 +1,317 @@
+package migrations
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func sqlFiles(files map[string]string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for name, contents := range files {
+		fsys["sql/"+name] = &fstest.MapFile{Data: []byte(contents)}
+	}
+	return fsys
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := load(sqlFiles(map[string]string{
+		"0002_add_index.up.sql":      "CREATE INDEX a ON b (c);",
+		"0001_create_table.up.sql":   "CREATE TABLE b (c int);",
+		"0001_create_table.down.sql": "DROP TABLE b;",
+		"0010_no_down.up.sql":        "SELECT 1;",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, migration := range migrations {
+		names = append(names, migration.Name)
+	}
+	if want := []string{"create_table", "add_index", "no_down"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("loaded %v, want %v in version order", names, want)
+	}
+
+	first := migrations[0]
+	if first.Version != 1 || first.Up != "CREATE TABLE b (c int);" || first.Down != "DROP TABLE b;" {
+		t.Errorf("first = %+v", first)
+	}
+	if len(first.Checksum) != 64 {
+		t.Errorf("checksum = %q", first.Checksum)
+	}
+	if migrations[2].Down != "" {
+		t.Errorf("no_down has a down step %q", migrations[2].Down)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{
+			name: "duplicate version",
+			files: map[string]string{
+				"0001_create_table.up.sql": "CREATE TABLE b (c int);",
+				"0001_add_index.up.sql":    "CREATE INDEX a ON b (c);",
+			},
+			wantErr: "migration version 1 is used by both",
+		},
+		{
+			name: "duplicate version with different padding",
+			files: map[string]string{
+				"0001_create_table.up.sql": "CREATE TABLE b (c int);",
+				"1_add_index.up.sql":       "CREATE INDEX a ON b (c);",
+			},
+			wantErr: "migration version 1 is used by both",
+		},
+		{
+			name: "missing up step",
+			files: map[string]string{
+				"0001_create_table.up.sql": "CREATE TABLE b (c int);",
+				"0002_add_index.down.sql":  "DROP INDEX a ON b;",
+			},
+			wantErr: "migration 2_add_index has no up step",
+		},
+		{
+			name:    "no version",
+			files:   map[string]string{"create_table.up.sql": "CREATE TABLE b (c int);"},
+			wantErr: "does not match",
+		},
+		{
+			name:    "no direction",
+			files:   map[string]string{"0001_create_table.sql": "CREATE TABLE b (c int);"},
+			wantErr: "does not match",
+		},
+		{
+			name:    "unknown direction",
+			files:   map[string]string{"0001_create_table.sideways.sql": "CREATE TABLE b (c int);"},
+			wantErr: "does not match",
+		},
+		{
+			name:    "name with a dash",
+			files:   map[string]string{"0001_create-table.up.sql": "CREATE TABLE b (c int);"},
+			wantErr: "does not match",
+		},
+		{
+			name:    "not sql",
+			files:   map[string]string{"0001_create_table.up.txt": "CREATE TABLE b (c int);"},
+			wantErr: "does not match",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := load(sqlFiles(test.files))
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("err = %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+/*
+TestEmbeddedMigrations - the migrations that ship load, are numbered without gaps and can all be rolled back
+*/
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			t.Errorf("migration %d_%s, want version %d", migration.Version, migration.Name, i+1)
+		}
+		if migration.Down == "" {
+			t.Errorf("migration %d_%s has no down step", migration.Version, migration.Name)
+		}
+		if len(statements(migration.Up)) == 0 {
+			t.Errorf("migration %d_%s has no statements", migration.Version, migration.Name)
+		}
+	}
+}
+
+func TestChecksumCoversBothSteps(t *testing.T) {
+	up, down := "CREATE TABLE b (c int);\n", "DROP TABLE b;\n"
+	original := checksum(up, down)
+
+	if checksum(up+" ", down) == original {
+		t.Error("editing the up step didn't change the checksum")
+	}
+	if checksum(up, down+" ") == original {
+		t.Error("editing the down step didn't change the checksum")
+	}
+	if checksum(up+down, "") == original || checksum("", up+down) == original {
+		t.Error("moving text between the steps didn't change the checksum")
+	}
+	if checksum(up, down) != original {
+		t.Error("the checksum isn't stable")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	migrations, err := load(sqlFiles(map[string]string{
+		"0001_create_table.up.sql":   "CREATE TABLE b (c int);",
+		"0001_create_table.down.sql": "DROP TABLE b;",
+		"0002_add_index.up.sql":      "CREATE INDEX a ON b (c);",
+		"0002_add_index.down.sql":    "DROP INDEX a ON b;",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, second := migrations[0], migrations[1]
+
+	tests := []struct {
+		name         string
+		applied      []appliedMigration
+		wantOutdated []int
+		wantErr      error
+	}{
+		{
+			name: "nothing applied",
+		},
+		{
+			name: "all applied",
+			applied: []appliedMigration{
+				{version: 1, name: first.Name, checksum: first.Checksum},
+				{version: 2, name: second.Name, checksum: second.Checksum},
+			},
+		},
+		{
+			name:    "some applied",
+			applied: []appliedMigration{{version: 1, name: first.Name, checksum: first.Checksum}},
+		},
+		{
+			name: "up step edited",
+			applied: []appliedMigration{
+				{version: 1, name: first.Name, checksum: first.Checksum},
+				{version: 2, name: second.Name, checksum: checksum("CREATE INDEX a ON b (c, d);", second.Down)},
+			},
+			wantErr: ErrChecksumMismatch,
+		},
+		{
+			name: "down step edited",
+			applied: []appliedMigration{
+				{version: 1, name: first.Name, checksum: checksum(first.Up, "DROP TABLE IF EXISTS b;")},
+			},
+			wantErr: ErrChecksumMismatch,
+		},
+		{
+			name: "applied by a newer build",
+			applied: []appliedMigration{
+				{version: 1, name: first.Name, checksum: first.Checksum},
+				{version: 3, name: "from_the_future", checksum: first.Checksum},
+			},
+			wantErr: ErrUnknownMigration,
+		},
+		{
+			// recorded before the down step was checksummed, the up step is unchanged
+			name: "up step only checksum",
+			applied: []appliedMigration{
+				{version: 1, name: first.Name, checksum: sum(first.Up)},
+				{version: 2, name: second.Name, checksum: sum(second.Up)},
+			},
+			wantOutdated: []int{1, 2},
+		},
+		{
+			name: "up step only checksum of an edited up step",
+			applied: []appliedMigration{
+				{version: 1, name: first.Name, checksum: sum("CREATE TABLE b (c bigint);")},
+			},
+			wantErr: ErrChecksumMismatch,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			applied := map[int]appliedMigration{}
+			for _, migration := range test.applied {
+				applied[migration.version] = migration
+			}
+
+			outdated, err := check(migrations, applied)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("err = %v, want %v", err, test.wantErr)
+			}
+
+			var versions []int
+			for _, migration := range outdated {
+				versions = append(versions, migration.Version)
+			}
+			if !reflect.DeepEqual(versions, test.wantOutdated) {
+				t.Errorf("outdated = %v, want %v", versions, test.wantOutdated)
+			}
+		})
+	}
+}
+
+func TestStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name:   "single",
+			script: "ALTER TABLE users ADD COLUMN bio text;\n",
+			want:   []string{"ALTER TABLE users ADD COLUMN bio text;"},
+		},
+		{
+			name: "multiple over several lines",
+			script: `CREATE TABLE photos
+(
+	id int NOT NULL AUTO_INCREMENT,
+	PRIMARY KEY (id)
+);
+
+CREATE INDEX photos_user ON photos (user_id);
+`,
+			want: []string{
+				"CREATE TABLE photos\n(\n\tid int NOT NULL AUTO_INCREMENT,\n\tPRIMARY KEY (id)\n);",
+				"CREATE INDEX photos_user ON photos (user_id);",
+			},
+		},
+		{
+			name:   "windows line endings",
+			script: "UPDATE users SET a = 1;\r\nUPDATE users SET b = 2;\r\n",
+			want:   []string{"UPDATE users SET a = 1;", "UPDATE users SET b = 2;"},
+		},
+		{
+			name:   "trailing whitespace after the semicolon",
+			script: "UPDATE users SET a = 1;  \nUPDATE users SET b = 2;\t\n",
+			want:   []string{"UPDATE users SET a = 1;", "UPDATE users SET b = 2;"},
+		},
+		{
+			name:   "semicolon inside a line",
+			script: "UPDATE users SET bio = 'a;b' WHERE id = 1;\n",
+			want:   []string{"UPDATE users SET bio = 'a;b' WHERE id = 1;"},
+		},
+		{
+			name:   "last statement without a semicolon",
+			script: "UPDATE users SET a = 1;\nUPDATE users SET b = 2",
+			want:   []string{"UPDATE users SET a = 1;", "UPDATE users SET b = 2"},
+		},
+		{
+			name:   "empty statements",
+			script: "UPDATE users SET a = 1;\n;\n\n   ;\n",
+			want:   []string{"UPDATE users SET a = 1;"},
+		},
+		{
+			name:   "empty",
+			script: "\n  \n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := statements(test.script); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("statements = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/labstack/gommon/log"
+	"sort"
+	"time"
+)
+
+/*
+lockName - name of the MySQL advisory lock held while migrating
+GET_LOCK is server wide so two replicas starting together will queue behind each other instead of both migrating
+*/
+const lockName = "dating_app_schema_migrations"
+
+var ErrChecksumMismatch = errors.New("applied migration has been edited")
+var ErrUnknownMigration = errors.New("database has a migration this build doesn't know about")
+
+/*
+Runner - applies and rolls back migrations, recording them in the schema_migrations table
+*/
+type Runner struct {
+	db          *sql.DB
+	migrations  []Migration
+	LockTimeout time.Duration
+}
+
+func NewRunner(db *sql.DB) (*Runner, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Runner{
+		db:          db,
+		migrations:  migrations,
+		LockTimeout: 60 * time.Second,
+	}, nil
+}
+
+type appliedMigration struct {
+	version  int
+	name     string
+	checksum string
+}
+
+/*
+Up - applies every migration that hasn't been applied yet, in version order
+Before anything runs the applied migrations are checked against this build so an edited migration stops start up
+*/
+func (r *Runner) Up() error {
+	return r.withLock(func(conn *sql.Conn) error {
+		applied, err := r.verify(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range r.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			log.Infof("applying migration %d_%s", migration.Version, migration.Name)
+			err = execute(conn, migration.Up)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			_, err = conn.ExecContext(context.Background(), "INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?,?,?,?)",
+				migration.Version, migration.Name, migration.Checksum, time.Now().UTC())
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+/*
+Down - rolls back the most recently applied migrations, newest first
+*/
+func (r *Runner) Down(steps int) error {
+	return r.withLock(func(conn *sql.Conn) error {
+		applied, err := r.verify(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(r.migrations) - 1; i >= 0 && steps > 0; i-- {
+			migration := r.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down step", migration.Version, migration.Name)
+			}
+
+			log.Infof("rolling back migration %d_%s", migration.Version, migration.Name)
+			err = execute(conn, migration.Down)
+			if err != nil {
+				return fmt.Errorf("rollback %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			_, err = conn.ExecContext(context.Background(), "DELETE FROM schema_migrations WHERE version = ?", migration.Version)
+			if err != nil {
+				return err
+			}
+			steps--
+		}
+
+		return nil
+	})
+}
+
+/*
+verify - reads the applied migrations and makes sure every one of them matches this build
+*/
+func (r *Runner) verify(conn *sql.Conn) (map[int]appliedMigration, error) {
+	ctx := context.Background()
+
+	_, err := conn.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS schema_migrations
+(
+	version int NOT NULL,
+	name varchar(255) NOT NULL,
+	checksum char(64) NOT NULL,
+	applied_at datetime NOT NULL,
+	PRIMARY KEY (version)
+);`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT version, name, checksum FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]appliedMigration{}
+	for rows.Next() {
+		var migration appliedMigration
+		err = rows.Scan(&migration.version, &migration.name, &migration.checksum)
+		if err != nil {
+			return nil, err
+		}
+		applied[migration.version] = migration
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	outdated, err := check(r.migrations, applied)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, migration := range outdated {
+		_, err = conn.ExecContext(ctx, "UPDATE schema_migrations SET checksum = ? WHERE version = ?", migration.Checksum, migration.Version)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return applied, nil
+}
+
+/*
+check - makes sure every applied migration is one of this build's and hasn't been edited since it was applied
+returns the migrations whose recorded checksum only covers the up step, from before the down step was checksummed too,
+their up step is unchanged but the recorded checksum needs replacing. The down step can't be checked for those
+*/
+func check(migrations []Migration, applied map[int]appliedMigration) ([]Migration, error) {
+	known := map[int]Migration{}
+	for _, migration := range migrations {
+		known[migration.Version] = migration
+	}
+
+	versions := make([]int, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+
+	var outdated []Migration
+	for _, version := range versions {
+		migration := applied[version]
+		expected, ok := known[version]
+		if !ok {
+			return nil, fmt.Errorf("%w: %d_%s", ErrUnknownMigration, version, migration.name)
+		}
+
+		switch migration.checksum {
+		case expected.Checksum:
+		case expected.upChecksum:
+			outdated = append(outdated, expected)
+		default:
+			return nil, fmt.Errorf("%w: %d_%s", ErrChecksumMismatch, version, migration.name)
+		}
+	}
+
+	return outdated, nil
+}
+
+/*
+withLock - runs fn on a single connection while holding the migration lock
+the lock belongs to the connection, which is why everything has to run on the same one
+*/
+func (r *Runner) withLock(fn func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var acquired sql.NullInt64
+	err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, int(r.LockTimeout.Seconds())).Scan(&acquired)
+	if err != nil {
+		return err
+	}
+	if !acquired.Valid || acquired.Int64 != 1 {
+		return fmt.Errorf("timed out waiting for migration lock after %s", r.LockTimeout)
+	}
+	defer func() {
+		_, releaseErr := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", lockName)
+		if releaseErr != nil {
+			log.Error(releaseErr)
+		}
+	}()
+
+	return fn(conn)
+}
+
+/*
+execute - runs each statement of a migration script
+MySQL commits DDL implicitly so a migration that fails part way needs fixing by hand,
+keep each migration small enough that this is easy
+*/
+func execute(conn *sql.Conn, script string) error {
+	for _, statement := range statements(script) {
+		_, err := conn.ExecContext(context.Background(), statement)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS users
+(
+	id int auto_increment,
+	email varchar(255) NOT NULL,
+	password varchar(255) NOT NULL,
+	name varchar(255) NOT NULL,
+	gender int NOT NULL DEFAULT 2,
+	date_of_birth datetime,
+	latitude int,
+	longitude int,
+	likability int NOT NULL DEFAULT 0,
+	PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS matches
+(
+	user_id int,
+	match_user_id int,
+	state int default 0
+);
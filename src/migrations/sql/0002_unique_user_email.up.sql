@@ -0,0 +1,6 @@
+-- emails were never checked for uniqueness, keep the oldest account on its address and move the rest aside
+UPDATE users u
+JOIN users original ON original.email = u.email AND original.id < u.id
+SET u.email = CONCAT('duplicate-', u.id, '-', u.email);
+
+ALTER TABLE users ADD UNIQUE INDEX users_email_unique (email);
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS sessions
+(
+	id char(32) NOT NULL,
+	user_id int NOT NULL,
+	refresh_token_hash char(64) NOT NULL,
+	previous_token_hash char(64),
+	device_name varchar(255) NOT NULL DEFAULT '',
+	user_agent varchar(512) NOT NULL DEFAULT '',
+	ip_address varchar(45) NOT NULL DEFAULT '',
+	created_at datetime NOT NULL,
+	last_used_at datetime NOT NULL,
+	expires_at datetime NOT NULL,
+	revoked_at datetime,
+	PRIMARY KEY (id),
+	UNIQUE INDEX sessions_refresh_token_hash (refresh_token_hash),
+	INDEX sessions_previous_token_hash (previous_token_hash),
+	INDEX sessions_user_id (user_id)
+);
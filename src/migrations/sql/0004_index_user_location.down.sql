@@ -0,0 +1 @@
+DROP INDEX users_location ON users;
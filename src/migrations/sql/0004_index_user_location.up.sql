@@ -0,0 +1,2 @@
+-- lets the bounding box prefilter for max_distance use a range scan instead of reading every user
+CREATE INDEX users_location ON users (latitude, longitude);
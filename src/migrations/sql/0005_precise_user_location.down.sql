@@ -0,0 +1,4 @@
+ALTER TABLE users
+	DROP COLUMN last_located_at,
+	MODIFY latitude int,
+	MODIFY longitude int;
@@ -0,0 +1,6 @@
+-- coordinates were stored as whole degrees, DECIMAL(9,6) keeps them to about 10cm.
+-- existing values convert exactly, the precision they lost when they were saved can't be recovered
+ALTER TABLE users
+	MODIFY latitude DECIMAL(9,6),
+	MODIFY longitude DECIMAL(9,6),
+	ADD COLUMN last_located_at datetime;
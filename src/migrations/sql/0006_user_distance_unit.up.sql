@@ -0,0 +1,3 @@
+-- the unit distances are shown to the user in, most of our markets use kilometres
+ALTER TABLE users
+	ADD COLUMN distance_unit ENUM('km', 'mi') NOT NULL DEFAULT 'km';
@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS user_prompts;
+
+DROP TABLE IF EXISTS prompts;
+
+DROP TABLE IF EXISTS user_interests;
+
+DROP TABLE IF EXISTS interests;
+
+ALTER TABLE users
+	DROP COLUMN height_cm,
+	DROP COLUMN school,
+	DROP COLUMN job_title,
+	DROP COLUMN bio;
@@ -0,0 +1,96 @@
+-- the details a user fills in about themselves, lengths are enforced by interactors.validateDetails
+ALTER TABLE users
+	ADD COLUMN bio varchar(500) NOT NULL DEFAULT '',
+	ADD COLUMN job_title varchar(100) NOT NULL DEFAULT '',
+	ADD COLUMN school varchar(100) NOT NULL DEFAULT '',
+	ADD COLUMN height_cm smallint;
+
+-- the managed vocabulary of interest tags, retire a tag by clearing active rather than deleting it
+-- so users who already picked it keep it
+CREATE TABLE IF NOT EXISTS interests
+(
+	id int auto_increment,
+	slug varchar(50) NOT NULL,
+	name varchar(100) NOT NULL,
+	active tinyint(1) NOT NULL DEFAULT 1,
+	PRIMARY KEY (id),
+	UNIQUE INDEX interests_slug (slug)
+);
+
+INSERT INTO interests (slug, name) VALUES
+	('art', 'Art'),
+	('baking', 'Baking'),
+	('board-games', 'Board games'),
+	('camping', 'Camping'),
+	('climbing', 'Climbing'),
+	('coffee', 'Coffee'),
+	('comedy', 'Comedy'),
+	('cooking', 'Cooking'),
+	('cycling', 'Cycling'),
+	('dancing', 'Dancing'),
+	('dogs', 'Dogs'),
+	('cats', 'Cats'),
+	('fashion', 'Fashion'),
+	('film', 'Film'),
+	('fitness', 'Fitness'),
+	('football', 'Football'),
+	('gaming', 'Gaming'),
+	('gardening', 'Gardening'),
+	('hiking', 'Hiking'),
+	('live-music', 'Live music'),
+	('languages', 'Languages'),
+	('meditation', 'Meditation'),
+	('photography', 'Photography'),
+	('podcasts', 'Podcasts'),
+	('reading', 'Reading'),
+	('running', 'Running'),
+	('swimming', 'Swimming'),
+	('tech', 'Tech'),
+	('theatre', 'Theatre'),
+	('travel', 'Travel'),
+	('volunteering', 'Volunteering'),
+	('wine', 'Wine'),
+	('writing', 'Writing'),
+	('yoga', 'Yoga');
+
+CREATE TABLE IF NOT EXISTS user_interests
+(
+	user_id int NOT NULL,
+	interest_id int NOT NULL,
+	PRIMARY KEY (user_id, interest_id),
+	INDEX user_interests_interest_id (interest_id)
+);
+
+-- the catalog of questions users can answer on their profile, retired the same way as interests
+CREATE TABLE IF NOT EXISTS prompts
+(
+	id int auto_increment,
+	text varchar(255) NOT NULL,
+	active tinyint(1) NOT NULL DEFAULT 1,
+	PRIMARY KEY (id)
+);
+
+INSERT INTO prompts (text) VALUES
+	('A perfect day for me looks like'),
+	('I''m looking for'),
+	('My most irrational fear'),
+	('The way to win me over is'),
+	('Two truths and a lie'),
+	('My simple pleasures'),
+	('I''m weirdly attracted to'),
+	('The best trip I''ve taken'),
+	('Don''t hate me if I'),
+	('My go-to karaoke song'),
+	('I geek out on'),
+	('We''ll get along if');
+
+-- position orders a user's answers on their profile, a user answers each prompt at most once
+CREATE TABLE IF NOT EXISTS user_prompts
+(
+	user_id int NOT NULL,
+	prompt_id int NOT NULL,
+	position tinyint NOT NULL,
+	answer varchar(300) NOT NULL,
+	PRIMARY KEY (user_id, prompt_id),
+	UNIQUE INDEX user_prompts_position (user_id, position)
+);
@@ -0,0 +1,3 @@
+-- bumped by every PATCH /me, it's the ETag clients send back in If-Match so two devices can't overwrite each other's edits
+ALTER TABLE users
+	ADD COLUMN version int NOT NULL DEFAULT 1;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS discovery_genders;
+
+DROP TABLE IF EXISTS discovery_preferences;
@@ -0,0 +1,19 @@
+-- what a user wants to see in GET /profiles, users without a row see everyone
+-- max_distance is stored in miles whatever unit the user set it in
+CREATE TABLE IF NOT EXISTS discovery_preferences
+(
+	user_id int NOT NULL,
+	age_min tinyint unsigned,
+	age_max tinyint unsigned,
+	max_distance double,
+	sort varchar(20) NOT NULL DEFAULT '',
+	PRIMARY KEY (user_id)
+);
+
+-- the genders a user wants to see, no rows means every gender
+CREATE TABLE IF NOT EXISTS discovery_genders
+(
+	user_id int NOT NULL,
+	gender int NOT NULL,
+	PRIMARY KEY (user_id, gender)
+);
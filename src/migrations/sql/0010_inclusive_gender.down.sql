@@ -0,0 +1,18 @@
+-- genders outside of male and female go back to not specified, their descriptions are lost
+CREATE TABLE IF NOT EXISTS discovery_genders
+(
+	user_id int NOT NULL,
+	gender int NOT NULL,
+	PRIMARY KEY (user_id, gender)
+);
+
+INSERT IGNORE INTO discovery_genders (user_id, gender)
+SELECT user_id, CASE show_me WHEN 'men' THEN 0 WHEN 'women' THEN 1 ELSE 2 END FROM discovery_show_me;
+
+DROP TABLE IF EXISTS discovery_show_me;
+
+UPDATE users SET gender = CASE gender WHEN 'male' THEN '0' WHEN 'female' THEN '1' ELSE '2' END;
+
+ALTER TABLE users
+	DROP COLUMN gender_description,
+	MODIFY gender int NOT NULL DEFAULT 2;
@@ -0,0 +1,21 @@
+-- genders are stored as their code from models.Genders so the list can grow without a migration
+-- existing rows were 0 male, 1 female and 2 not specified
+ALTER TABLE users
+	MODIFY gender varchar(32) NOT NULL DEFAULT 'not_specified',
+	ADD COLUMN gender_description varchar(50) NOT NULL DEFAULT '' AFTER gender;
+
+UPDATE users SET gender = CASE gender WHEN '0' THEN 'male' WHEN '1' THEN 'female' ELSE 'not_specified' END;
+
+-- the groups of genders a user wants to see, no rows means everyone
+CREATE TABLE IF NOT EXISTS discovery_show_me
+(
+	user_id int NOT NULL,
+	show_me varchar(20) NOT NULL,
+	PRIMARY KEY (user_id, show_me)
+);
+
+-- not specified was the only option outside of male and female, so users who wanted to see it now see non-binary users
+INSERT IGNORE INTO discovery_show_me (user_id, show_me)
+SELECT user_id, CASE gender WHEN 0 THEN 'men' WHEN 1 THEN 'women' ELSE 'nonbinary' END FROM discovery_genders;
+
+DROP TABLE IF EXISTS discovery_genders;
@@ -0,0 +1,15 @@
+-- photos on a user's profile, the files are in blob storage under the keys
+-- position 0 is the primary photo, positions are kept contiguous as photos are added, removed and reordered
+CREATE TABLE IF NOT EXISTS photos
+(
+	id int NOT NULL AUTO_INCREMENT,
+	user_id int NOT NULL,
+	position tinyint unsigned NOT NULL,
+	storage_key varchar(255) NOT NULL,
+	thumbnail_key varchar(255) NOT NULL,
+	width smallint unsigned NOT NULL,
+	height smallint unsigned NOT NULL,
+	created_at datetime NOT NULL,
+	PRIMARY KEY (id),
+	KEY photos_user_position (user_id, position)
+);
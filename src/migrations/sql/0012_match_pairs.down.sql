@@ -0,0 +1,15 @@
+-- back to a row per pair recording who swiped first and the state, each user's own swipe is lost
+CREATE TABLE IF NOT EXISTS matches_by_user
+(
+	user_id int,
+	match_user_id int,
+	state int default 0
+);
+
+INSERT INTO matches_by_user (user_id, match_user_id, state)
+SELECT IF(low_swipe IS NOT NULL, low_user_id, high_user_id), IF(low_swipe IS NOT NULL, high_user_id, low_user_id), state
+FROM matches;
+
+DROP TABLE matches;
+
+RENAME TABLE matches_by_user TO matches;
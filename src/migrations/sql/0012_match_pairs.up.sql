@@ -0,0 +1,36 @@
+-- one row per pair of users, lower user id first, so two users swiping on each other at once can't create two rows
+-- each user's swipe is kept separately, NULL until they've swiped, and state is what the two add up to
+CREATE TABLE IF NOT EXISTS match_pairs
+(
+	low_user_id int NOT NULL,
+	high_user_id int NOT NULL,
+	low_swipe ENUM('YES', 'NO'),
+	high_swipe ENUM('YES', 'NO'),
+	state int NOT NULL DEFAULT 0,
+	matched_at datetime,
+	updated_at datetime NOT NULL,
+	PRIMARY KEY (low_user_id, high_user_id),
+	KEY match_pairs_high_user (high_user_id)
+);
+
+-- the old rows only recorded who swiped first and the state. Pending means they said yes, matched means both did,
+-- unmatched is recorded as a no from whoever swiped first because the row doesn't say who it was.
+-- Pairs with a row in each direction are merged, a no from either side wins
+INSERT INTO match_pairs (low_user_id, high_user_id, low_swipe, high_swipe, updated_at)
+SELECT LEAST(user_id, match_user_id), GREATEST(user_id, match_user_id),
+	CASE WHEN user_id < match_user_id THEN IF(state = 2, 'NO', 'YES') ELSE IF(state = 1, 'YES', NULL) END,
+	CASE WHEN user_id < match_user_id THEN IF(state = 1, 'YES', NULL) ELSE IF(state = 2, 'NO', 'YES') END,
+	UTC_TIMESTAMP()
+FROM matches
+WHERE user_id IS NOT NULL AND match_user_id IS NOT NULL AND user_id != match_user_id
+ON DUPLICATE KEY UPDATE
+	low_swipe = IF(low_swipe IS NULL OR VALUES(low_swipe) = 'NO', VALUES(low_swipe), low_swipe),
+	high_swipe = IF(high_swipe IS NULL OR VALUES(high_swipe) = 'NO', VALUES(high_swipe), high_swipe);
+
+UPDATE match_pairs SET
+	state = CASE WHEN low_swipe = 'NO' OR high_swipe = 'NO' THEN 2 WHEN low_swipe = 'YES' AND high_swipe = 'YES' THEN 1 ELSE 0 END,
+	matched_at = IF(state = 1, updated_at, NULL);
+
+DROP TABLE matches;
+
+RENAME TABLE match_pairs TO matches;
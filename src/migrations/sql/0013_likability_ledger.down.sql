@@ -0,0 +1,2 @@
+-- users.likability keeps the totals from the ledger
+DROP TABLE IF EXISTS likability_ledger;
@@ -0,0 +1,27 @@
+-- every change to a user's likability, users.likability is a cache of the total and can be rebuilt from it
+-- user_id is the user whose score changed and swiper_id the user whose swipe changed it.
+-- Changing a swipe reverses the entry for the old one, reverses_id is unique so an entry can only be reversed once
+CREATE TABLE IF NOT EXISTS likability_ledger
+(
+	id bigint NOT NULL AUTO_INCREMENT,
+	user_id int NOT NULL,
+	swiper_id int NOT NULL,
+	swipe ENUM('YES', 'NO') NOT NULL,
+	delta int NOT NULL,
+	reverses_id bigint,
+	created_at datetime NOT NULL,
+	PRIMARY KEY (id),
+	UNIQUE KEY likability_ledger_reverses (reverses_id),
+	KEY likability_ledger_user (user_id),
+	KEY likability_ledger_swiper (swiper_id, user_id)
+);
+
+-- the swipes that are already recorded, each one counts once
+INSERT INTO likability_ledger (user_id, swiper_id, swipe, delta, created_at)
+SELECT high_user_id, low_user_id, low_swipe, IF(low_swipe = 'YES', 1, -1), updated_at FROM matches WHERE low_swipe IS NOT NULL;
+
+INSERT INTO likability_ledger (user_id, swiper_id, swipe, delta, created_at)
+SELECT low_user_id, high_user_id, high_swipe, IF(high_swipe = 'YES', 1, -1), updated_at FROM matches WHERE high_swipe IS NOT NULL;
+
+-- scores from before the ledger drifted with every change of swipe, start again from the swipes as they are now
+UPDATE users SET likability = (SELECT COALESCE(SUM(delta), 0) FROM likability_ledger WHERE likability_ledger.user_id = users.id);
@@ -0,0 +1,7 @@
+-- saved preferences can't sort by desirability without the rating
+UPDATE discovery_preferences SET sort = '' WHERE sort = 'desirability';
+
+ALTER TABLE users
+	DROP COLUMN rated_at,
+	DROP COLUMN rating_deviation,
+	DROP COLUMN rating;
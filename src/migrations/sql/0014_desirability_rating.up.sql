@@ -0,0 +1,6 @@
+-- each user's desirability rating, see the rating package. Everyone starts unrated at 1500 with the widest deviation,
+-- rated_at is when a swipe last changed it and is NULL until the first one
+ALTER TABLE users
+	ADD COLUMN rating double NOT NULL DEFAULT 1500 AFTER likability,
+	ADD COLUMN rating_deviation double NOT NULL DEFAULT 350 AFTER rating,
+	ADD COLUMN rated_at datetime AFTER rating_deviation;
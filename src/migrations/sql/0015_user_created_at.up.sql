@@ -0,0 +1,5 @@
+-- when each user signed up, the ranked profile deck boosts new users.
+-- Accounts from before it was recorded get the time of their first login, or stay NULL if they never logged in
+ALTER TABLE users ADD COLUMN created_at datetime;
+
+UPDATE users SET created_at = (SELECT MIN(sessions.created_at) FROM sessions WHERE sessions.user_id = users.id);
@@ -0,0 +1,4 @@
+ALTER TABLE matches
+	ADD KEY match_pairs_high_user (high_user_id),
+	DROP KEY matches_high_user_state,
+	DROP KEY matches_low_user_state;
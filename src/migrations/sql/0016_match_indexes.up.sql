@@ -0,0 +1,7 @@
+-- a user's matches are listed most recent first, each side of the pair has an index leading with its user and the state
+-- so the matched rows are read in order without a scan. The pair's other user id is the rest of the primary key,
+-- which every secondary index carries, so they replace the index on high_user_id alone
+ALTER TABLE matches
+	ADD KEY matches_low_user_state (low_user_id, state, matched_at),
+	ADD KEY matches_high_user_state (high_user_id, state, matched_at),
+	DROP KEY match_pairs_high_user;
@@ -0,0 +1,88 @@
+package models
+
+import "time"
+
+/*
+ProfileSort - the orders profiles can be returned in
+every order ends with the profile id so that pages are stable
+*/
+type ProfileSort string
+
+const (
+	SortDefault      ProfileSort = ""
+	SortRecommended  ProfileSort = "recommended"
+	SortDistance     ProfileSort = "distance"
+	SortDesirability ProfileSort = "desirability"
+	SortRanked       ProfileSort = "ranked"
+)
+
+/*
+FilterOpts - the filters a user can apply when requesting profiles
+AgeMin and AgeMax are dates of birth rather than ages so they can be compared directly against the db,
+an empty Genders matches every gender
+Distances are measured in miles from Origin, the requesting user's location, and MaxDistance of 0 means no limit.
+ViewerAge and ViewerGender describe the requesting user, a candidate is only returned if the requesting user also fits
+the candidate's own discovery preferences
+After and Limit page through the results, a Limit of 0 returns everything after the cursor.
+ProfileIDs, when it isn't nil, only looks at those profiles, the rest of the filters still apply to them.
+Ratings are decayed to Now before profiles are sorted by desirability.
+Ranked profiles are scored once they've been fetched, the repository returns the nearest Limit of them and ignores After
+*/
+type FilterOpts struct {
+	AgeMin       *time.Time
+	AgeMax       *time.Time
+	Genders      []GenderType
+	Origin       Location
+	MaxDistance  float64
+	ViewerAge    int
+	ViewerGender GenderType
+	Sort         ProfileSort
+	Now          time.Time
+	After        *ProfileCursor
+	Limit        int
+	ProfileIDs   []int
+}
+
+/*
+ProfileCursor - position of the last profile on a page, in terms of the sort the page was requested with
+desirability and rank scores change over time, RatedAt is when they were worked out so the next page is sorted the same way
+*/
+type ProfileCursor struct {
+	Sort         ProfileSort `json:"s"`
+	ID           int         `json:"id"`
+	Likability   int         `json:"l,omitempty"`
+	Distance     float64     `json:"d,omitempty"`
+	Desirability float64     `json:"r,omitempty"`
+	Score        float64     `json:"k,omitempty"`
+	RatedAt      *time.Time  `json:"t,omitempty"`
+}
+
+/*
+Precedes - whether the profile comes after the cursor in the cursor's sort, the profile needs the value it was sorted on.
+Ranked profiles are paged through once they've been scored, so every profile comes after a ranked cursor
+*/
+func (c *ProfileCursor) Precedes(profile *Profile) bool {
+	switch c.Sort {
+	case SortRecommended:
+		likability := *profile.LikabilityScore
+		return likability < c.Likability || (likability == c.Likability && profile.ID > c.ID)
+	case SortDistance:
+		distance := *profile.Distance
+		return distance > c.Distance || (distance == c.Distance && profile.ID > c.ID)
+	case SortDesirability:
+		desirability := *profile.Desirability
+		return desirability < c.Desirability || (desirability == c.Desirability && profile.ID > c.ID)
+	case SortRanked:
+		return true
+	default:
+		return profile.ID > c.ID
+	}
+}
+
+/*
+ProfilePage - one page of profiles, NextCursor is nil on the last page
+*/
+type ProfilePage struct {
+	Profiles   []*Profile `json:"profiles"`
+	NextCursor *string    `json:"next_cursor"`
+}
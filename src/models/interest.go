@@ -0,0 +1,10 @@
+package models
+
+/*
+Interest - a tag from the managed vocabulary, clients refer to interests by their slug
+*/
+type Interest struct {
+	ID   int    `json:"-"`
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
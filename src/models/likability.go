@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+/*
+LikabilityEntry - a change to a user's likability, recorded in the ledger along with the swipe that caused it
+a reversal cancels an earlier entry when the swipe is changed, it has the opposite delta and ReversesID set to that entry
+*/
+type LikabilityEntry struct {
+	ID         int64
+	UserID     int
+	SwiperID   int
+	Swipe      Preference
+	Delta      int
+	ReversesID *int64
+	CreatedAt  time.Time
+}
+
+/*
+LikabilityRepair - a user whose cached likability didn't match the total of their ledger entries
+*/
+type LikabilityRepair struct {
+	UserID int
+	Cached int
+	Ledger int
+}
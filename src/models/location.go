@@ -0,0 +1,58 @@
+package models
+
+/*
+Location - a point on the earth in decimal degrees
+*/
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+/*
+DistanceUnit - the unit distances are shown to a user in, distances are always calculated in miles
+*/
+type DistanceUnit string
+
+const (
+	Kilometres DistanceUnit = "km"
+	Miles      DistanceUnit = "mi"
+)
+
+// KilometresPerMile - the international mile is exactly 1.609344 km
+const KilometresPerMile = 1.609344
+
+func (u DistanceUnit) Valid() bool {
+	return u == Kilometres || u == Miles
+}
+
+/*
+FromMiles - converts a distance in miles to this unit
+*/
+func (u DistanceUnit) FromMiles(miles float64) float64 {
+	if u == Kilometres {
+		return miles * KilometresPerMile
+	}
+	return miles
+}
+
+/*
+ToMiles - converts a distance in this unit to miles
+*/
+func (u DistanceUnit) ToMiles(distance float64) float64 {
+	if u == Kilometres {
+		return distance / KilometresPerMile
+	}
+	return distance
+}
+
+/*
+ApproximateDistance - how far away another user is, as shown to the requesting user
+Value is rounded into a bucket with jitter added so that exact distances can't be used to find someone,
+when LessThan is set the other user is less than Value away
+*/
+type ApproximateDistance struct {
+	Value    float64      `json:"value"`
+	Unit     DistanceUnit `json:"unit"`
+	LessThan bool         `json:"less_than,omitempty"`
+	Label    string       `json:"label"`
+}
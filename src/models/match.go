@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 /*
 MatchState - type to define states of a relationship
 Pending occurs when user1 swipes yes on user2
@@ -14,8 +16,92 @@ const (
 	Unmatched
 )
 
+/*
+Preference - a user's swipe on another user
+*/
+type Preference string
+
+const (
+	Yes Preference = "YES"
+	No  Preference = "NO"
+)
+
+func (p Preference) Valid() bool {
+	return p == Yes || p == No
+}
+
+/*
+Match - the relationship between a pair of users, there's only ever one per pair, stored with the lower user id first
+each user's swipe is kept separately, nil until they've swiped
+*/
 type Match struct {
-	UserID int `json:"userId"`
-	MatchID int `json:"matchId"`
-	State MatchState `json:"state"`
+	LowUserID  int
+	HighUserID int
+	LowSwipe   *Preference
+	HighSwipe  *Preference
+	State      MatchState
+	MatchedAt  *time.Time
+	UpdatedAt  time.Time
+}
+
+/*
+Pair - the two user ids in the order the pair is stored
+*/
+func Pair(userID, otherID int) (low, high int) {
+	if userID < otherID {
+		return userID, otherID
+	}
+	return otherID, userID
+}
+
+/*
+SwipeOf - the user's swipe on the other user in the pair, nil if they haven't swiped
+*/
+func (m *Match) SwipeOf(userID int) *Preference {
+	if userID == m.LowUserID {
+		return m.LowSwipe
+	}
+	return m.HighSwipe
+}
+
+/*
+MatchSummary - one of a user's matches as they see it, the other user's profile and when they matched.
+LastMessage is the latest message between them, users can't message each other yet so it's always nil
+*/
+type MatchSummary struct {
+	Profile     *Profile        `json:"profile"`
+	MatchedAt   time.Time       `json:"matched_at"`
+	LastMessage *MessagePreview `json:"last_message"`
+}
+
+/*
+MessagePreview - the start of a message, FromMe is set when the user it's shown to sent it
+*/
+type MessagePreview struct {
+	Body   string    `json:"body"`
+	SentAt time.Time `json:"sent_at"`
+	FromMe bool      `json:"from_me"`
+}
+
+/*
+MatchCursor - position of the last match on a page, matches are ordered by when they matched then by the other user's id
+*/
+type MatchCursor struct {
+	MatchedAt time.Time `json:"m"`
+	UserID    int       `json:"id"`
+}
+
+/*
+Precedes - whether the match comes after the cursor, most recent first
+*/
+func (c *MatchCursor) Precedes(matchedAt time.Time, userID int) bool {
+	return matchedAt.Before(c.MatchedAt) || (matchedAt.Equal(c.MatchedAt) && userID < c.UserID)
+}
+
+/*
+MatchPage - one page of a user's matches, NextCursor is nil on the last page
+*/
+type MatchPage struct {
+	Matches    []*MatchSummary `json:"matches"`
+	NextCursor *string         `json:"next_cursor"`
 }
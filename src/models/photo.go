@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+/*
+Photo - a picture on a user's profile, in the order the user arranged them. The first is their primary photo
+the files are kept in blob storage under Key and ThumbnailKey, the URLs are worked out from them when the photo is returned
+*/
+type Photo struct {
+	ID           int       `json:"id"`
+	UserID       int       `json:"-"`
+	Position     int       `json:"-"`
+	Key          string    `json:"-"`
+	ThumbnailKey string    `json:"-"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	Primary      bool      `json:"primary"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "math"
+
+/*
+DiscoveryPreferences - who a user wants to see when they request profiles
+nil limits and an empty ShowMe mean no restriction. MaxDistance is in Unit, repositories store it in miles
+*/
+type DiscoveryPreferences struct {
+	AgeMin      *int         `json:"age_min"`
+	AgeMax      *int         `json:"age_max"`
+	ShowMe      []ShowMe     `json:"show_me"`
+	MaxDistance *float64     `json:"max_distance"`
+	Unit        DistanceUnit `json:"unit"`
+	Sort        ProfileSort  `json:"sort"`
+}
+
+/*
+In - the same preferences with MaxDistance converted to unit, rounded to 2 decimal places
+so a distance converted there and back reads the same as it was set
+*/
+func (p DiscoveryPreferences) In(unit DistanceUnit) DiscoveryPreferences {
+	if p.Unit == unit || p.Unit == "" {
+		p.Unit = unit
+		return p
+	}
+
+	if p.MaxDistance != nil {
+		distance := math.Round(unit.FromMiles(p.Unit.ToMiles(*p.MaxDistance))*100) / 100
+		p.MaxDistance = &distance
+	}
+	p.Unit = unit
+
+	return p
+}
@@ -2,50 +2,162 @@ package models
 
 import (
 	"encoding/json"
-	"github.com/golang-jwt/jwt/v4"
 	"strings"
 	"time"
 )
 
 /*
-GenderType - represents the possible values for gender
-Will be stored in the db as an int and read to be returned as a string
+GenderType - the gender a user identifies as, one of the codes in Genders
+Stored in the db as the code and returned as the label, so the original Male, Female and Not Specified read as they always have.
 Users have the right not to specify their gender but this may affect how they are returned in results
 */
-type GenderType int
+type GenderType string
 
 const (
-	Male GenderType = iota
-	Female
-	NotSpecified
+	Male         GenderType = "male"
+	Female       GenderType = "female"
+	NonBinary    GenderType = "non_binary"
+	TransMan     GenderType = "trans_man"
+	TransWoman   GenderType = "trans_woman"
+	Genderqueer  GenderType = "genderqueer"
+	Genderfluid  GenderType = "genderfluid"
+	Agender      GenderType = "agender"
+	TwoSpirit    GenderType = "two_spirit"
+	NotSpecified GenderType = "not_specified"
 )
 
-func ToGenderTypeFromString(s string) GenderType {
-	switch s {
-	case "Male":
-		return Male
-	case "Female":
-		return Female
-	default:
-		return NotSpecified
+/*
+ShowMe - the groups of genders a user can choose to be shown, every gender belongs to at most one
+*/
+type ShowMe string
+
+const (
+	ShowMen       ShowMe = "men"
+	ShowWomen     ShowMe = "women"
+	ShowNonBinary ShowMe = "nonbinary"
+)
+
+/*
+GenderOption - an entry in the list of genders users can pick from
+*/
+type GenderOption struct {
+	Code   GenderType `json:"code"`
+	Label  string     `json:"label"`
+	ShowMe ShowMe     `json:"show_me,omitempty"`
+}
+
+/*
+Genders - every gender a user can identify as, add to the end to extend it.
+Users who don't specify a gender aren't in any show me group, so they're only shown to users who want to see everyone.
+Anyone can add their own description alongside the gender they pick
+*/
+var Genders = []GenderOption{
+	{Code: Male, Label: "Male", ShowMe: ShowMen},
+	{Code: Female, Label: "Female", ShowMe: ShowWomen},
+	{Code: NonBinary, Label: "Non-binary", ShowMe: ShowNonBinary},
+	{Code: TransMan, Label: "Trans man", ShowMe: ShowMen},
+	{Code: TransWoman, Label: "Trans woman", ShowMe: ShowWomen},
+	{Code: Genderqueer, Label: "Genderqueer", ShowMe: ShowNonBinary},
+	{Code: Genderfluid, Label: "Genderfluid", ShowMe: ShowNonBinary},
+	{Code: Agender, Label: "Agender", ShowMe: ShowNonBinary},
+	{Code: TwoSpirit, Label: "Two-Spirit", ShowMe: ShowNonBinary},
+	{Code: NotSpecified, Label: "Not Specified"},
+}
+
+/*
+MarshalJSON - the code is written as it is, rather than as the label GenderType marshals to
+*/
+func (o GenderOption) MarshalJSON() ([]byte, error) {
+	type option GenderOption
+	return json.Marshal(struct {
+		Code string `json:"code"`
+		option
+	}{Code: string(o.Code), option: option(o)})
+}
+
+/*
+ShowMeOptions - every show me group
+*/
+var ShowMeOptions = []ShowMe{ShowMen, ShowWomen, ShowNonBinary}
+
+/*
+ParseGender - reads a gender from its code or label, ignoring case
+*/
+func ParseGender(s string) (GenderType, bool) {
+	s = strings.TrimSpace(s)
+	for _, option := range Genders {
+		if strings.EqualFold(s, string(option.Code)) || strings.EqualFold(s, option.Label) {
+			return option.Code, true
+		}
 	}
+	return GenderType(strings.ToLower(s)), false
+}
+
+/*
+UnspecifiedOnly - the gender filter for only the users who didn't specify their gender.
+A filter of Not Specified on its own means any gender, as it did before there were more genders
+*/
+const UnspecifiedOnly = "unspecified_only"
+
+/*
+ParseGenderFilter - reads the genders a profiles request is narrowed down to, nil when it isn't narrowed down by gender.
+Not Specified alongside other genders includes the users who didn't specify theirs
+*/
+func ParseGenderFilter(values []string) []GenderType {
+	var genders []GenderType
+	legacyAny := len(values) == 1
+	for _, value := range values {
+		if strings.EqualFold(strings.TrimSpace(value), UnspecifiedOnly) {
+			genders = append(genders, NotSpecified)
+			legacyAny = false
+			continue
+		}
+		gender, _ := ParseGender(value)
+		genders = append(genders, gender)
+	}
+
+	if legacyAny && genders[0] == NotSpecified {
+		return nil
+	}
+	return genders
+}
+
+func (t GenderType) option() (GenderOption, bool) {
+	for _, option := range Genders {
+		if option.Code == t {
+			return option, true
+		}
+	}
+	return GenderOption{}, false
+}
+
+func (t GenderType) Valid() bool {
+	_, ok := t.option()
+	return ok
 }
 
 func (t GenderType) String() string {
-	switch t {
-	case Male:
-		return "Male"
-	case Female:
-		return "Female"
-	default:
-		return "Not Specified"
+	if option, ok := t.option(); ok {
+		return option.Label
 	}
+	return string(t)
+}
+
+/*
+ShowMe - the show me group the gender belongs to, empty for not specified
+*/
+func (t GenderType) ShowMe() ShowMe {
+	option, _ := t.option()
+	return option.ShowMe
 }
 
 func (t GenderType) MarshalJSON() ([]byte, error) {
 	return json.Marshal(t.String())
 }
 
+/*
+UnmarshalJSON - accepts a code or a label, anything else is kept as it was sent so validation can report it
+*/
 func (t *GenderType) UnmarshalJSON(b []byte) error {
 	var gender string
 	err := json.Unmarshal(b, &gender)
@@ -53,49 +165,76 @@ func (t *GenderType) UnmarshalJSON(b []byte) error {
 		return err
 	}
 
-	switch strings.ToLower(gender) {
-	case "male":
-		*t = Male
-	case "female":
-		*t = Female
-	default:
-		*t = NotSpecified
-	}
+	*t, _ = ParseGender(gender)
 
 	return nil
 }
 
+func (s ShowMe) Valid() bool {
+	for _, option := range ShowMeOptions {
+		if s == option {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+GendersFor - every gender in the show me groups
+*/
+func GendersFor(showMe []ShowMe) []GenderType {
+	var genders []GenderType
+	for _, option := range Genders {
+		for _, group := range showMe {
+			if option.ShowMe != "" && option.ShowMe == group {
+				genders = append(genders, option.Code)
+			}
+		}
+	}
+	return genders
+}
+
 /*
 	User - struct is used for creating users.
 	This is because we don't want to return login credentials when retrieving profiles
 */
 type User struct {
 	Email    string `json:"email"`
-	Password string `json:"password"`
+	Password string `json:"password,omitempty"`
+	LastLocatedAt *time.Time `json:"-"`
+	DistanceUnit DistanceUnit `json:"distance_unit"`
+	Version int `json:"-"`
+	Preferences *DiscoveryPreferences `json:"preferences,omitempty"`
 	Profile
 }
 
 /*
 	Profile - holds all non-sensitive user information. Used when getting profiles
+	Distance is the exact distance in miles, it never leaves the API, only ApproximateDistance is returned
+	Desirability never leaves the API either, it's used to page through profiles sorted by it
+	JoinedAt and ActiveAt are when the user signed up and last used a session, nil if it isn't known, they're used to rank profiles
 */
 type Profile struct {
 	ID       int `json:"id"`
 	Name     string `json:"name"`
 	Gender   GenderType `json:"gender"`
+	GenderDescription string `json:"gender_description,omitempty"`
 	DateOfBirth      time.Time `json:"-"`
 	Age      int `json:"age"`
+	Bio string `json:"bio"`
+	JobTitle string `json:"job_title"`
+	School string `json:"school"`
+	HeightCM *int `json:"height_cm,omitempty"`
+	Interests []Interest `json:"interests"`
+	Prompts []PromptAnswer `json:"prompts"`
+	Photos []Photo `json:"photos"`
 	Latitude float64 `json:"-"`
 	Longitude float64 `json:"-"`
-	Distance *float64 `json:"distance,omitempty"`
+	Distance *float64 `json:"-"`
+	ApproximateDistance *ApproximateDistance `json:"distance,omitempty"`
 	LikabilityScore *int `json:"likability,omitempty"`
+	Desirability *float64 `json:"-"`
+	JoinedAt *time.Time `json:"-"`
+	ActiveAt *time.Time `json:"-"`
+	Ranking *RankExplanation `json:"ranking,omitempty"`
 }
-
-/*
- Used for generating tokens
-*/
-var JWT_KEY = []byte("my_secret_key")
-
-type Claims struct {
-	UserID int `json:"user_id"`
-	jwt.RegisteredClaims
-}
\ No newline at end of file
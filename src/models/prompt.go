@@ -0,0 +1,18 @@
+package models
+
+/*
+Prompt - a question from the catalog users can answer on their profile
+*/
+type Prompt struct {
+	ID   int    `json:"id"`
+	Text string `json:"text"`
+}
+
+/*
+PromptAnswer - a user's answer to a prompt, in the order they're shown on the profile
+*/
+type PromptAnswer struct {
+	PromptID int    `json:"prompt_id"`
+	Prompt   string `json:"prompt"`
+	Answer   string `json:"answer"`
+}
@@ -0,0 +1,21 @@
+package models
+
+/*
+RankExplanation - why a profile is where it is in the ranked deck, its score is the total of each feature's contribution
+only returned to clients in debug mode, to help tune the weights
+*/
+type RankExplanation struct {
+	Experiment string         `json:"experiment"`
+	Score      float64        `json:"score"`
+	Features   []FeatureScore `json:"features"`
+}
+
+/*
+FeatureScore - how a profile scored on one feature, from 0 to 1, and what that added to its rank after weighting
+*/
+type FeatureScore struct {
+	Feature      string  `json:"feature"`
+	Score        float64 `json:"score"`
+	Weight       float64 `json:"weight"`
+	Contribution float64 `json:"contribution"`
+}
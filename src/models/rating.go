@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+/*
+Rating - a user's desirability rating, how likely they are to be liked, along with how sure of it we are.
+Every user starts unrated, see the rating package for how swipes move it
+*/
+type Rating struct {
+	Value     float64
+	Deviation float64
+	RatedAt   *time.Time
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+/*
+Session - a login on one device
+The refresh token itself is never stored, only its sha256 hash.
+Refresh tokens are rotated on every use, the hash of the one it replaced is kept so a stolen
+token being replayed can be detected and the session revoked
+*/
+type Session struct {
+	ID                string
+	UserID            int
+	RefreshTokenHash  string
+	PreviousTokenHash string
+	DeviceName        string
+	UserAgent         string
+	IPAddress         string
+	CreatedAt         time.Time
+	LastUsedAt        time.Time
+	ExpiresAt         time.Time
+	RevokedAt         *time.Time
+}
+
+/*
+Active - the session hasn't been logged out and its refresh token hasn't expired
+*/
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+/*
+TokenPair - what a client receives when logging in or refreshing
+*/
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
@@ -0,0 +1,161 @@
+package passwords
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+/*
+Params - argon2id cost parameters
+They are stored alongside every hash so they can be raised later without breaking existing passwords,
+users are rehashed with the new parameters the next time they log in
+*/
+type Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+/*
+DefaultParams - the parameters new hashes are created with, following the OWASP argon2id recommendation
+*/
+var DefaultParams = Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+var ErrInvalidHash = errors.New("invalid password hash")
+
+/*
+ErrPlaintext - the stored value is a legacy plaintext password rather than a hash, see VerifyPlaintext
+*/
+var ErrPlaintext = errors.New("password is stored in plaintext")
+
+const argon2idPrefix = "$argon2id$"
+
+var bcryptPrefixes = []string{"$2a$", "$2b$", "$2y$"}
+
+/*
+Hash - hashes the password with argon2id, encoded in the PHC string format
+	$argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+*/
+func Hash(password string) (string, error) {
+	return hashWithParams(password, DefaultParams)
+}
+
+func hashWithParams(password string, params Params) (string, error) {
+	salt := make([]byte, params.SaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, params.Memory, params.Iterations, params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+/*
+Verify - checks the password against the stored value in constant time
+needsRehash is true when the password matched but the stored value should be replaced with Hash(password),
+either because it was created with outdated parameters or by bcrypt.
+Returns ErrPlaintext if the stored value is a plaintext password from before hashing, they're only checked by VerifyPlaintext
+*/
+func Verify(password, stored string) (match bool, needsRehash bool, err error) {
+	switch {
+	case strings.HasPrefix(stored, argon2idPrefix):
+		params, salt, key, err := decode(stored)
+		if err != nil {
+			return false, false, err
+		}
+
+		candidate := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(candidate, key) != 1 {
+			return false, false, nil
+		}
+
+		params.SaltLength = uint32(len(salt))
+		params.KeyLength = uint32(len(key))
+		return true, params != DefaultParams, nil
+	case IsBcrypt(stored):
+		err := bcrypt.CompareHashAndPassword([]byte(stored), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		if err != nil {
+			return false, false, err
+		}
+		return true, true, nil
+	default:
+		return false, false, ErrPlaintext
+	}
+}
+
+/*
+VerifyPlaintext - checks the password against a legacy plaintext row in constant time, a match always needs rehashing
+*/
+func VerifyPlaintext(password, stored string) bool {
+	return subtle.ConstantTimeCompare([]byte(password), []byte(stored)) == 1
+}
+
+/*
+IsHashed - reports whether the stored value is a hash rather than a legacy plaintext password
+*/
+func IsHashed(stored string) bool {
+	return strings.HasPrefix(stored, argon2idPrefix) || IsBcrypt(stored)
+}
+
+func IsBcrypt(stored string) bool {
+	for _, prefix := range bcryptPrefixes {
+		if strings.HasPrefix(stored, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func decode(stored string) (Params, []byte, []byte, error) {
+	params := Params{}
+
+	parts := strings.Split(stored, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	// argon2 panics rather than returning an error for zero iterations or parallelism
+	if err != nil || params.Iterations < 1 || params.Parallelism < 1 {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil || len(salt) == 0 {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	return params, salt, key, nil
+}
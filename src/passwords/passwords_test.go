@@ -0,0 +1,167 @@
+package passwords
+
+import (
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"testing"
+)
+
+/*
+testParams - cheap parameters so the tests run quickly, they also count as outdated against DefaultParams
+*/
+var testParams = Params{
+	Memory:      1024,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func TestHashVerify(t *testing.T) {
+	hash, err := Hash("Correct horse 42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=3,p=2$") {
+		t.Errorf("hash = %q", hash)
+	}
+	if !IsHashed(hash) {
+		t.Error("IsHashed = false")
+	}
+
+	match, needsRehash, err := Verify("Correct horse 42", hash)
+	if err != nil || !match || needsRehash {
+		t.Errorf("Verify = %v, %v, %v, want a match that doesn't need rehashing", match, needsRehash, err)
+	}
+
+	match, needsRehash, err = Verify("Correct horse 43", hash)
+	if err != nil || match || needsRehash {
+		t.Errorf("wrong password Verify = %v, %v, %v", match, needsRehash, err)
+	}
+
+	other, err := Hash("Correct horse 42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == hash {
+		t.Error("two hashes of the same password share a salt")
+	}
+}
+
+func TestVerifyOutdatedParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		params Params
+	}{
+		{name: "memory", params: testParams},
+		{name: "iterations", params: Params{Memory: DefaultParams.Memory, Iterations: 1, Parallelism: 2, SaltLength: 16, KeyLength: 32}},
+		{name: "salt length", params: Params{Memory: DefaultParams.Memory, Iterations: 3, Parallelism: 2, SaltLength: 8, KeyLength: 32}},
+		{name: "key length", params: Params{Memory: DefaultParams.Memory, Iterations: 3, Parallelism: 2, SaltLength: 16, KeyLength: 16}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hash, err := hashWithParams("Correct horse 42", test.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			match, needsRehash, err := Verify("Correct horse 42", hash)
+			if err != nil || !match || !needsRehash {
+				t.Errorf("Verify = %v, %v, %v, want a match that needs rehashing", match, needsRehash, err)
+			}
+
+			match, needsRehash, err = Verify("wrong", hash)
+			if err != nil || match || needsRehash {
+				t.Errorf("wrong password Verify = %v, %v, %v", match, needsRehash, err)
+			}
+		})
+	}
+}
+
+func TestVerifyBcrypt(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("Correct horse 42"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsBcrypt(string(hash)) || !IsHashed(string(hash)) {
+		t.Errorf("%q isn't recognised as bcrypt", hash)
+	}
+
+	match, needsRehash, err := Verify("Correct horse 42", string(hash))
+	if err != nil || !match || !needsRehash {
+		t.Errorf("Verify = %v, %v, %v, want a match that needs rehashing", match, needsRehash, err)
+	}
+
+	match, needsRehash, err = Verify("Correct horse 43", string(hash))
+	if err != nil || match || needsRehash {
+		t.Errorf("wrong password Verify = %v, %v, %v", match, needsRehash, err)
+	}
+
+	_, _, err = Verify("Correct horse 42", string(hash[:20]))
+	if err == nil {
+		t.Error("truncated bcrypt hash verified without an error")
+	}
+}
+
+func TestVerifyPlaintext(t *testing.T) {
+	_, _, err := Verify("Correct horse 42", "Correct horse 42")
+	if !errors.Is(err, ErrPlaintext) {
+		t.Errorf("err = %v, want ErrPlaintext", err)
+	}
+	if IsHashed("Correct horse 42") {
+		t.Error("plaintext IsHashed")
+	}
+
+	if !VerifyPlaintext("Correct horse 42", "Correct horse 42") {
+		t.Error("VerifyPlaintext didn't match")
+	}
+	if VerifyPlaintext("Correct horse 42", "Correct horse 4") {
+		t.Error("VerifyPlaintext matched a prefix")
+	}
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	hash, err := hashWithParams("Correct horse 42", testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(hash, "$")
+	salt, key := parts[4], parts[5]
+
+	tests := []struct {
+		name   string
+		stored string
+	}{
+		{name: "prefix only", stored: "$argon2id$"},
+		{name: "truncated after version", stored: "$argon2id$v=19$"},
+		{name: "truncated after params", stored: "$argon2id$v=19$m=1024,t=1,p=1$" + salt},
+		{name: "missing key", stored: "$argon2id$v=19$m=1024,t=1,p=1$" + salt + "$"},
+		{name: "truncated key", stored: hash[:len(hash)-1] + "$"},
+		{name: "extra part", stored: hash + "$extra"},
+		{name: "wrong version", stored: "$argon2id$v=16$m=1024,t=1,p=1$" + salt + "$" + key},
+		{name: "bad version", stored: "$argon2id$version$m=1024,t=1,p=1$" + salt + "$" + key},
+		{name: "missing params", stored: "$argon2id$v=19$m=1024$" + salt + "$" + key},
+		{name: "bad params", stored: "$argon2id$v=19$m=x,t=1,p=1$" + salt + "$" + key},
+		{name: "zero iterations", stored: "$argon2id$v=19$m=1024,t=0,p=1$" + salt + "$" + key},
+		{name: "zero parallelism", stored: "$argon2id$v=19$m=1024,t=1,p=0$" + salt + "$" + key},
+		{name: "parallelism overflow", stored: "$argon2id$v=19$m=1024,t=1,p=256$" + salt + "$" + key},
+		{name: "negative memory", stored: "$argon2id$v=19$m=-1,t=1,p=1$" + salt + "$" + key},
+		{name: "salt not base64", stored: "$argon2id$v=19$m=1024,t=1,p=1$!!!$" + key},
+		{name: "empty salt", stored: "$argon2id$v=19$m=1024,t=1,p=1$$" + key},
+		{name: "key not base64", stored: "$argon2id$v=19$m=1024,t=1,p=1$" + salt + "$!!!"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			match, needsRehash, err := Verify("Correct horse 42", test.stored)
+			if !errors.Is(err, ErrInvalidHash) {
+				t.Errorf("err = %v, want ErrInvalidHash", err)
+			}
+			if match || needsRehash {
+				t.Errorf("Verify = %v, %v", match, needsRehash)
+			}
+		})
+	}
+}
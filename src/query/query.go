@@ -0,0 +1,262 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+Cond - a fragment of a WHERE clause and the values for its placeholders
+SQL must only ever contain identifiers and operators written in code, every value goes in Args
+and is sent to the database as a parameter
+*/
+type Cond struct {
+	SQL  string
+	Args []any
+}
+
+/*
+Expr - a condition written out by hand, with a ? for each value
+*/
+func Expr(sql string, args ...any) Cond {
+	return Cond{SQL: sql, Args: args}
+}
+
+func Eq(column string, value any) Cond {
+	return Expr(column+" = ?", value)
+}
+
+func NotEq(column string, value any) Cond {
+	return Expr(column+" != ?", value)
+}
+
+func Lt(column string, value any) Cond {
+	return Expr(column+" < ?", value)
+}
+
+func Lte(column string, value any) Cond {
+	return Expr(column+" <= ?", value)
+}
+
+func Gt(column string, value any) Cond {
+	return Expr(column+" > ?", value)
+}
+
+func Gte(column string, value any) Cond {
+	return Expr(column+" >= ?", value)
+}
+
+/*
+In - column IN (?, ?, ...), an empty list matches nothing
+*/
+func In(column string, values ...any) Cond {
+	if len(values) == 0 {
+		return Expr("1 = 0")
+	}
+	return Expr(column+" IN ("+placeholders(len(values))+")", values...)
+}
+
+/*
+NotIn - column NOT IN (?, ?, ...), an empty list matches everything
+*/
+func NotIn(column string, values ...any) Cond {
+	if len(values) == 0 {
+		return Expr("1 = 1")
+	}
+	return Expr(column+" NOT IN ("+placeholders(len(values))+")", values...)
+}
+
+/*
+And - every condition must hold, empty conditions are skipped
+*/
+func And(conds ...Cond) Cond {
+	return join(" AND ", conds)
+}
+
+/*
+Or - at least one condition must hold, empty conditions are skipped
+*/
+func Or(conds ...Cond) Cond {
+	return join(" OR ", conds)
+}
+
+func join(separator string, conds []Cond) Cond {
+	var nonEmpty []Cond
+	for _, cond := range conds {
+		if cond.SQL != "" {
+			nonEmpty = append(nonEmpty, cond)
+		}
+	}
+
+	if len(nonEmpty) == 1 {
+		return nonEmpty[0]
+	}
+
+	parts := make([]string, 0, len(nonEmpty))
+	var args []any
+	for _, cond := range nonEmpty {
+		parts = append(parts, "("+cond.SQL+")")
+		args = append(args, cond.Args...)
+	}
+	return Cond{SQL: strings.Join(parts, separator), Args: args}
+}
+
+func placeholders(count int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", count), ", ")
+}
+
+/*
+SelectBuilder - builds a SELECT statement from optional parts
+
+	query.Select("id", "name").From("users").Where(query.Eq("id", 1)).Build()
+*/
+type SelectBuilder struct {
+	columns  []Cond
+	from     string
+	fromArgs []any
+	fromSub  *SelectBuilder
+	where    []Cond
+	orderBy  []string
+	limit    *int
+}
+
+func Select(columns ...string) *SelectBuilder {
+	b := &SelectBuilder{}
+	for _, column := range columns {
+		b.columns = append(b.columns, Cond{SQL: column})
+	}
+	return b
+}
+
+/*
+Column - adds a computed column with placeholders, like "ABS(latitude - ?) AS offset"
+*/
+func (b *SelectBuilder) Column(expr string, args ...any) *SelectBuilder {
+	b.columns = append(b.columns, Expr(expr, args...))
+	return b
+}
+
+/*
+From - the table, or a subquery with placeholders for its args
+*/
+func (b *SelectBuilder) From(table string, args ...any) *SelectBuilder {
+	b.from = table
+	b.fromArgs = args
+	b.fromSub = nil
+	return b
+}
+
+/*
+FromSubquery - selects from a derived table, so the outer query can filter and order on the subquery's computed columns
+*/
+func (b *SelectBuilder) FromSubquery(sub *SelectBuilder, alias string) *SelectBuilder {
+	b.from = alias
+	b.fromArgs = nil
+	b.fromSub = sub
+	return b
+}
+
+/*
+Where - adds conditions that must all hold
+*/
+func (b *SelectBuilder) Where(conds ...Cond) *SelectBuilder {
+	b.where = append(b.where, conds...)
+	return b
+}
+
+/*
+WhereIf - adds the condition only when include is true, for optional filters
+*/
+func (b *SelectBuilder) WhereIf(include bool, cond Cond) *SelectBuilder {
+	if include {
+		b.where = append(b.where, cond)
+	}
+	return b
+}
+
+/*
+OrderBy - adds ordering terms, like "likability DESC"
+*/
+func (b *SelectBuilder) OrderBy(terms ...string) *SelectBuilder {
+	b.orderBy = append(b.orderBy, terms...)
+	return b
+}
+
+/*
+Limit - the number of rows is always sent as a parameter
+*/
+func (b *SelectBuilder) Limit(limit int) *SelectBuilder {
+	b.limit = &limit
+	return b
+}
+
+/*
+Build - the SQL and its args in placeholder order
+fails if any part has a different number of placeholders to args, which would otherwise only show up as a
+confusing driver error or, worse, a query that binds values to the wrong placeholders
+*/
+func (b *SelectBuilder) Build() (string, []any, error) {
+	if len(b.columns) == 0 || b.from == "" {
+		return "", nil, fmt.Errorf("select needs columns and a table")
+	}
+
+	var sql strings.Builder
+	var args []any
+
+	columns := make([]string, 0, len(b.columns))
+	for _, column := range b.columns {
+		if err := checkPlaceholders(column.SQL, column.Args); err != nil {
+			return "", nil, err
+		}
+		columns = append(columns, column.SQL)
+		args = append(args, column.Args...)
+	}
+
+	sql.WriteString("SELECT ")
+	sql.WriteString(strings.Join(columns, ", "))
+	sql.WriteString(" FROM ")
+
+	if b.fromSub != nil {
+		subSQL, subArgs, err := b.fromSub.Build()
+		if err != nil {
+			return "", nil, err
+		}
+		sql.WriteString("(" + subSQL + ") AS " + b.from)
+		args = append(args, subArgs...)
+	} else {
+		if err := checkPlaceholders(b.from, b.fromArgs); err != nil {
+			return "", nil, err
+		}
+		sql.WriteString(b.from)
+		args = append(args, b.fromArgs...)
+	}
+
+	where := And(b.where...)
+	if where.SQL != "" {
+		if err := checkPlaceholders(where.SQL, where.Args); err != nil {
+			return "", nil, err
+		}
+		sql.WriteString(" WHERE ")
+		sql.WriteString(where.SQL)
+		args = append(args, where.Args...)
+	}
+
+	if len(b.orderBy) > 0 {
+		sql.WriteString(" ORDER BY ")
+		sql.WriteString(strings.Join(b.orderBy, ", "))
+	}
+
+	if b.limit != nil {
+		sql.WriteString(" LIMIT ?")
+		args = append(args, *b.limit)
+	}
+
+	return sql.String(), args, nil
+}
+
+func checkPlaceholders(sql string, args []any) error {
+	if count := strings.Count(sql, "?"); count != len(args) {
+		return fmt.Errorf("%q has %d placeholders but %d args", sql, count, len(args))
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package query
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// injection - a value that would break out of the query if it were ever written into the SQL
+const injection = "x' OR '1'='1"
+
+func TestSelectBuilderBuild(t *testing.T) {
+	bornBefore := time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		query *SelectBuilder
+		sql   string
+		args  []any
+	}{
+		{
+			name:  "columns and table",
+			query: Select("id", "name").From("users"),
+			sql:   "SELECT id, name FROM users",
+			args:  nil,
+		},
+		{
+			name:  "where",
+			query: Select("id").From("users").Where(Eq("email", injection)),
+			sql:   "SELECT id FROM users WHERE email = ?",
+			args:  []any{injection},
+		},
+		{
+			name:  "several conditions are and'ed",
+			query: Select("id").From("users").Where(Gte("age", 18), Lte("distance", 12.5), NotEq("id", 7)),
+			sql:   "SELECT id FROM users WHERE (age >= ?) AND (distance <= ?) AND (id != ?)",
+			args:  []any{18, 12.5, 7},
+		},
+		{
+			name: "where if included",
+			query: Select("id").From("users").
+				WhereIf(true, Lt("date_of_birth", bornBefore)).
+				WhereIf(true, Lte("distance", 50.0)),
+			sql:  "SELECT id FROM users WHERE (date_of_birth < ?) AND (distance <= ?)",
+			args: []any{bornBefore, 50.0},
+		},
+		{
+			name: "where if left out",
+			query: Select("id").From("users").
+				WhereIf(false, Lt("date_of_birth", bornBefore)).
+				WhereIf(true, Gt("id", 3)).
+				WhereIf(false, Lte("distance", injection)),
+			sql:  "SELECT id FROM users WHERE id > ?",
+			args: []any{3},
+		},
+		{
+			name:  "expr",
+			query: Select("id").From("users").Where(Expr("latitude BETWEEN ? AND ?", 51.1, 51.9)),
+			sql:   "SELECT id FROM users WHERE latitude BETWEEN ? AND ?",
+			args:  []any{51.1, 51.9},
+		},
+		{
+			name:  "expr without args",
+			query: Select("id").From("users").Where(Expr("distance IS NOT NULL")),
+			sql:   "SELECT id FROM users WHERE distance IS NOT NULL",
+			args:  nil,
+		},
+		{
+			name: "or and and nest",
+			query: Select("id").From("users").Where(Or(
+				Lt("likability", 4),
+				And(Eq("likability", 4), Gt("id", 10)),
+			)),
+			sql:  "SELECT id FROM users WHERE (likability < ?) OR ((likability = ?) AND (id > ?))",
+			args: []any{4, 4, 10},
+		},
+		{
+			name:  "in",
+			query: Select("id").From("users").Where(In("gender", "male", injection)),
+			sql:   "SELECT id FROM users WHERE gender IN (?, ?)",
+			args:  []any{"male", injection},
+		},
+		{
+			name:  "empty in matches nothing",
+			query: Select("id").From("users").Where(In("gender")),
+			sql:   "SELECT id FROM users WHERE 1 = 0",
+			args:  nil,
+		},
+		{
+			name:  "empty not in matches everything",
+			query: Select("id").From("users").Where(NotIn("id")),
+			sql:   "SELECT id FROM users WHERE 1 = 1",
+			args:  nil,
+		},
+		{
+			name:  "ordering",
+			query: Select("id").From("users").OrderBy("likability DESC").OrderBy("id"),
+			sql:   "SELECT id FROM users ORDER BY likability DESC, id",
+			args:  nil,
+		},
+		{
+			name:  "limit is a parameter",
+			query: Select("id").From("users").Where(Gt("id", 5)).OrderBy("id").Limit(21),
+			sql:   "SELECT id FROM users WHERE id > ? ORDER BY id LIMIT ?",
+			args:  []any{5, 21},
+		},
+		{
+			name:  "computed column args come first",
+			query: Select("id").Column("ABS(latitude - ?) AS offset", 51.5).From("users").Where(Eq("name", injection)).Limit(1),
+			sql:   "SELECT id, ABS(latitude - ?) AS offset FROM users WHERE name = ? LIMIT ?",
+			args:  []any{51.5, injection, 1},
+		},
+		{
+			name: "from a subquery",
+			query: Select("id", "distance").
+				FromSubquery(Select("id").Column("? * latitude AS distance", 3959.0).From("users").Where(NotEq("id", 1)), "candidates").
+				Where(Lte("distance", 10.0)).
+				OrderBy("distance", "id").
+				Limit(20),
+			sql:  "SELECT id, distance FROM (SELECT id, ? * latitude AS distance FROM users WHERE id != ?) AS candidates WHERE distance <= ? ORDER BY distance, id LIMIT ?",
+			args: []any{3959.0, 1, 10.0, 20},
+		},
+		{
+			name:  "table with args",
+			query: Select("users.id").From("users LEFT JOIN matches ON matches.low_user_id = ?", 3),
+			sql:   "SELECT users.id FROM users LEFT JOIN matches ON matches.low_user_id = ?",
+			args:  []any{3},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sql, args, err := test.query.Build()
+			if err != nil {
+				t.Fatalf("Build() error = %v", err)
+			}
+			if sql != test.sql {
+				t.Errorf("Build() sql = %q, want %q", sql, test.sql)
+			}
+			if !reflect.DeepEqual(args, test.args) {
+				t.Errorf("Build() args = %#v, want %#v", args, test.args)
+			}
+			if strings.Count(sql, "?") != len(args) {
+				t.Errorf("Build() has %d placeholders for %d args", strings.Count(sql, "?"), len(args))
+			}
+			for _, arg := range args {
+				if value, ok := arg.(string); ok && strings.Contains(sql, value) {
+					t.Errorf("Build() wrote the value %q into the sql %q", value, sql)
+				}
+			}
+		})
+	}
+}
+
+func TestSelectBuilderBuildErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		query *SelectBuilder
+	}{
+		{name: "no columns", query: Select().From("users")},
+		{name: "no table", query: Select("id")},
+		{name: "too few args", query: Select("id").From("users").Where(Expr("id = ? OR id = ?", 1))},
+		{name: "too many args", query: Select("id").From("users").Where(Expr("id = ?", 1, 2))},
+		{name: "column args", query: Select("id").Column("? + ?", 1).From("users")},
+		{name: "table args", query: Select("id").From("users WHERE id = ?")},
+		{name: "subquery", query: Select("id").FromSubquery(Select("id").From("users").Where(Expr("id = ?")), "candidates")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sql, args, err := test.query.Build()
+			if err == nil {
+				t.Errorf("Build() = %q, %#v, want an error", sql, args)
+			}
+		})
+	}
+}
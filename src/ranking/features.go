@@ -0,0 +1,169 @@
+package ranking
+
+import (
+	"dating-app/src/models"
+	"dating-app/src/rating"
+	"math"
+	"time"
+)
+
+/*
+Feature names, config.RankingFeatures lists the same names to validate the weights in experiments
+*/
+const (
+	Distance        = "distance"
+	AgeFit          = "age_fit"
+	SharedInterests = "shared_interests"
+	Rating          = "rating"
+	Activity        = "activity"
+	NewUser         = "new_user"
+)
+
+/*
+Feature - something a candidate is scored on, from 0 (not at all) to 1
+*/
+type Feature interface {
+	Name() string
+	Score(viewer Viewer, candidate Candidate, now time.Time) float64
+}
+
+/*
+Viewer - the user the deck is being ranked for, AgeMin and AgeMax are the ages they want to see, nil when they're open
+*/
+type Viewer struct {
+	Age       int
+	AgeMin    *int
+	AgeMax    *int
+	Interests []models.Interest
+}
+
+/*
+Candidate - a profile in the deck, its Distance is still the exact distance in miles, along with its interests
+*/
+type Candidate struct {
+	Profile   *models.Profile
+	Interests []models.Interest
+}
+
+/*
+Features - every feature a ranker can weight, in the order they're explained
+*/
+var Features = []Feature{
+	feature{Distance, distanceScore},
+	feature{AgeFit, ageFitScore},
+	feature{SharedInterests, sharedInterestsScore},
+	feature{Rating, ratingScore},
+	feature{Activity, activityScore},
+	feature{NewUser, newUserScore},
+}
+
+type feature struct {
+	name  string
+	score func(viewer Viewer, candidate Candidate, now time.Time) float64
+}
+
+func (f feature) Name() string {
+	return f.name
+}
+
+func (f feature) Score(viewer Viewer, candidate Candidate, now time.Time) float64 {
+	return f.score(viewer, candidate, now)
+}
+
+const (
+	// halfScoreDistance - the distance in miles that scores a half, closer scores more
+	halfScoreDistance = 15
+	// ageTolerance - how far in years from the viewer's own age scores a half when they haven't picked an age range
+	ageTolerance = 5
+	// activityHalfLife - how long after a user was last active their activity scores a half
+	activityHalfLife = 3 * 24 * time.Hour
+	// newUserPeriod - how long new users are boosted for, the boost shrinks from 1 to 0 over it
+	newUserPeriod = 14 * 24 * time.Hour
+)
+
+/*
+distanceScore - 1 right next to the viewer, falling to a half at halfScoreDistance, 0 without a location
+*/
+func distanceScore(_ Viewer, candidate Candidate, _ time.Time) float64 {
+	if candidate.Profile.Distance == nil {
+		return 0
+	}
+	return 1 / (1 + *candidate.Profile.Distance/halfScoreDistance)
+}
+
+/*
+ageFitScore - 1 in the middle of the viewer's age range, a half at its ends.
+With an open range the middle is the viewer's own age
+*/
+func ageFitScore(viewer Viewer, candidate Candidate, _ time.Time) float64 {
+	target, tolerance := float64(viewer.Age), float64(ageTolerance)
+	if viewer.AgeMin != nil && viewer.AgeMax != nil {
+		target = float64(*viewer.AgeMin+*viewer.AgeMax) / 2
+		tolerance = math.Max(1, float64(*viewer.AgeMax-*viewer.AgeMin)/2)
+	} else if viewer.AgeMin != nil {
+		target = math.Max(target, float64(*viewer.AgeMin))
+	} else if viewer.AgeMax != nil {
+		target = math.Min(target, float64(*viewer.AgeMax))
+	}
+
+	off := (float64(candidate.Profile.Age) - target) / tolerance
+	return 1 / (1 + off*off)
+}
+
+/*
+sharedInterestsScore - the share of the smaller set of interests the two users have in common
+*/
+func sharedInterestsScore(viewer Viewer, candidate Candidate, _ time.Time) float64 {
+	if len(viewer.Interests) == 0 || len(candidate.Interests) == 0 {
+		return 0
+	}
+
+	theirs := make(map[string]bool, len(candidate.Interests))
+	for _, interest := range candidate.Interests {
+		theirs[interest.Slug] = true
+	}
+	shared := 0
+	for _, interest := range viewer.Interests {
+		if theirs[interest.Slug] {
+			shared++
+		}
+	}
+
+	smaller := len(viewer.Interests)
+	if len(candidate.Interests) < smaller {
+		smaller = len(candidate.Interests)
+	}
+	return float64(shared) / float64(smaller)
+}
+
+/*
+ratingScore - the chance the candidate would be liked over an average user, going by their desirability
+*/
+func ratingScore(_ Viewer, candidate Candidate, _ time.Time) float64 {
+	if candidate.Profile.Desirability == nil {
+		return 0
+	}
+	return 1 / (1 + math.Pow(10, -(*candidate.Profile.Desirability-rating.Initial)/400))
+}
+
+/*
+activityScore - 1 for a user active right now, halving every activityHalfLife since
+*/
+func activityScore(_ Viewer, candidate Candidate, now time.Time) float64 {
+	if candidate.Profile.ActiveAt == nil {
+		return 0
+	}
+	since := math.Max(0, now.Sub(*candidate.Profile.ActiveAt).Hours())
+	return math.Pow(0.5, since/activityHalfLife.Hours())
+}
+
+/*
+newUserScore - 1 for a user who has just signed up, falling to 0 by the end of newUserPeriod
+*/
+func newUserScore(_ Viewer, candidate Candidate, now time.Time) float64 {
+	if candidate.Profile.JoinedAt == nil {
+		return 0
+	}
+	age := now.Sub(*candidate.Profile.JoinedAt)
+	return math.Max(0, math.Min(1, 1-age.Hours()/newUserPeriod.Hours()))
+}
@@ -0,0 +1,150 @@
+package ranking
+
+import (
+	"dating-app/src/config"
+	"dating-app/src/models"
+	"dating-app/src/rating"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func interests(slugs ...string) []models.Interest {
+	list := make([]models.Interest, len(slugs))
+	for i, slug := range slugs {
+		list[i] = models.Interest{Slug: slug}
+	}
+	return list
+}
+
+func ago(d time.Duration) *time.Time {
+	at := now.Add(-d)
+	return &at
+}
+
+func float(f float64) *float64 {
+	return &f
+}
+
+func age(years int) *int {
+	return &years
+}
+
+const day = 24 * time.Hour
+
+/*
+TestFeatureCurves - the score each feature gives at the points its curve is described by
+*/
+func TestFeatureCurves(t *testing.T) {
+	tests := []struct {
+		name      string
+		feature   func(Viewer, Candidate, time.Time) float64
+		viewer    Viewer
+		candidate Candidate
+		want      float64
+	}{
+		{name: "distance unknown", feature: distanceScore, want: 0},
+		{name: "distance next door", feature: distanceScore, candidate: Candidate{Profile: &models.Profile{Distance: float(0)}}, want: 1},
+		{name: "distance half", feature: distanceScore, candidate: Candidate{Profile: &models.Profile{Distance: float(halfScoreDistance)}}, want: 0.5},
+		{name: "distance far", feature: distanceScore, candidate: Candidate{Profile: &models.Profile{Distance: float(3 * halfScoreDistance)}}, want: 0.25},
+
+		{name: "age own", feature: ageFitScore, viewer: Viewer{Age: 30}, candidate: Candidate{Profile: &models.Profile{Age: 30}}, want: 1},
+		{name: "age tolerance older", feature: ageFitScore, viewer: Viewer{Age: 30}, candidate: Candidate{Profile: &models.Profile{Age: 30 + ageTolerance}}, want: 0.5},
+		{name: "age tolerance younger", feature: ageFitScore, viewer: Viewer{Age: 30}, candidate: Candidate{Profile: &models.Profile{Age: 30 - ageTolerance}}, want: 0.5},
+		{name: "age range middle", feature: ageFitScore, viewer: Viewer{Age: 50, AgeMin: age(24), AgeMax: age(36)}, candidate: Candidate{Profile: &models.Profile{Age: 30}}, want: 1},
+		{name: "age range end", feature: ageFitScore, viewer: Viewer{Age: 50, AgeMin: age(24), AgeMax: age(36)}, candidate: Candidate{Profile: &models.Profile{Age: 36}}, want: 0.5},
+		{name: "age range of one year", feature: ageFitScore, viewer: Viewer{Age: 50, AgeMin: age(30), AgeMax: age(30)}, candidate: Candidate{Profile: &models.Profile{Age: 31}}, want: 0.5},
+		{name: "age min above own", feature: ageFitScore, viewer: Viewer{Age: 30, AgeMin: age(40)}, candidate: Candidate{Profile: &models.Profile{Age: 40}}, want: 1},
+		{name: "age max below own", feature: ageFitScore, viewer: Viewer{Age: 30, AgeMax: age(25)}, candidate: Candidate{Profile: &models.Profile{Age: 25}}, want: 1},
+
+		{name: "interests none", feature: sharedInterestsScore, viewer: Viewer{Interests: interests("hiking")}, candidate: Candidate{Profile: &models.Profile{}}, want: 0},
+		{name: "interests disjoint", feature: sharedInterestsScore, viewer: Viewer{Interests: interests("hiking")},
+			candidate: Candidate{Profile: &models.Profile{}, Interests: interests("chess")}, want: 0},
+		{name: "interests share of smaller", feature: sharedInterestsScore, viewer: Viewer{Interests: interests("hiking", "chess", "jazz", "baking")},
+			candidate: Candidate{Profile: &models.Profile{}, Interests: interests("chess", "hiking")}, want: 1},
+		{name: "interests half", feature: sharedInterestsScore, viewer: Viewer{Interests: interests("hiking", "chess")},
+			candidate: Candidate{Profile: &models.Profile{}, Interests: interests("chess", "jazz", "baking")}, want: 0.5},
+
+		{name: "rating unknown", feature: ratingScore, candidate: Candidate{Profile: &models.Profile{}}, want: 0},
+		{name: "rating average", feature: ratingScore, candidate: Candidate{Profile: &models.Profile{Desirability: float(rating.Initial)}}, want: 0.5},
+		{name: "rating 400 above", feature: ratingScore, candidate: Candidate{Profile: &models.Profile{Desirability: float(rating.Initial + 400)}}, want: 10.0 / 11},
+		{name: "rating 400 below", feature: ratingScore, candidate: Candidate{Profile: &models.Profile{Desirability: float(rating.Initial - 400)}}, want: 1.0 / 11},
+
+		{name: "activity unknown", feature: activityScore, candidate: Candidate{Profile: &models.Profile{}}, want: 0},
+		{name: "activity now", feature: activityScore, candidate: Candidate{Profile: &models.Profile{ActiveAt: ago(0)}}, want: 1},
+		{name: "activity half life", feature: activityScore, candidate: Candidate{Profile: &models.Profile{ActiveAt: ago(activityHalfLife)}}, want: 0.5},
+		{name: "activity two half lives", feature: activityScore, candidate: Candidate{Profile: &models.Profile{ActiveAt: ago(2 * activityHalfLife)}}, want: 0.25},
+		{name: "activity ahead of the clock", feature: activityScore, candidate: Candidate{Profile: &models.Profile{ActiveAt: ago(-time.Hour)}}, want: 1},
+
+		{name: "new user unknown", feature: newUserScore, candidate: Candidate{Profile: &models.Profile{}}, want: 0},
+		{name: "new user just joined", feature: newUserScore, candidate: Candidate{Profile: &models.Profile{JoinedAt: ago(0)}}, want: 1},
+		{name: "new user half way", feature: newUserScore, candidate: Candidate{Profile: &models.Profile{JoinedAt: ago(newUserPeriod / 2)}}, want: 0.5},
+		{name: "new user period over", feature: newUserScore, candidate: Candidate{Profile: &models.Profile{JoinedAt: ago(newUserPeriod)}}, want: 0},
+		{name: "new user long ago", feature: newUserScore, candidate: Candidate{Profile: &models.Profile{JoinedAt: ago(365 * day)}}, want: 0},
+		{name: "new user ahead of the clock", feature: newUserScore, candidate: Candidate{Profile: &models.Profile{JoinedAt: ago(-time.Hour)}}, want: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.candidate.Profile == nil {
+				test.candidate.Profile = &models.Profile{}
+			}
+			if got := test.feature(test.viewer, test.candidate, now); math.Abs(got-test.want) > 1e-9 {
+				t.Errorf("score = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+/*
+TestFeatureCurvesFall - the scores only ever fall the further a candidate is from what suits the viewer
+*/
+func TestFeatureCurvesFall(t *testing.T) {
+	viewer := Viewer{Age: 30}
+	for step := 1; step < 40; step++ {
+		nearer := Candidate{Profile: &models.Profile{
+			Distance: float(float64(step - 1)),
+			Age:      30 + step - 1,
+			ActiveAt: ago(time.Duration(step-1) * 12 * time.Hour),
+			JoinedAt: ago(time.Duration(step-1) * 12 * time.Hour),
+		}}
+		further := Candidate{Profile: &models.Profile{
+			Distance: float(float64(step)),
+			Age:      30 + step,
+			ActiveAt: ago(time.Duration(step) * 12 * time.Hour),
+			JoinedAt: ago(time.Duration(step) * 12 * time.Hour),
+		}}
+		for _, feature := range Features {
+			if feature.Score(viewer, further, now) > feature.Score(viewer, nearer, now) {
+				t.Errorf("%s scores step %d above step %d", feature.Name(), step, step-1)
+			}
+		}
+	}
+}
+
+/*
+TestRankingFeatures - config validates experiments' weights against its own list of feature names,
+which has to be the features that can be scored
+*/
+func TestRankingFeatures(t *testing.T) {
+	var names []string
+	for _, feature := range Features {
+		names = append(names, feature.Name())
+	}
+	if !reflect.DeepEqual(config.RankingFeatures, names) {
+		t.Errorf("config.RankingFeatures = %v, want the ranking features %v", config.RankingFeatures, names)
+	}
+
+	for feature := range DefaultWeights {
+		found := false
+		for _, name := range names {
+			found = found || name == feature
+		}
+		if !found {
+			t.Errorf("DefaultWeights has an unknown feature %q", feature)
+		}
+	}
+}
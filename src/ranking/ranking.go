@@ -0,0 +1,128 @@
+package ranking
+
+import (
+	"dating-app/src/config"
+	"dating-app/src/models"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"time"
+)
+
+/*
+Ranker - orders the candidates in a deck, best first, and explains each one's place
+*/
+type Ranker interface {
+	Name() string
+	Rank(viewer Viewer, candidates []Candidate, now time.Time) []Candidate
+}
+
+/*
+DefaultWeights - the weights users outside of any experiment are ranked with
+*/
+var DefaultWeights = map[string]float64{
+	Distance:        1,
+	AgeFit:          0.5,
+	SharedInterests: 0.75,
+	Rating:          1,
+	Activity:        0.5,
+	NewUser:         0.5,
+}
+
+/*
+Weighted - ranks candidates by the weighted total of their feature scores, ties go to the lower profile id.
+Features without a weight aren't scored
+*/
+type Weighted struct {
+	name    string
+	weights map[string]float64
+}
+
+func NewWeighted(name string, weights map[string]float64) *Weighted {
+	return &Weighted{
+		name:    name,
+		weights: weights,
+	}
+}
+
+func (w *Weighted) Name() string {
+	return w.name
+}
+
+/*
+Rank - sorts the candidates in place and sets each profile's Ranking to its score and how it got there
+*/
+func (w *Weighted) Rank(viewer Viewer, candidates []Candidate, now time.Time) []Candidate {
+	for _, candidate := range candidates {
+		explanation := &models.RankExplanation{Experiment: w.name, Features: []models.FeatureScore{}}
+		for _, feature := range Features {
+			weight, ok := w.weights[feature.Name()]
+			if !ok {
+				continue
+			}
+			score := feature.Score(viewer, candidate, now)
+			explanation.Features = append(explanation.Features, models.FeatureScore{
+				Feature:      feature.Name(),
+				Score:        score,
+				Weight:       weight,
+				Contribution: weight * score,
+			})
+			explanation.Score += weight * score
+		}
+		candidate.Profile.Ranking = explanation
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i].Profile, candidates[j].Profile
+		if a.Ranking.Score != b.Ranking.Score {
+			return a.Ranking.Score > b.Ranking.Score
+		}
+		return a.ID < b.ID
+	})
+
+	return candidates
+}
+
+/*
+Experiments - picks the ranker for each user from the configured experiments
+*/
+type Experiments struct {
+	experiments []experiment
+	fallback    Ranker
+}
+
+type experiment struct {
+	ranker  Ranker
+	traffic int
+}
+
+func NewExperiments(cfg config.Ranking) *Experiments {
+	experiments := &Experiments{
+		fallback: NewWeighted("default", DefaultWeights),
+	}
+	for _, configured := range cfg.Experiments {
+		experiments.experiments = append(experiments.experiments, experiment{
+			ranker:  NewWeighted(configured.Name, configured.Weights),
+			traffic: configured.Traffic,
+		})
+	}
+	return experiments
+}
+
+/*
+For - the ranker for the user, each user is put in one of 100 buckets by a hash of their id
+and the experiments take their traffic's worth of buckets in the order they're configured
+*/
+func (e *Experiments) For(userID int) Ranker {
+	hash := fnv.New32a()
+	hash.Write([]byte("ranking:" + strconv.Itoa(userID)))
+	bucket := int(hash.Sum32() % 100)
+
+	for _, experiment := range e.experiments {
+		if bucket < experiment.traffic {
+			return experiment.ranker
+		}
+		bucket -= experiment.traffic
+	}
+	return e.fallback
+}
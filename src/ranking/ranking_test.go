@@ -0,0 +1,142 @@
+package ranking
+
+import (
+	"dating-app/src/config"
+	"dating-app/src/models"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func candidate(id int, distance float64) Candidate {
+	return Candidate{Profile: &models.Profile{ID: id, Distance: float(distance)}}
+}
+
+func rankedIDs(candidates []Candidate) []int {
+	ids := make([]int, len(candidates))
+	for i, candidate := range candidates {
+		ids[i] = candidate.Profile.ID
+	}
+	return ids
+}
+
+/*
+TestWeightedRank - candidates come out highest total first and equal totals go to the lower id,
+whatever order they went in
+*/
+func TestWeightedRank(t *testing.T) {
+	ranker := NewWeighted("nearby", map[string]float64{Distance: 2})
+	orders := [][]Candidate{
+		{candidate(9, 30), candidate(4, 0), candidate(7, 15), candidate(2, 15), candidate(5, 0)},
+		{candidate(5, 0), candidate(2, 15), candidate(7, 15), candidate(4, 0), candidate(9, 30)},
+	}
+	for _, candidates := range orders {
+		ranked := ranker.Rank(Viewer{}, candidates, now)
+		if got, want := rankedIDs(ranked), []int{4, 5, 2, 7, 9}; !reflect.DeepEqual(got, want) {
+			t.Errorf("ranked %v, want %v", got, want)
+		}
+	}
+}
+
+/*
+TestWeightedRankExplanation - each profile's Ranking has every weighted feature and no others,
+in the order of Features, adding up to its score
+*/
+func TestWeightedRankExplanation(t *testing.T) {
+	ranker := NewWeighted("test", map[string]float64{NewUser: 3, Distance: 2, Rating: 0})
+	profile := &models.Profile{ID: 1, Distance: float(halfScoreDistance), JoinedAt: ago(newUserPeriod / 2)}
+	ranker.Rank(Viewer{}, []Candidate{{Profile: profile}}, now)
+
+	explanation := profile.Ranking
+	if explanation == nil || explanation.Experiment != "test" {
+		t.Fatalf("Ranking = %+v, want an explanation from the test experiment", explanation)
+	}
+	want := []models.FeatureScore{
+		{Feature: Distance, Score: 0.5, Weight: 2, Contribution: 1},
+		{Feature: Rating, Score: 0, Weight: 0, Contribution: 0},
+		{Feature: NewUser, Score: 0.5, Weight: 3, Contribution: 1.5},
+	}
+	if !reflect.DeepEqual(explanation.Features, want) {
+		t.Errorf("features = %+v, want %+v", explanation.Features, want)
+	}
+	if math.Abs(explanation.Score-2.5) > 1e-9 {
+		t.Errorf("score = %v, want 2.5", explanation.Score)
+	}
+}
+
+/*
+TestExperimentsFor - a user's experiment is picked from a hash of their id, so it's the same every time,
+each experiment gets about its traffic's share of users and the rest get the default weights
+*/
+func TestExperimentsFor(t *testing.T) {
+	experiments := NewExperiments(config.Ranking{Experiments: []config.RankingExperiment{
+		{Name: "a", Traffic: 20, Weights: map[string]float64{Distance: 1}},
+		{Name: "b", Traffic: 30, Weights: map[string]float64{Rating: 1}},
+		{Name: "off", Traffic: 0, Weights: map[string]float64{Activity: 1}},
+	}})
+
+	const users = 20000
+	counts := map[string]int{}
+	for userID := 1; userID <= users; userID++ {
+		name := experiments.For(userID).Name()
+		if again := experiments.For(userID).Name(); again != name {
+			t.Fatalf("user %d was put in %s then %s", userID, name, again)
+		}
+		counts[name]++
+	}
+
+	shares := map[string]float64{"a": 0.2, "b": 0.3, "off": 0, "default": 0.5}
+	for name, share := range shares {
+		got := float64(counts[name]) / users
+		if math.Abs(got-share) > 0.02 {
+			t.Errorf("%s has %.3f of users, want about %.2f", name, got, share)
+		}
+	}
+	if counts["off"] != 0 {
+		t.Errorf("an experiment with no traffic has %d users", counts["off"])
+	}
+}
+
+func TestExperimentsForFallback(t *testing.T) {
+	none := NewExperiments(config.Ranking{})
+	for userID := 1; userID <= 1000; userID++ {
+		if name := none.For(userID).Name(); name != "default" {
+			t.Fatalf("with no experiments user %d was put in %s", userID, name)
+		}
+	}
+
+	everyone := NewExperiments(config.Ranking{Experiments: []config.RankingExperiment{
+		{Name: "half", Traffic: 50},
+		{Name: "other half", Traffic: 50},
+	}})
+	for userID := 1; userID <= 1000; userID++ {
+		if name := everyone.For(userID).Name(); name == "default" {
+			t.Fatalf("with all the traffic in experiments user %d got the default weights", userID)
+		}
+	}
+}
+
+/*
+TestExperimentsUseTheirWeights - each experiment ranks with its own weights, the fallback with DefaultWeights
+*/
+func TestExperimentsUseTheirWeights(t *testing.T) {
+	experiments := NewExperiments(config.Ranking{Experiments: []config.RankingExperiment{
+		{Name: "everyone", Traffic: 100, Weights: map[string]float64{Distance: 1}},
+	}})
+	profile := &models.Profile{ID: 1, Distance: float(0)}
+	experiments.For(1).Rank(Viewer{}, []Candidate{{Profile: profile}}, now)
+	if len(profile.Ranking.Features) != 1 || profile.Ranking.Features[0].Feature != Distance {
+		t.Errorf("everyone ranked on %+v, want distance alone", profile.Ranking.Features)
+	}
+
+	fallback := NewExperiments(config.Ranking{})
+	fallback.For(1).Rank(Viewer{Age: 30}, []Candidate{{Profile: profile}}, now)
+	if len(profile.Ranking.Features) != len(DefaultWeights) {
+		t.Errorf("the fallback ranked on %d features, want the %d in DefaultWeights", len(profile.Ranking.Features), len(DefaultWeights))
+	}
+	for _, score := range profile.Ranking.Features {
+		if score.Weight != DefaultWeights[score.Feature] {
+			t.Errorf("the fallback weighted %s %v, want %v", score.Feature, score.Weight, DefaultWeights[score.Feature])
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package rating
+
+import (
+	"dating-app/src/models"
+	"math"
+	"time"
+)
+
+/*
+Glicko ratings, each swipe is a game between the swiped user and the swiper which the swiped user wins with a yes.
+Beating a highly rated opponent gains more than beating a low rated one, so a like from someone who is liked a lot counts for more.
+Only the swiped user is rated, a swipe says nothing about how desirable the swiper is.
+Ratings decay while no one swipes on the user - their deviation grows back towards MaxDeviation - and profiles are ranked by
+Desirability, which is taken off the rating, so users drop down the rankings until new swipes confirm where they are
+*/
+const (
+	Initial      = 1500
+	MaxDeviation = 350
+	MinDeviation = 30
+
+	/*
+		DeviationGrowth - how much the squared deviation grows each day without a swipe,
+		a well established rating (a deviation of 50) is back to an unrated one after about 180 days
+	*/
+	DeviationGrowth = (MaxDeviation*MaxDeviation - 50*50) / 180.0
+)
+
+// q - converts between the rating scale and natural logarithms, ln(10) / 400
+var q = math.Ln10 / 400
+
+/*
+New - the rating every user starts with
+*/
+func New() models.Rating {
+	return models.Rating{Value: Initial, Deviation: MaxDeviation}
+}
+
+/*
+Decay - the rating as it stands at now, its deviation grown for every day since it was last rated
+*/
+func Decay(r models.Rating, now time.Time) models.Rating {
+	if r.RatedAt == nil {
+		return r
+	}
+
+	days := math.Max(0, now.Sub(*r.RatedAt).Seconds()/86400)
+	r.Deviation = math.Min(MaxDeviation, math.Sqrt(r.Deviation*r.Deviation+DeviationGrowth*days))
+
+	return r
+}
+
+/*
+Desirability - the rating less two deviations, a rating we're fairly sure the user is at least as good as.
+New and inactive users are ranked below users with the same rating who have been swiped on recently.
+The MySQL repository computes the same in SQL, keep the two in step
+*/
+func Desirability(r models.Rating, now time.Time) float64 {
+	r = Decay(r, now)
+	return r.Value - 2*r.Deviation
+}
+
+/*
+Swipe - the swiped user's new rating after the swiper's swipe, liked when they swiped yes
+*/
+func Swipe(swiped, swiper models.Rating, liked bool, now time.Time) models.Rating {
+	swiped = Decay(swiped, now)
+	swiper = Decay(swiper, now)
+
+	score := 0.0
+	if liked {
+		score = 1
+	}
+
+	g := g(swiper.Deviation)
+	expected := 1 / (1 + math.Pow(10, -g*(swiped.Value-swiper.Value)/400))
+	dSquared := 1 / (q * q * g * g * expected * (1 - expected))
+	precision := 1/(swiped.Deviation*swiped.Deviation) + 1/dSquared
+
+	return models.Rating{
+		Value:     swiped.Value + q/precision*g*(score-expected),
+		Deviation: math.Max(MinDeviation, math.Sqrt(1/precision)),
+		RatedAt:   &now,
+	}
+}
+
+/*
+g - how much a game counts for given how uncertain the opponent's rating is
+*/
+func g(deviation float64) float64 {
+	return 1 / math.Sqrt(1+3*q*q*deviation*deviation/(math.Pi*math.Pi))
+}
@@ -0,0 +1,131 @@
+package rating
+
+import (
+	"dating-app/src/models"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+/*
+TestRatingsConvergeToHiddenStrengths - a population where each user has a hidden strength swipes on each other once,
+each swipe a yes with the chance Glicko expects from the two strengths. The ratings should end up in the same order as
+the strengths, and further apart the more the strengths are
+*/
+func TestRatingsConvergeToHiddenStrengths(t *testing.T) {
+	const users = 60
+	random := rand.New(rand.NewSource(1))
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	strengths := make([]float64, users)
+	ratings := make([]models.Rating, users)
+	var swipes [][2]int
+	for i := range strengths {
+		strengths[i] = 1000 + random.Float64()*1000
+		ratings[i] = New()
+		for j := 0; j < users; j++ {
+			if i != j {
+				swipes = append(swipes, [2]int{j, i})
+			}
+		}
+	}
+	random.Shuffle(len(swipes), func(i, j int) { swipes[i], swipes[j] = swipes[j], swipes[i] })
+
+	for n, swipe := range swipes {
+		swiper, swiped := swipe[0], swipe[1]
+		liked := random.Float64() < 1/(1+math.Pow(10, -(strengths[swiped]-strengths[swiper])/400))
+		// a swipe every few seconds, the deviations barely decay
+		at := now.Add(time.Duration(n) * 5 * time.Second)
+		ratings[swiped] = Swipe(ratings[swiped], ratings[swiper], liked, at)
+	}
+
+	values := make([]float64, users)
+	for i, r := range ratings {
+		values[i] = r.Value
+		if r.Deviation >= MaxDeviation/2 {
+			t.Errorf("user %d's deviation is still %.0f after %d swipes", i, r.Deviation, users-1)
+		}
+	}
+	if correlation := spearman(strengths, values); correlation < 0.9 {
+		t.Errorf("rank correlation between strengths and ratings = %.3f, want at least 0.9", correlation)
+	}
+
+	strongest, weakest := 0, 0
+	for i := range strengths {
+		if strengths[i] > strengths[strongest] {
+			strongest = i
+		}
+		if strengths[i] < strengths[weakest] {
+			weakest = i
+		}
+	}
+	if Desirability(ratings[strongest], now) <= Desirability(ratings[weakest], now) {
+		t.Errorf("the strongest user's desirability %.0f isn't above the weakest's %.0f",
+			Desirability(ratings[strongest], now), Desirability(ratings[weakest], now))
+	}
+}
+
+func TestSwipeMovesTowardsTheResult(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	liked := Swipe(New(), New(), true, now)
+	disliked := Swipe(New(), New(), false, now)
+	if liked.Value <= Initial || disliked.Value >= Initial {
+		t.Errorf("a yes rated %.1f and a no %.1f, want above and below %d", liked.Value, disliked.Value, Initial)
+	}
+	if liked.Deviation >= MaxDeviation {
+		t.Errorf("deviation after a swipe = %.1f, want it to narrow from %d", liked.Deviation, MaxDeviation)
+	}
+
+	strong := models.Rating{Value: 1900, Deviation: 50}
+	weak := models.Rating{Value: 1100, Deviation: 50}
+	if Swipe(New(), strong, true, now).Value <= Swipe(New(), weak, true, now).Value {
+		t.Error("a yes from a highly rated swiper didn't count for more than one from a low rated swiper")
+	}
+}
+
+func TestDecay(t *testing.T) {
+	ratedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := models.Rating{Value: 1700, Deviation: 50, RatedAt: &ratedAt}
+
+	if got := Decay(r, ratedAt); got.Deviation != 50 {
+		t.Errorf("Decay() straight away = %.1f, want 50", got.Deviation)
+	}
+	if got := Decay(r, ratedAt.AddDate(0, 0, 30)); got.Deviation <= 50 || got.Deviation >= MaxDeviation {
+		t.Errorf("Decay() after 30 days = %.1f, want between 50 and %d", got.Deviation, MaxDeviation)
+	}
+	if got := Decay(r, ratedAt.AddDate(1, 0, 0)); got.Deviation != MaxDeviation {
+		t.Errorf("Decay() after a year = %.1f, want %d", got.Deviation, MaxDeviation)
+	}
+	if got := Decay(r, ratedAt.AddDate(1, 0, 0)); got.Value != r.Value {
+		t.Errorf("Decay() changed the value to %.1f", got.Value)
+	}
+}
+
+/*
+spearman - the rank correlation of the two samples, which have no ties
+*/
+func spearman(a, b []float64) float64 {
+	rankA, rankB := ranks(a), ranks(b)
+	n := float64(len(a))
+	sum := 0.0
+	for i := range a {
+		d := rankA[i] - rankB[i]
+		sum += d * d
+	}
+	return 1 - 6*sum/(n*(n*n-1))
+}
+
+func ranks(values []float64) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return values[order[i]] < values[order[j]] })
+	ranked := make([]float64, len(values))
+	for rank, i := range order {
+		ranked[i] = float64(rank)
+	}
+	return ranked
+}
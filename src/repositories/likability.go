@@ -0,0 +1,146 @@
+package repositories
+
+import (
+	"database/sql"
+	"dating-app/src/models"
+	"errors"
+)
+
+/*
+MySQLLikabilityRepository - LikabilityRepository backed by the likability_ledger table
+*/
+type MySQLLikabilityRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLLikabilityRepository(db *sql.DB) *MySQLLikabilityRepository {
+	return &MySQLLikabilityRepository{
+		db: db,
+	}
+}
+
+/*
+recordSwipe - adds the ledger entry for a swipe and moves the cached likability by what changed,
+in the swipe's transaction. The entry for the swiper's previous swipe on the same user is reversed first.
+The pair's row in matches is locked by the transaction, so nothing else can change the swiper's entries while it runs
+*/
+func recordSwipe(tx *sql.Tx, entry models.LikabilityEntry) error {
+	change := 0
+
+	previous := models.LikabilityEntry{}
+	row := tx.QueryRow(`SELECT id, swipe, delta FROM likability_ledger entries
+WHERE user_id = ? AND swiper_id = ? AND reverses_id IS NULL
+AND NOT EXISTS (SELECT 1 FROM likability_ledger reversals WHERE reversals.reverses_id = entries.id)
+ORDER BY id DESC LIMIT 1`, entry.UserID, entry.SwiperID)
+	err := row.Scan(&previous.ID, &previous.Swipe, &previous.Delta)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err == nil {
+		// reverses_id is unique, reversing an entry that's already been reversed changes nothing
+		result, err := tx.Exec(`INSERT INTO likability_ledger (user_id, swiper_id, swipe, delta, reverses_id, created_at) VALUES (?, ?, ?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE reverses_id = reverses_id`, entry.UserID, entry.SwiperID, previous.Swipe, -previous.Delta, previous.ID, entry.CreatedAt)
+		if err != nil {
+			return err
+		}
+		reversed, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if reversed == 1 {
+			change -= previous.Delta
+		}
+	}
+
+	_, err = tx.Exec("INSERT INTO likability_ledger (user_id, swiper_id, swipe, delta, created_at) VALUES (?, ?, ?, ?, ?)",
+		entry.UserID, entry.SwiperID, entry.Swipe, entry.Delta, entry.CreatedAt)
+	if err != nil {
+		return err
+	}
+	change += entry.Delta
+
+	if change != 0 {
+		_, err = tx.Exec("UPDATE users SET likability = likability + ? WHERE id = ?", change, entry.UserID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+Repair - checks up to limit users with an id greater than afterID, resetting the cached likability of any whose
+doesn't match the total of their ledger entries. Returns the last id checked, 0 once there are none left, and the users that were reset
+*/
+func (r *MySQLLikabilityRepository) Repair(afterID, limit int) (int, []models.LikabilityRepair, error) {
+	rows, err := r.db.Query("SELECT id FROM users WHERE id > ? ORDER BY id LIMIT ?", afterID, limit)
+	if err != nil {
+		return 0, nil, err
+	}
+	var userIDs []int
+	for rows.Next() {
+		var userID int
+		err = rows.Scan(&userID)
+		if err != nil {
+			rows.Close()
+			return 0, nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	lastID := 0
+	var repairs []models.LikabilityRepair
+	for _, userID := range userIDs {
+		repair, err := r.repair(userID)
+		if err != nil {
+			return lastID, repairs, err
+		}
+		if repair != nil {
+			repairs = append(repairs, *repair)
+		}
+		lastID = userID
+	}
+
+	return lastID, repairs, nil
+}
+
+/*
+repair - resets one user's cached likability to their ledger total, nil if it was already right.
+Swipes update the ledger before the user's row, so locking the row first means the total can't include a swipe the cache doesn't
+*/
+func (r *MySQLLikabilityRepository) repair(userID int) (*models.LikabilityRepair, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	repair := &models.LikabilityRepair{UserID: userID}
+	err = tx.QueryRow("SELECT likability FROM users WHERE id = ? FOR UPDATE", userID).Scan(&repair.Cached)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRow("SELECT COALESCE(SUM(delta), 0) FROM likability_ledger WHERE user_id = ?", userID).Scan(&repair.Ledger)
+	if err != nil {
+		return nil, err
+	}
+	if repair.Ledger == repair.Cached {
+		return nil, nil
+	}
+
+	_, err = tx.Exec("UPDATE users SET likability = ? WHERE id = ?", repair.Ledger, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return repair, tx.Commit()
+}
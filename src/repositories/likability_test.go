@@ -0,0 +1,228 @@
+package repositories
+
+import (
+	"dating-app/src/models"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+/*
+swipeAs - what a swipe does to a relationship, the same as the interactors' applySwipe
+*/
+func swipeAs(userID int, preference models.Preference) SwipeFunc {
+	return func(relationship *models.Match) (int, error) {
+		if current := relationship.SwipeOf(userID); current != nil && *current == preference {
+			return 0, errAlreadySwiped
+		}
+		if userID == relationship.LowUserID {
+			relationship.LowSwipe = &preference
+		} else {
+			relationship.HighSwipe = &preference
+		}
+
+		now := time.Now().UTC().Truncate(time.Second)
+		low, high := relationship.LowSwipe, relationship.HighSwipe
+		switch {
+		case (low != nil && *low == models.No) || (high != nil && *high == models.No):
+			relationship.State = models.Unmatched
+			relationship.MatchedAt = nil
+		case low != nil && high != nil:
+			relationship.State = models.Matched
+			relationship.MatchedAt = &now
+		default:
+			relationship.State = models.Pending
+		}
+		relationship.UpdatedAt = now
+
+		if preference == models.Yes {
+			return 1, nil
+		}
+		return -1, nil
+	}
+}
+
+/*
+unreversed - the entries that still count, neither a reversal nor reversed by one
+*/
+func unreversed(entries []models.LikabilityEntry) []models.LikabilityEntry {
+	reversed := map[int64]bool{}
+	for _, entry := range entries {
+		if entry.ReversesID != nil {
+			reversed[*entry.ReversesID] = true
+		}
+	}
+
+	var current []models.LikabilityEntry
+	for _, entry := range entries {
+		if entry.ReversesID == nil && !reversed[entry.ID] {
+			current = append(current, entry)
+		}
+	}
+	return current
+}
+
+/*
+testLedger - a swiper changing their mind leaves one entry that counts, repeating a swipe changes nothing,
+and Repair resets a cached likability that has drifted from the ledger. drift sets the cached likability behind the ledger's back
+*/
+func testLedger(t *testing.T, store swipeStore, likability LikabilityRepository, drift func(userID, likability int) error) {
+	prefix := time.Now().UnixNano()
+	var userIDs []int
+	for i := 0; i < 2; i++ {
+		user, err := store.users.Create(models.User{
+			Email:        fmt.Sprintf("ledger%d-%d@example.com", prefix, i),
+			Password:     "hash",
+			DistanceUnit: models.Kilometres,
+			Profile: models.Profile{
+				Name:        "Ledger",
+				Gender:      models.NotSpecified,
+				DateOfBirth: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		userIDs = append(userIDs, user.ID)
+	}
+	swiper, swiped := userIDs[0], userIDs[1]
+
+	cached := func() int {
+		t.Helper()
+		user, err := store.users.GetByID(swiped)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.LikabilityScore == nil {
+			t.Fatal("no likability")
+		}
+		return *user.LikabilityScore
+	}
+	ledger := func() []models.LikabilityEntry {
+		t.Helper()
+		entries, err := store.ledger(swiped, swiper)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return entries
+	}
+	check := func(preference models.Preference, wantLikability, wantEntries int) {
+		t.Helper()
+		entries := ledger()
+		if len(entries) != wantEntries {
+			t.Errorf("after %s there are %d entries, want %d", preference, len(entries), wantEntries)
+		}
+		total := 0
+		for _, entry := range entries {
+			total += entry.Delta
+		}
+		current := unreversed(entries)
+		if len(current) != 1 || current[0].Swipe != preference || current[0].Delta != wantLikability {
+			t.Errorf("after %s the unreversed entries are %+v, want a single %s worth %d", preference, current, preference, wantLikability)
+		}
+		if total != wantLikability {
+			t.Errorf("after %s the ledger totals %d, want %d", preference, total, wantLikability)
+		}
+		if got := cached(); got != wantLikability {
+			t.Errorf("after %s the cached likability is %d, want %d", preference, got, wantLikability)
+		}
+	}
+
+	// each change of mind reverses the previous entry then adds its own
+	steps := []struct {
+		preference     models.Preference
+		wantLikability int
+		wantEntries    int
+	}{
+		{preference: models.Yes, wantLikability: 1, wantEntries: 1},
+		{preference: models.No, wantLikability: -1, wantEntries: 3},
+		{preference: models.Yes, wantLikability: 1, wantEntries: 5},
+	}
+	for _, step := range steps {
+		if _, err := store.matches.Swipe(swiper, swiped, swipeAs(swiper, step.preference), keepRating); err != nil {
+			t.Fatal(err)
+		}
+		check(step.preference, step.wantLikability, step.wantEntries)
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err := store.matches.Swipe(swiper, swiped, swipeAs(swiper, models.Yes), keepRating)
+		if !errors.Is(err, errAlreadySwiped) {
+			t.Fatalf("repeated yes err = %v, want errAlreadySwiped", err)
+		}
+	}
+	check(models.Yes, 1, 5)
+
+	if err := drift(swiped, 7); err != nil {
+		t.Fatal(err)
+	}
+	lastID, repairs, err := likability.Repair(swiped-1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastID != swiped {
+		t.Errorf("Repair checked up to %d, want %d", lastID, swiped)
+	}
+	want := models.LikabilityRepair{UserID: swiped, Cached: 7, Ledger: 1}
+	if len(repairs) != 1 || repairs[0] != want {
+		t.Errorf("repairs = %+v, want %+v", repairs, want)
+	}
+	check(models.Yes, 1, 5)
+
+	if _, repairs, err = likability.Repair(swiped-1, 1); err != nil || len(repairs) != 0 {
+		t.Errorf("second Repair = %+v, %v, want nothing to repair", repairs, err)
+	}
+	if _, repairs, err = likability.Repair(swiper-1, 1); err != nil || len(repairs) != 0 {
+		t.Errorf("Repair of the swiper = %+v, %v, want nothing to repair", repairs, err)
+	}
+}
+
+func TestMemoryLedger(t *testing.T) {
+	users := NewMemoryUserRepository()
+	testLedger(t, memorySwipeStore(users), NewMemoryLikabilityRepository(users), func(userID, likability int) error {
+		users.mu.Lock()
+		defer users.mu.Unlock()
+		users.users[userID].likability = likability
+		return nil
+	})
+}
+
+func TestMemoryLikabilityRepairBatches(t *testing.T) {
+	users := NewMemoryUserRepository()
+	likability := NewMemoryLikabilityRepository(users)
+	for i := 0; i < 5; i++ {
+		user, err := users.Create(models.User{Email: fmt.Sprintf("batch%d@example.com", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i%2 == 0 {
+			users.users[user.ID].likability = i + 1
+		}
+	}
+
+	var checked []int
+	var repaired []int
+	afterID := 0
+	for {
+		lastID, repairs, err := likability.Repair(afterID, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lastID == 0 {
+			break
+		}
+		checked = append(checked, lastID)
+		for _, repair := range repairs {
+			repaired = append(repaired, repair.UserID)
+		}
+		afterID = lastID
+	}
+
+	if fmt.Sprint(checked) != "[2 4 5]" {
+		t.Errorf("batches ended at %v, want [2 4 5]", checked)
+	}
+	if fmt.Sprint(repaired) != "[1 3 5]" {
+		t.Errorf("repaired %v, want [1 3 5]", repaired)
+	}
+}
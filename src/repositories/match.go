@@ -0,0 +1,418 @@
+package repositories
+
+import (
+	"database/sql"
+	"dating-app/src/geo"
+	"dating-app/src/models"
+	"dating-app/src/query"
+	"dating-app/src/rating"
+	"errors"
+)
+
+/*
+MySQLMatchRepository - MatchRepository backed by the matches table
+*/
+type MySQLMatchRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLMatchRepository(db *sql.DB) *MySQLMatchRepository {
+	return &MySQLMatchRepository{
+		db: db,
+	}
+}
+
+/*
+GetProfilesForUser - gets the profiles for a requesting user within filtering options
+*/
+func (r *MySQLMatchRepository) GetProfilesForUser(userID int, opts models.FilterOpts) ([]*models.Profile, error) {
+	profileQuery, args, err := profilesQuery(userID, opts).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(profileQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var profiles []*models.Profile
+
+	for rows.Next() {
+		profile := new(models.Profile)
+
+		var dateOfBirth string
+		var heightCM sql.NullInt32
+		var joinedAt, activeAt sql.NullString
+
+		err = rows.Scan(&profile.ID, &profile.Name, &profile.Gender, &profile.GenderDescription, &dateOfBirth, &profile.Latitude, &profile.Longitude, &profile.LikabilityScore, &profile.Distance,
+			&profile.Desirability, &profile.Bio, &profile.JobTitle, &profile.School, &heightCM, &joinedAt, &activeAt)
+		if err != nil {
+			return nil, err
+		}
+		profile.DateOfBirth, err = parseDateTime(dateOfBirth)
+		if err != nil {
+			return nil, err
+		}
+		profile.HeightCM = nullableInt(heightCM)
+		profile.JoinedAt, err = nullableDateTime(joinedAt)
+		if err != nil {
+			return nil, err
+		}
+		profile.ActiveAt, err = nullableDateTime(activeAt)
+		if err != nil {
+			return nil, err
+		}
+
+		profiles = append(profiles, profile)
+	}
+	return profiles, rows.Err()
+}
+
+/*
+haversineSQL - great circle distance in miles from the point in the args to each user, see geo.Haversine
+args: earth radius, latitude, latitude, longitude
+*/
+const haversineSQL = `? * 2 * ASIN(SQRT(LEAST(1,
+POWER(SIN(RADIANS(latitude - ?) / 2), 2) + COS(RADIANS(?)) * COS(RADIANS(latitude)) * POWER(SIN(RADIANS(longitude - ?) / 2), 2))))`
+
+/*
+desirabilitySQL - each user's rating less two deviations, decayed to the time in the args, see rating.Desirability
+args: max deviation, deviation growth, time
+*/
+const desirabilitySQL = `rating - 2 * LEAST(?, SQRT(rating_deviation * rating_deviation +
+? * GREATEST(0, COALESCE(TIMESTAMPDIFF(SECOND, rated_at, ?), 0)) / 86400))`
+
+/*
+profilesQuery - the profiles a user hasn't swiped yet, excluding anyone who has already matched or rejected them,
+narrowed down by the optional filters and starting after the cursor.
+Matching is mutual, candidates whose own discovery preferences would never show them the requesting user are left out
+The candidates are selected in a derived table so their distance from the requesting user can be filtered and sorted on.
+A max distance first narrows the candidates to a bounding box, which can use the location index, before the exact distance is checked
+*/
+func profilesQuery(userID int, opts models.FilterOpts) *query.SelectBuilder {
+	origin := opts.Origin
+
+	candidates := query.Select("id", "name", "gender", "gender_description", "date_of_birth", "latitude", "longitude", "likability",
+		"bio", "job_title", "school", "height_cm", "created_at").
+		Column(haversineSQL+" AS distance", geo.EarthRadiusMiles, origin.Latitude, origin.Latitude, origin.Longitude).
+		Column(desirabilitySQL+" AS desirability", rating.MaxDeviation, rating.DeviationGrowth, opts.Now).
+		Column("(SELECT MAX(last_used_at) FROM sessions WHERE sessions.user_id = users.id) AS active_at").
+		Column("discovery_preferences.max_distance AS their_max_distance").
+		From("users LEFT JOIN discovery_preferences ON discovery_preferences.user_id = users.id").
+		Where(
+			query.Expr("id NOT IN (SELECT high_user_id FROM matches WHERE low_user_id = ? AND (low_swipe IS NOT NULL OR state != ?))", userID, models.Pending),
+			query.Expr("id NOT IN (SELECT low_user_id FROM matches WHERE high_user_id = ? AND (high_swipe IS NOT NULL OR state != ?))", userID, models.Pending),
+			query.NotEq("id", userID),
+			// the requesting user has to fit the candidate's preferences too
+			query.Expr("discovery_preferences.age_min IS NULL OR discovery_preferences.age_min <= ?", opts.ViewerAge),
+			query.Expr("discovery_preferences.age_max IS NULL OR discovery_preferences.age_max >= ?", opts.ViewerAge),
+			query.Expr(`NOT EXISTS (SELECT 1 FROM discovery_show_me WHERE discovery_show_me.user_id = users.id)
+OR ? IN (SELECT show_me FROM discovery_show_me WHERE discovery_show_me.user_id = users.id)`, opts.ViewerGender.ShowMe()),
+		).
+		WhereIf(opts.AgeMin != nil && !opts.AgeMin.IsZero(), query.Lt("date_of_birth", opts.AgeMin)).
+		WhereIf(opts.AgeMax != nil && !opts.AgeMax.IsZero(), query.Gt("date_of_birth", opts.AgeMax)).
+		WhereIf(len(opts.Genders) > 0, query.In("gender", genderArgs(opts.Genders)...)).
+		WhereIf(opts.ProfileIDs != nil, query.In("id", toArgs(opts.ProfileIDs)...))
+
+	if opts.MaxDistance > 0 {
+		candidates.Where(boundingBoxCond(geo.NewBoundingBox(origin.Latitude, origin.Longitude, opts.MaxDistance)))
+	}
+
+	profiles := query.Select("id", "name", "gender", "gender_description", "date_of_birth", "latitude", "longitude", "likability", "distance",
+		"desirability", "bio", "job_title", "school", "height_cm", "created_at", "active_at").
+		FromSubquery(candidates, "candidates").
+		Where(query.Expr("their_max_distance IS NULL OR distance <= their_max_distance")).
+		WhereIf(opts.MaxDistance > 0, query.Lte("distance", opts.MaxDistance))
+
+	switch opts.Sort {
+	case models.SortRecommended:
+		if opts.After != nil {
+			profiles.Where(query.Or(
+				query.Lt("likability", opts.After.Likability),
+				query.And(query.Eq("likability", opts.After.Likability), query.Gt("id", opts.After.ID)),
+			))
+		}
+		profiles.OrderBy("likability DESC", "id")
+	case models.SortDistance:
+		// users without a location can't be placed in distance order
+		profiles.Where(query.Expr("distance IS NOT NULL"))
+		if opts.After != nil {
+			profiles.Where(query.Or(
+				query.Gt("distance", opts.After.Distance),
+				query.And(query.Eq("distance", opts.After.Distance), query.Gt("id", opts.After.ID)),
+			))
+		}
+		profiles.OrderBy("distance", "id")
+	case models.SortDesirability:
+		if opts.After != nil {
+			profiles.Where(query.Or(
+				query.Lt("desirability", opts.After.Desirability),
+				query.And(query.Eq("desirability", opts.After.Desirability), query.Gt("id", opts.After.ID)),
+			))
+		}
+		profiles.OrderBy("desirability DESC", "id")
+	case models.SortRanked:
+		// the nearest candidates are ranked once they've been fetched, users without a location are left until last
+		profiles.OrderBy("distance IS NULL", "distance", "id")
+	default:
+		if opts.After != nil {
+			profiles.Where(query.Gt("id", opts.After.ID))
+		}
+		profiles.OrderBy("id")
+	}
+
+	if opts.Limit > 0 {
+		profiles.Limit(opts.Limit)
+	}
+
+	return profiles
+}
+
+/*
+GetMatches - the users the user is matched with, most recently matched first, starting after the cursor
+*/
+func (r *MySQLMatchRepository) GetMatches(userID int, after *models.MatchCursor, limit int) ([]*models.MatchSummary, error) {
+	matchesQuery, args, err := matchesQuery(userID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(matchesQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []*models.MatchSummary
+	for rows.Next() {
+		profile := new(models.Profile)
+		var dateOfBirth, matchedAt string
+		var heightCM sql.NullInt32
+
+		err = rows.Scan(&profile.ID, &profile.Name, &profile.Gender, &profile.GenderDescription, &dateOfBirth, &profile.Latitude, &profile.Longitude,
+			&profile.Bio, &profile.JobTitle, &profile.School, &heightCM, &matchedAt)
+		if err != nil {
+			return nil, err
+		}
+		profile.DateOfBirth, err = parseDateTime(dateOfBirth)
+		if err != nil {
+			return nil, err
+		}
+		profile.HeightCM = nullableInt(heightCM)
+
+		match := &models.MatchSummary{Profile: profile}
+		match.MatchedAt, err = parseDateTime(matchedAt)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, match)
+	}
+	return matches, rows.Err()
+}
+
+/*
+matchesQuery - the user's matches after the cursor. Each side of the pair is read in order from its own index and only
+the first limit of each are merged
+*/
+func matchesQuery(userID int, after *models.MatchCursor, limit int) (string, []any, error) {
+	side := func(userColumn, otherColumn string) (string, []any, error) {
+		matches := query.Select(otherColumn+" AS other_id", "matched_at").
+			From("matches").
+			Where(query.Eq(userColumn, userID), query.Eq("state", models.Matched))
+		if after != nil {
+			matches.Where(query.Or(
+				query.Lt("matched_at", after.MatchedAt),
+				query.And(query.Eq("matched_at", after.MatchedAt), query.Lt(otherColumn, after.UserID)),
+			))
+		}
+		return matches.OrderBy("matched_at DESC", otherColumn+" DESC").Limit(limit).Build()
+	}
+
+	lowQuery, lowArgs, err := side("low_user_id", "high_user_id")
+	if err != nil {
+		return "", nil, err
+	}
+	highQuery, highArgs, err := side("high_user_id", "low_user_id")
+	if err != nil {
+		return "", nil, err
+	}
+
+	return `SELECT users.id, users.name, users.gender, users.gender_description, users.date_of_birth, users.latitude, users.longitude,
+users.bio, users.job_title, users.school, users.height_cm, user_matches.matched_at
+FROM ((` + lowQuery + `) UNION ALL (` + highQuery + `)) AS user_matches
+JOIN users ON users.id = user_matches.other_id
+ORDER BY user_matches.matched_at DESC, users.id DESC
+LIMIT ?`, append(append(lowArgs, highArgs...), limit), nil
+}
+
+func genderArgs(genders []models.GenderType) []any {
+	args := make([]any, len(genders))
+	for i, gender := range genders {
+		args[i] = gender
+	}
+	return args
+}
+
+func boundingBoxCond(box geo.BoundingBox) query.Cond {
+	latitude := query.Expr("latitude BETWEEN ? AND ?", box.MinLat, box.MaxLat)
+
+	switch {
+	case box.AllLongitudes:
+		return latitude
+	case box.CrossesAntimeridian():
+		return query.And(latitude, query.Or(query.Gte("longitude", box.MinLng), query.Lte("longitude", box.MaxLng)))
+	default:
+		return query.And(latitude, query.Expr("longitude BETWEEN ? AND ?", box.MinLng, box.MaxLng))
+	}
+}
+
+/*
+swipeAttempts - how many times a swipe is tried when MySQL picks it as a deadlock victim
+*/
+const swipeAttempts = 3
+
+/*
+Swipe - applies the swipe to the pair's relationship in a transaction, holding the pair's row lock until it commits
+so two users swiping on each other at the same time are applied one after the other.
+The row is created first if the pair has never swiped, the unique pair key means there's only ever one to lock
+*/
+func (r *MySQLMatchRepository) Swipe(userID, profileID int, swipe SwipeFunc, rate RateFunc) (*models.Match, error) {
+	var relationship *models.Match
+	var err error
+	for attempt := 0; attempt < swipeAttempts; attempt++ {
+		relationship, err = r.swipe(userID, profileID, swipe, rate)
+		if !isDeadlock(err) {
+			break
+		}
+	}
+	return relationship, err
+}
+
+func (r *MySQLMatchRepository) swipe(userID, profileID int, swipe SwipeFunc, rate RateFunc) (*models.Match, error) {
+	low, high := models.Pair(userID, profileID)
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// a no-op update on an existing pair still locks its row
+	_, err = tx.Exec(`INSERT INTO matches (low_user_id, high_user_id, state, updated_at) VALUES (?, ?, ?, UTC_TIMESTAMP())
+ON DUPLICATE KEY UPDATE low_user_id = low_user_id`, low, high, models.Pending)
+	if err != nil {
+		return nil, err
+	}
+
+	relationship := &models.Match{LowUserID: low, HighUserID: high}
+	var lowSwipe, highSwipe, matchedAt sql.NullString
+	var updatedAt string
+	row := tx.QueryRow("SELECT low_swipe, high_swipe, state, matched_at, updated_at FROM matches WHERE low_user_id = ? AND high_user_id = ? FOR UPDATE", low, high)
+	err = row.Scan(&lowSwipe, &highSwipe, &relationship.State, &matchedAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	relationship.LowSwipe = nullablePreference(lowSwipe)
+	relationship.HighSwipe = nullablePreference(highSwipe)
+	if matchedAt.Valid {
+		t, err := parseDateTime(matchedAt.String)
+		if err != nil {
+			return nil, err
+		}
+		relationship.MatchedAt = &t
+	}
+	relationship.UpdatedAt, err = parseDateTime(updatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	first := relationship.SwipeOf(userID) == nil
+	likability, err := swipe(relationship)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec("UPDATE matches SET low_swipe = ?, high_swipe = ?, state = ?, matched_at = ?, updated_at = ? WHERE low_user_id = ? AND high_user_id = ?",
+		relationship.LowSwipe, relationship.HighSwipe, relationship.State, relationship.MatchedAt, relationship.UpdatedAt, low, high)
+	if err != nil {
+		return nil, err
+	}
+
+	if swiped := relationship.SwipeOf(userID); swiped != nil {
+		err = recordSwipe(tx, models.LikabilityEntry{
+			UserID:    profileID,
+			SwiperID:  userID,
+			Swipe:     *swiped,
+			Delta:     likability,
+			CreatedAt: relationship.UpdatedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if first {
+		err = rateSwipe(tx, profileID, userID, rate)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return relationship, nil
+}
+
+/*
+rateSwipe - updates the swiped user's rating in the swipe's transaction, their row is locked so swipes on them are rated one at a time
+*/
+func rateSwipe(tx *sql.Tx, swipedID, swiperID int, rate RateFunc) error {
+	swiped, err := scanRating(tx.QueryRow("SELECT rating, rating_deviation, rated_at FROM users WHERE id = ? FOR UPDATE", swipedID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	swiper, err := scanRating(tx.QueryRow("SELECT rating, rating_deviation, rated_at FROM users WHERE id = ?", swiperID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	rated := rate(swiped, swiper)
+	_, err = tx.Exec("UPDATE users SET rating = ?, rating_deviation = ?, rated_at = ? WHERE id = ?", rated.Value, rated.Deviation, rated.RatedAt, swipedID)
+	return err
+}
+
+func scanRating(row *sql.Row) (models.Rating, error) {
+	r := models.Rating{}
+	var ratedAt sql.NullString
+	err := row.Scan(&r.Value, &r.Deviation, &ratedAt)
+	if err != nil {
+		return r, err
+	}
+	if ratedAt.Valid {
+		t, err := parseDateTime(ratedAt.String)
+		if err != nil {
+			return r, err
+		}
+		r.RatedAt = &t
+	}
+	return r, nil
+}
+
+func nullablePreference(value sql.NullString) *models.Preference {
+	if !value.Valid {
+		return nil
+	}
+	preference := models.Preference(value.String)
+	return &preference
+}
@@ -0,0 +1,223 @@
+package repositories
+
+import (
+	"dating-app/src/models"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+TestProfilesQueryIsMutual - the candidates' own preferences are checked against the requesting user in the SQL,
+the MySQL half of TestMySQLGetProfilesForUserIsMutual for when there's no database to run it against
+*/
+func TestProfilesQueryIsMutual(t *testing.T) {
+	opts := models.FilterOpts{
+		ViewerAge:    31,
+		ViewerGender: models.NonBinary,
+		Now:          time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+	}
+	sql, args, err := profilesQuery(7, opts).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clauses := []struct {
+		sql  string
+		args []any
+	}{
+		{sql: "discovery_preferences.age_min IS NULL OR discovery_preferences.age_min <= ?", args: []any{31}},
+		{sql: "discovery_preferences.age_max IS NULL OR discovery_preferences.age_max >= ?", args: []any{31}},
+		{
+			sql: `NOT EXISTS (SELECT 1 FROM discovery_show_me WHERE discovery_show_me.user_id = users.id)
+OR ? IN (SELECT show_me FROM discovery_show_me WHERE discovery_show_me.user_id = users.id)`,
+			args: []any{models.NonBinary.ShowMe()},
+		},
+		{sql: "their_max_distance IS NULL OR distance <= their_max_distance"},
+	}
+
+	for _, clause := range clauses {
+		at := strings.Index(sql, clause.sql)
+		if at < 0 {
+			t.Errorf("query doesn't have %s", clause.sql)
+			continue
+		}
+		// the clause's args are the ones after those of every placeholder before it
+		first := strings.Count(sql[:at], "?")
+		for i, want := range clause.args {
+			if got := args[first+i]; got != want {
+				t.Errorf("%s arg %d = %#v, want %#v", clause.sql, i, got, want)
+			}
+		}
+	}
+
+	if strings.Count(sql, "?") != len(args) {
+		t.Errorf("%d placeholders but %d args", strings.Count(sql, "?"), len(args))
+	}
+}
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+/*
+golden - compares the built SQL and its args with testdata/<name>.golden, or rewrites the file with -update.
+floats are written to 9 significant figures so the files don't depend on the last bit of the geometry
+*/
+func golden(t *testing.T, name, sql string, args []any) {
+	t.Helper()
+
+	var built strings.Builder
+	built.WriteString(sql)
+	built.WriteString("\n-- args\n")
+	for i, arg := range args {
+		switch arg := arg.(type) {
+		case float64:
+			fmt.Fprintf(&built, "%d: float64 %.9g\n", i+1, arg)
+		case time.Time:
+			fmt.Fprintf(&built, "%d: time.Time %s\n", i+1, arg.Format(time.RFC3339))
+		case *time.Time:
+			fmt.Fprintf(&built, "%d: *time.Time %s\n", i+1, arg.Format(time.RFC3339))
+		default:
+			fmt.Fprintf(&built, "%d: %T %v\n", i+1, arg, arg)
+		}
+	}
+
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(built.String()), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v, run go test -update to create it", err)
+	}
+	if built.String() != string(want) {
+		t.Errorf("built query doesn't match %s, run go test -update and review the diff\ngot:\n%s\nwant:\n%s", path, built.String(), want)
+	}
+}
+
+func TestProfilesQueryGolden(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	bornBefore := time.Date(1999, 6, 1, 0, 0, 0, 0, time.UTC)
+	bornAfter := time.Date(1984, 6, 1, 0, 0, 0, 0, time.UTC)
+	london := models.Location{Latitude: 51.5, Longitude: -0.12}
+
+	base := func() models.FilterOpts {
+		return models.FilterOpts{
+			Origin:       london,
+			ViewerAge:    30,
+			ViewerGender: models.Female,
+			Now:          now,
+		}
+	}
+
+	tests := []struct {
+		name string
+		opts func(opts *models.FilterOpts)
+	}{
+		{name: "profiles_default", opts: func(opts *models.FilterOpts) {}},
+		{name: "profiles_default_after", opts: func(opts *models.FilterOpts) {
+			opts.After = &models.ProfileCursor{ID: 42}
+			opts.Limit = 21
+		}},
+		{name: "profiles_recommended_after", opts: func(opts *models.FilterOpts) {
+			opts.Sort = models.SortRecommended
+			opts.After = &models.ProfileCursor{Sort: models.SortRecommended, ID: 42, Likability: 17}
+			opts.Limit = 21
+		}},
+		{name: "profiles_distance_after", opts: func(opts *models.FilterOpts) {
+			opts.Sort = models.SortDistance
+			opts.After = &models.ProfileCursor{Sort: models.SortDistance, ID: 42, Distance: 12.5}
+			opts.Limit = 21
+		}},
+		{name: "profiles_desirability_after", opts: func(opts *models.FilterOpts) {
+			opts.Sort = models.SortDesirability
+			opts.After = &models.ProfileCursor{Sort: models.SortDesirability, ID: 42, Desirability: 1234.5, RatedAt: &now}
+			opts.Limit = 21
+		}},
+		{name: "profiles_ranked", opts: func(opts *models.FilterOpts) {
+			opts.Sort = models.SortRanked
+			opts.MaxDistance = 25
+			opts.Limit = 500
+		}},
+		{name: "profiles_bounding_box", opts: func(opts *models.FilterOpts) {
+			opts.MaxDistance = 25
+		}},
+		{name: "profiles_bounding_box_antimeridian", opts: func(opts *models.FilterOpts) {
+			opts.Origin = models.Location{Latitude: -17.7, Longitude: 179.9}
+			opts.MaxDistance = 50
+		}},
+		{name: "profiles_bounding_box_pole", opts: func(opts *models.FilterOpts) {
+			opts.Origin = models.Location{Latitude: 89.5, Longitude: 10}
+			opts.MaxDistance = 50
+		}},
+		{name: "profiles_genders", opts: func(opts *models.FilterOpts) {
+			opts.Genders = []models.GenderType{models.Male, models.NonBinary}
+		}},
+		{name: "profiles_ages", opts: func(opts *models.FilterOpts) {
+			opts.AgeMin = &bornBefore
+			opts.AgeMax = &bornAfter
+		}},
+		{name: "profiles_ids", opts: func(opts *models.FilterOpts) {
+			opts.ProfileIDs = []int{3, 5, 8}
+		}},
+		{name: "profiles_every_filter", opts: func(opts *models.FilterOpts) {
+			opts.AgeMin = &bornBefore
+			opts.AgeMax = &bornAfter
+			opts.Genders = []models.GenderType{models.Female}
+			opts.MaxDistance = 10
+			opts.ProfileIDs = []int{3, 5}
+			opts.Sort = models.SortDistance
+			opts.After = &models.ProfileCursor{Sort: models.SortDistance, ID: 3, Distance: 1.25}
+			opts.Limit = 2
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := base()
+			test.opts(&opts)
+
+			sql, args, err := profilesQuery(7, opts).Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Count(sql, "?") != len(args) {
+				t.Errorf("%d placeholders but %d args", strings.Count(sql, "?"), len(args))
+			}
+			golden(t, test.name, sql, args)
+		})
+	}
+}
+
+func TestMatchesQueryGolden(t *testing.T) {
+	tests := []struct {
+		name  string
+		after *models.MatchCursor
+	}{
+		{name: "matches_first_page"},
+		{name: "matches_after", after: &models.MatchCursor{MatchedAt: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC), UserID: 42}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sql, args, err := matchesQuery(7, test.after, 21)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Count(sql, "?") != len(args) {
+				t.Errorf("%d placeholders but %d args", strings.Count(sql, "?"), len(args))
+			}
+			golden(t, test.name, sql, args)
+		})
+	}
+}
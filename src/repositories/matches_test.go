@@ -0,0 +1,171 @@
+package repositories
+
+import (
+	"dating-app/src/models"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+/*
+setMatch - a SwipeFunc that leaves the pair in state, matched at matchedAt if that's Matched
+*/
+func setMatch(state models.MatchState, matchedAt time.Time) SwipeFunc {
+	return func(relationship *models.Match) (int, error) {
+		yes := models.Yes
+		relationship.LowSwipe = &yes
+		relationship.HighSwipe = &yes
+		relationship.State = state
+		relationship.MatchedAt = nil
+		if state == models.Matched {
+			relationship.MatchedAt = &matchedAt
+		}
+		relationship.UpdatedAt = matchedAt
+		return 0, nil
+	}
+}
+
+/*
+testGetMatches - a user matched on both sides of the pair, some at the same time, read back a page at a time.
+The user is created in the middle so they're the high user of the pairs with the users before them and the low user after.
+matched_at is whole seconds since MySQL doesn't store any more
+*/
+func testGetMatches(t *testing.T, users UserRepository, matches MatchRepository) {
+	prefix := time.Now().UnixNano()
+	create := func(i int) int {
+		user, err := users.Create(models.User{
+			Email:        fmt.Sprintf("matches%d-%d@example.com", prefix, i),
+			Password:     "hash",
+			DistanceUnit: models.Kilometres,
+			Profile: models.Profile{
+				Name:        fmt.Sprintf("Match %d", i),
+				Gender:      models.NotSpecified,
+				DateOfBirth: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return user.ID
+	}
+
+	var before, after []int
+	for i := 0; i < 6; i++ {
+		before = append(before, create(i))
+	}
+	userID := create(6)
+	for i := 7; i < 13; i++ {
+		after = append(after, create(i))
+	}
+
+	base := time.Now().UTC().Truncate(time.Second).Add(-24 * time.Hour)
+	hours := func(n int) time.Time { return base.Add(time.Duration(n) * time.Hour) }
+
+	type pair struct {
+		other     int
+		state     models.MatchState
+		matchedAt time.Time
+	}
+	pairs := []pair{
+		// the newest matches are all on the low side, so a page of them must not be filled from the high side
+		{other: after[0], state: models.Matched, matchedAt: hours(10)},
+		{other: after[1], state: models.Matched, matchedAt: hours(9)},
+		{other: after[2], state: models.Matched, matchedAt: hours(8)},
+		{other: before[0], state: models.Matched, matchedAt: hours(7)},
+		// a tie across the two sides, the higher id comes first
+		{other: before[1], state: models.Matched, matchedAt: hours(6)},
+		{other: after[3], state: models.Matched, matchedAt: hours(6)},
+		// a tie on one side
+		{other: before[2], state: models.Matched, matchedAt: hours(5)},
+		{other: before[3], state: models.Matched, matchedAt: hours(5)},
+		{other: after[4], state: models.Matched, matchedAt: hours(1)},
+		// neither pending nor unmatched pairs are matches
+		{other: before[4], state: models.Pending, matchedAt: hours(11)},
+		{other: after[5], state: models.Unmatched, matchedAt: hours(11)},
+	}
+	for _, p := range pairs {
+		if _, err := matches.Swipe(userID, p.other, setMatch(p.state, p.matchedAt), keepRating); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// someone else's match
+	if _, err := matches.Swipe(before[5], after[5], setMatch(models.Matched, hours(12)), keepRating); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []int{after[0], after[1], after[2], before[0], after[3], before[1], before[3], before[2], after[4]}
+	wantAt := map[int]time.Time{}
+	for _, p := range pairs {
+		wantAt[p.other] = p.matchedAt
+	}
+
+	all, err := matches.GetMatches(userID, nil, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := summaryIDs(all); !reflect.DeepEqual(got, want) {
+		t.Fatalf("matches = %v, want %v", got, want)
+	}
+	for _, match := range all {
+		if !match.MatchedAt.Equal(wantAt[match.Profile.ID]) {
+			t.Errorf("%d matched at %v, want %v", match.Profile.ID, match.MatchedAt, wantAt[match.Profile.ID])
+		}
+		if match.Profile.Name == "" || match.Profile.DateOfBirth.IsZero() {
+			t.Errorf("%d's profile isn't filled in: %+v", match.Profile.ID, match.Profile)
+		}
+		if match.Profile.LikabilityScore != nil {
+			t.Errorf("%d's profile has their likability", match.Profile.ID)
+		}
+		if match.LastMessage != nil {
+			t.Errorf("%d has a last message", match.Profile.ID)
+		}
+	}
+
+	for _, limit := range []int{1, 2, 3, 4, 9, 10} {
+		t.Run(fmt.Sprintf("pages of %d", limit), func(t *testing.T) {
+			var paged []int
+			var cursor *models.MatchCursor
+			for page := 0; page <= len(want); page++ {
+				matches, err := matches.GetMatches(userID, cursor, limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(matches) > limit {
+					t.Fatalf("page of %d matches, limit %d", len(matches), limit)
+				}
+				if len(matches) == 0 {
+					break
+				}
+				paged = append(paged, summaryIDs(matches)...)
+				last := matches[len(matches)-1]
+				cursor = &models.MatchCursor{MatchedAt: last.MatchedAt, UserID: last.Profile.ID}
+			}
+			if !reflect.DeepEqual(paged, want) {
+				t.Errorf("paged through %v, want %v", paged, want)
+			}
+		})
+	}
+
+	// the other user sees the match from their side
+	theirs, err := matches.GetMatches(before[0], nil, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := summaryIDs(theirs); !reflect.DeepEqual(got, []int{userID}) {
+		t.Errorf("%d's matches = %v, want [%d]", before[0], got, userID)
+	}
+}
+
+func summaryIDs(matches []*models.MatchSummary) []int {
+	ids := make([]int, len(matches))
+	for i, match := range matches {
+		ids[i] = match.Profile.ID
+	}
+	return ids
+}
+
+func TestMemoryGetMatches(t *testing.T) {
+	users := NewMemoryUserRepository()
+	testGetMatches(t, users, NewMemoryMatchRepository(users))
+}
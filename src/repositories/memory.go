@@ -0,0 +1,893 @@
+package repositories
+
+import (
+	"dating-app/src/geo"
+	"dating-app/src/models"
+	"dating-app/src/rating"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+MemoryUserRepository - thread-safe UserRepository kept entirely in memory
+Used to exercise the interactors and controllers without a running MySQL server
+*/
+type MemoryUserRepository struct {
+	mu     sync.RWMutex
+	nextID int
+	users  map[int]*memoryUser
+	ledger []models.LikabilityEntry
+}
+
+type memoryUser struct {
+	user        models.User
+	likability  int
+	rating      models.Rating
+	preferences models.DiscoveryPreferences
+}
+
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{
+		nextID: 1,
+		users:  make(map[int]*memoryUser),
+	}
+}
+
+/*
+GetByID - returns a copy of the stored user so callers can't mutate the store
+*/
+func (r *MemoryUserRepository) GetByID(userID int) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.users[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	user := stored.user
+	likability := stored.likability
+	user.LikabilityScore = &likability
+	return &user, nil
+}
+
+/*
+GetByEmail - returns the user with the matching email
+*/
+func (r *MemoryUserRepository) GetByEmail(email string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, stored := range r.users {
+		// the users table uses a case insensitive collation
+		if strings.EqualFold(stored.user.Email, email) {
+			user := stored.user
+			return &user, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+/*
+GetPasswordsAfter - returns the id and stored password of up to limit users with an id greater than afterID
+*/
+func (r *MemoryUserRepository) GetPasswordsAfter(afterID, limit int) ([]models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var users []models.User
+	for _, stored := range r.users {
+		if stored.user.ID > afterID {
+			users = append(users, models.User{Password: stored.user.Password, Profile: models.Profile{ID: stored.user.ID}})
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+	if len(users) > limit {
+		users = users[:limit]
+	}
+
+	return users, nil
+}
+
+/*
+Create - stores the user and assigns it the next id
+*/
+func (r *MemoryUserRepository) Create(user models.User) (models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, stored := range r.users {
+		if strings.EqualFold(stored.user.Email, user.Email) {
+			return user, ErrDuplicateEmail
+		}
+	}
+
+	user.ID = r.nextID
+	user.Version = 1
+	r.nextID++
+
+	stored := user
+	stored.LikabilityScore = nil
+	joinedAt := time.Now().UTC().Truncate(time.Second)
+	stored.JoinedAt = &joinedAt
+	r.users[user.ID] = &memoryUser{user: stored, rating: rating.New()}
+
+	return user, nil
+}
+
+/*
+UpdatePassword - replace the stored password hash for the provided user
+*/
+func (r *MemoryUserRepository) UpdatePassword(userID int, password string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if stored, ok := r.users[userID]; ok {
+		stored.user.Password = password
+	}
+
+	return nil
+}
+
+/*
+UpdateProfile - saves the user's editable details, only if the stored user is still at version
+*/
+func (r *MemoryUserRepository) UpdateProfile(user models.User, version int) (models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.users[user.ID]
+	if !ok || stored.user.Version != version {
+		return user, ErrVersionConflict
+	}
+
+	stored.user.Name = user.Name
+	stored.user.Gender = user.Gender
+	stored.user.GenderDescription = user.GenderDescription
+	stored.user.Bio = user.Bio
+	stored.user.JobTitle = user.JobTitle
+	stored.user.School = user.School
+	stored.user.HeightCM = user.HeightCM
+	stored.user.DistanceUnit = user.DistanceUnit
+	stored.user.Version = version + 1
+
+	user.Version = version + 1
+
+	return user, nil
+}
+
+/*
+UpdateLocation - record where the user is now
+*/
+func (r *MemoryUserRepository) UpdateLocation(userID int, location models.Location, locatedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if stored, ok := r.users[userID]; ok {
+		stored.user.Latitude = location.Latitude
+		stored.user.Longitude = location.Longitude
+		stored.user.LastLocatedAt = &locatedAt
+	}
+
+	return nil
+}
+
+/*
+recordSwipe - adds the ledger entry for a swipe, reversing the one for the swiper's previous swipe on the same user,
+and moves the cached likability by what changed. Swipes record themselves through MemoryMatchRepository
+*/
+func (r *MemoryUserRepository) recordSwipe(entry models.LikabilityEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	change := 0
+	reversed := make(map[int64]bool)
+	for _, recorded := range r.ledger {
+		if recorded.ReversesID != nil {
+			reversed[*recorded.ReversesID] = true
+		}
+	}
+	for i := len(r.ledger) - 1; i >= 0; i-- {
+		previous := r.ledger[i]
+		if previous.UserID != entry.UserID || previous.SwiperID != entry.SwiperID || previous.ReversesID != nil {
+			continue
+		}
+		if !reversed[previous.ID] {
+			r.appendEntry(models.LikabilityEntry{
+				UserID:     entry.UserID,
+				SwiperID:   entry.SwiperID,
+				Swipe:      previous.Swipe,
+				Delta:      -previous.Delta,
+				ReversesID: &previous.ID,
+				CreatedAt:  entry.CreatedAt,
+			})
+			change -= previous.Delta
+		}
+		break
+	}
+
+	r.appendEntry(entry)
+	change += entry.Delta
+
+	if stored, ok := r.users[entry.UserID]; ok {
+		stored.likability += change
+	}
+}
+
+/*
+rate - updates the swiped user's rating, swipes rate users through MemoryMatchRepository
+*/
+func (r *MemoryUserRepository) rate(swipedID, swiperID int, rate RateFunc) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	swiped, ok := r.users[swipedID]
+	if !ok {
+		return ErrNotFound
+	}
+	swiper, ok := r.users[swiperID]
+	if !ok {
+		return ErrNotFound
+	}
+
+	swiped.rating = rate(swiped.rating, swiper.rating)
+
+	return nil
+}
+
+func (r *MemoryUserRepository) appendEntry(entry models.LikabilityEntry) {
+	entry.ID = int64(len(r.ledger) + 1)
+	r.ledger = append(r.ledger, entry)
+}
+
+/*
+GetPreferences - the user's discovery preferences, with MaxDistance in miles
+*/
+func (r *MemoryUserRepository) GetPreferences(userID int) (models.DiscoveryPreferences, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.users[userID]
+	if !ok {
+		return models.DiscoveryPreferences{}, ErrNotFound
+	}
+
+	preferences := stored.preferences
+	preferences.ShowMe = append([]models.ShowMe(nil), preferences.ShowMe...)
+	preferences.Unit = models.Miles
+
+	return preferences, nil
+}
+
+/*
+SavePreferences - replaces the user's discovery preferences
+*/
+func (r *MemoryUserRepository) SavePreferences(userID int, preferences models.DiscoveryPreferences) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if stored, ok := r.users[userID]; ok {
+		preferences = preferences.In(models.Miles)
+		preferences.ShowMe = append([]models.ShowMe(nil), preferences.ShowMe...)
+		stored.preferences = preferences
+	}
+
+	return nil
+}
+
+/*
+memoryCandidate - a stored user as a profile, along with who they want to see
+*/
+type memoryCandidate struct {
+	profile     *models.Profile
+	rating      models.Rating
+	preferences models.DiscoveryPreferences
+}
+
+/*
+candidates - snapshot of every stored user, ordered by id like the users table
+*/
+func (r *MemoryUserRepository) candidates() []memoryCandidate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	candidates := make([]memoryCandidate, 0, len(r.users))
+	for _, stored := range r.users {
+		profile := stored.user.Profile
+		likability := stored.likability
+		profile.LikabilityScore = &likability
+		candidates = append(candidates, memoryCandidate{profile: &profile, rating: stored.rating, preferences: stored.preferences})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].profile.ID < candidates[j].profile.ID
+	})
+
+	return candidates
+}
+
+/*
+MemoryMatchRepository - thread-safe MatchRepository kept entirely in memory
+It reads profiles from the MemoryUserRepository it was created with
+*/
+type MemoryMatchRepository struct {
+	mu      sync.RWMutex
+	users   *MemoryUserRepository
+	matches []models.Match
+}
+
+func NewMemoryMatchRepository(users *MemoryUserRepository) *MemoryMatchRepository {
+	return &MemoryMatchRepository{
+		users: users,
+	}
+}
+
+/*
+GetProfilesForUser - applies the same exclusions and filters as the MySQL query
+profiles the user has already swiped are hidden, as are users who have swiped on them unless that swipe is still pending.
+Sessions are kept by MemorySessionRepository, so profiles don't know when users were last active
+*/
+func (r *MemoryMatchRepository) GetProfilesForUser(userID int, opts models.FilterOpts) ([]*models.Profile, error) {
+	excluded := r.excluded(userID)
+	excluded[userID] = true
+
+	var profiles []*models.Profile
+	for _, candidate := range r.users.candidates() {
+		profile := candidate.profile
+		if excluded[profile.ID] || !wantsToSee(candidate.preferences, opts.ViewerAge, opts.ViewerGender) {
+			continue
+		}
+		if opts.ProfileIDs != nil && !containsInt(opts.ProfileIDs, profile.ID) {
+			continue
+		}
+		if opts.AgeMin != nil && !opts.AgeMin.IsZero() && !profile.DateOfBirth.Before(*opts.AgeMin) {
+			continue
+		}
+		if opts.AgeMax != nil && !opts.AgeMax.IsZero() && !profile.DateOfBirth.After(*opts.AgeMax) {
+			continue
+		}
+		if len(opts.Genders) > 0 && !containsGender(opts.Genders, profile.Gender) {
+			continue
+		}
+
+		distance := geo.Haversine(opts.Origin.Latitude, opts.Origin.Longitude, profile.Latitude, profile.Longitude)
+		if opts.MaxDistance > 0 && distance > opts.MaxDistance {
+			continue
+		}
+		if candidate.preferences.MaxDistance != nil && distance > *candidate.preferences.MaxDistance {
+			continue
+		}
+		profile.Distance = &distance
+		desirability := rating.Desirability(candidate.rating, opts.Now)
+		profile.Desirability = &desirability
+
+		if opts.After != nil && !opts.After.Precedes(profile) {
+			continue
+		}
+
+		profiles = append(profiles, profile)
+	}
+
+	switch opts.Sort {
+	case models.SortRecommended:
+		sort.SliceStable(profiles, func(i, j int) bool {
+			return *profiles[i].LikabilityScore > *profiles[j].LikabilityScore
+		})
+	case models.SortDistance, models.SortRanked:
+		sort.SliceStable(profiles, func(i, j int) bool {
+			return *profiles[i].Distance < *profiles[j].Distance
+		})
+	case models.SortDesirability:
+		sort.SliceStable(profiles, func(i, j int) bool {
+			return *profiles[i].Desirability > *profiles[j].Desirability
+		})
+	}
+
+	if opts.Limit > 0 && len(profiles) > opts.Limit {
+		profiles = profiles[:opts.Limit]
+	}
+
+	return profiles, nil
+}
+
+/*
+wantsToSee - whether a candidate's preferences, with their max distance in miles, include a user of this age and gender
+the distance is checked separately once it's known
+*/
+func wantsToSee(preferences models.DiscoveryPreferences, age int, gender models.GenderType) bool {
+	if preferences.AgeMin != nil && age < *preferences.AgeMin {
+		return false
+	}
+	if preferences.AgeMax != nil && age > *preferences.AgeMax {
+		return false
+	}
+	if len(preferences.ShowMe) == 0 {
+		return true
+	}
+	for _, showMe := range preferences.ShowMe {
+		if showMe == gender.ShowMe() {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+GetMatches - the users the user is matched with, most recently matched first, starting after the cursor
+*/
+func (r *MemoryMatchRepository) GetMatches(userID int, after *models.MatchCursor, limit int) ([]*models.MatchSummary, error) {
+	r.mu.RLock()
+	var matches []*models.MatchSummary
+	for _, match := range r.matches {
+		if match.State != models.Matched || (match.LowUserID != userID && match.HighUserID != userID) {
+			continue
+		}
+		other := match.LowUserID
+		if other == userID {
+			other = match.HighUserID
+		}
+		if after != nil && !after.Precedes(*match.MatchedAt, other) {
+			continue
+		}
+		matches = append(matches, &models.MatchSummary{Profile: &models.Profile{ID: other}, MatchedAt: *match.MatchedAt})
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		if !matches[i].MatchedAt.Equal(matches[j].MatchedAt) {
+			return matches[i].MatchedAt.After(matches[j].MatchedAt)
+		}
+		return matches[i].Profile.ID > matches[j].Profile.ID
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	for _, match := range matches {
+		user, err := r.users.GetByID(match.Profile.ID)
+		if err != nil {
+			return nil, err
+		}
+		profile := user.Profile
+		profile.LikabilityScore = nil
+		match.Profile = &profile
+	}
+
+	return matches, nil
+}
+
+/*
+excluded - everyone the user has swiped on, and everyone who has swiped on them unless that swipe is still pending
+*/
+func (r *MemoryMatchRepository) excluded(userID int) map[int]bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	excluded := map[int]bool{}
+	for _, match := range r.matches {
+		if match.LowUserID != userID && match.HighUserID != userID {
+			continue
+		}
+		other := match.LowUserID
+		if other == userID {
+			other = match.HighUserID
+		}
+		if match.SwipeOf(userID) != nil || match.State != models.Pending {
+			excluded[other] = true
+		}
+	}
+	return excluded
+}
+
+/*
+Swipe - applies the swipe to the pair's relationship and rates the swiped user, the whole repository is locked while it does
+*/
+func (r *MemoryMatchRepository) Swipe(userID, profileID int, swipe SwipeFunc, rate RateFunc) (*models.Match, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	low, high := models.Pair(userID, profileID)
+	relationship := models.Match{LowUserID: low, HighUserID: high}
+	index := -1
+	for i, match := range r.matches {
+		if match.LowUserID == low && match.HighUserID == high {
+			relationship = match
+			index = i
+		}
+	}
+
+	first := relationship.SwipeOf(userID) == nil
+	likability, err := swipe(&relationship)
+	if err != nil {
+		return nil, err
+	}
+	if first {
+		err = r.users.rate(profileID, userID, rate)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if index < 0 {
+		r.matches = append(r.matches, relationship)
+	} else {
+		r.matches[index] = relationship
+	}
+	if swiped := relationship.SwipeOf(userID); swiped != nil {
+		r.users.recordSwipe(models.LikabilityEntry{
+			UserID:    profileID,
+			SwiperID:  userID,
+			Swipe:     *swiped,
+			Delta:     likability,
+			CreatedAt: relationship.UpdatedAt,
+		})
+	}
+
+	return &relationship, nil
+}
+
+/*
+MemoryLikabilityRepository - thread-safe LikabilityRepository over the ledger kept by a MemoryUserRepository
+*/
+type MemoryLikabilityRepository struct {
+	users *MemoryUserRepository
+}
+
+func NewMemoryLikabilityRepository(users *MemoryUserRepository) *MemoryLikabilityRepository {
+	return &MemoryLikabilityRepository{
+		users: users,
+	}
+}
+
+/*
+Repair - checks up to limit users with an id greater than afterID, resetting the cached likability of any whose
+doesn't match the total of their ledger entries. Returns the last id checked, 0 once there are none left, and the users that were reset
+*/
+func (r *MemoryLikabilityRepository) Repair(afterID, limit int) (int, []models.LikabilityRepair, error) {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	var userIDs []int
+	for userID := range r.users.users {
+		if userID > afterID {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	sort.Ints(userIDs)
+	if len(userIDs) > limit {
+		userIDs = userIDs[:limit]
+	}
+
+	totals := make(map[int]int)
+	for _, entry := range r.users.ledger {
+		totals[entry.UserID] += entry.Delta
+	}
+
+	lastID := 0
+	var repairs []models.LikabilityRepair
+	for _, userID := range userIDs {
+		stored := r.users.users[userID]
+		if stored.likability != totals[userID] {
+			repairs = append(repairs, models.LikabilityRepair{UserID: userID, Cached: stored.likability, Ledger: totals[userID]})
+			stored.likability = totals[userID]
+		}
+		lastID = userID
+	}
+
+	return lastID, repairs, nil
+}
+
+/*
+MemoryProfileRepository - thread-safe ProfileRepository kept entirely in memory
+the vocabulary and catalog it's created with are all treated as active
+*/
+type MemoryProfileRepository struct {
+	mu            sync.RWMutex
+	interests     []models.Interest
+	prompts       []models.Prompt
+	userInterests map[int][]int
+	userPrompts   map[int][]models.PromptAnswer
+}
+
+func NewMemoryProfileRepository(interests []models.Interest, prompts []models.Prompt) *MemoryProfileRepository {
+	return &MemoryProfileRepository{
+		interests:     interests,
+		prompts:       prompts,
+		userInterests: make(map[int][]int),
+		userPrompts:   make(map[int][]models.PromptAnswer),
+	}
+}
+
+func (r *MemoryProfileRepository) GetInterests() ([]models.Interest, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	interests := append([]models.Interest(nil), r.interests...)
+	sort.Slice(interests, func(i, j int) bool {
+		return interests[i].Name < interests[j].Name
+	})
+
+	return interests, nil
+}
+
+func (r *MemoryProfileRepository) GetPrompts() ([]models.Prompt, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]models.Prompt(nil), r.prompts...), nil
+}
+
+func (r *MemoryProfileRepository) GetInterestsForUsers(userIDs []int) (map[int][]models.Interest, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	interests := make(map[int][]models.Interest, len(userIDs))
+	for _, userID := range userIDs {
+		for _, interest := range r.interests {
+			if containsInt(r.userInterests[userID], interest.ID) {
+				interests[userID] = append(interests[userID], interest)
+			}
+		}
+		sort.Slice(interests[userID], func(i, j int) bool {
+			return interests[userID][i].Name < interests[userID][j].Name
+		})
+	}
+
+	return interests, nil
+}
+
+func (r *MemoryProfileRepository) GetPromptsForUsers(userIDs []int) (map[int][]models.PromptAnswer, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	answers := make(map[int][]models.PromptAnswer, len(userIDs))
+	for _, userID := range userIDs {
+		for _, answer := range r.userPrompts[userID] {
+			for _, prompt := range r.prompts {
+				if prompt.ID == answer.PromptID {
+					answer.Prompt = prompt.Text
+				}
+			}
+			answers[userID] = append(answers[userID], answer)
+		}
+	}
+
+	return answers, nil
+}
+
+func (r *MemoryProfileRepository) SetInterests(userID int, interestIDs []int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.userInterests[userID] = append([]int(nil), interestIDs...)
+
+	return nil
+}
+
+func (r *MemoryProfileRepository) SetPrompts(userID int, answers []models.PromptAnswer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.userPrompts[userID] = append([]models.PromptAnswer(nil), answers...)
+
+	return nil
+}
+
+/*
+MemoryPhotoRepository - thread-safe PhotoRepository kept entirely in memory
+*/
+type MemoryPhotoRepository struct {
+	mu     sync.RWMutex
+	nextID int
+	photos map[int][]models.Photo
+}
+
+func NewMemoryPhotoRepository() *MemoryPhotoRepository {
+	return &MemoryPhotoRepository{
+		nextID: 1,
+		photos: make(map[int][]models.Photo),
+	}
+}
+
+func (r *MemoryPhotoRepository) GetPhotosForUsers(userIDs []int) (map[int][]models.Photo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	photos := make(map[int][]models.Photo, len(userIDs))
+	for _, userID := range userIDs {
+		if len(r.photos[userID]) > 0 {
+			photos[userID] = append([]models.Photo(nil), r.photos[userID]...)
+		}
+	}
+
+	return photos, nil
+}
+
+func (r *MemoryPhotoRepository) CountPhotos(userID int) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return len(r.photos[userID]), nil
+}
+
+func (r *MemoryPhotoRepository) AddPhoto(photo models.Photo, limit int) (models.Photo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.photos[photo.UserID]) >= limit {
+		return photo, ErrPhotoLimit
+	}
+
+	photo.ID = r.nextID
+	r.nextID++
+	photo.Position = len(r.photos[photo.UserID])
+	r.photos[photo.UserID] = append(r.photos[photo.UserID], photo)
+
+	return photo, nil
+}
+
+func (r *MemoryPhotoRepository) DeletePhoto(userID, photoID int) (models.Photo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	photos := r.photos[userID]
+	for i, photo := range photos {
+		if photo.ID != photoID {
+			continue
+		}
+
+		remaining := append(append([]models.Photo(nil), photos[:i]...), photos[i+1:]...)
+		for position := range remaining {
+			remaining[position].Position = position
+		}
+		r.photos[userID] = remaining
+
+		return photo, nil
+	}
+
+	return models.Photo{}, ErrNotFound
+}
+
+func (r *MemoryPhotoRepository) OrderPhotos(userID int, photoIDs []int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	photos := r.photos[userID]
+	for i := range photos {
+		for position, photoID := range photoIDs {
+			if photos[i].ID == photoID {
+				photos[i].Position = position
+			}
+		}
+	}
+	sort.SliceStable(photos, func(i, j int) bool {
+		return photos[i].Position < photos[j].Position
+	})
+
+	return nil
+}
+
+func containsGender(values []models.GenderType, value models.GenderType) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+MemorySessionRepository - thread-safe SessionRepository kept entirely in memory
+*/
+type MemorySessionRepository struct {
+	mu       sync.RWMutex
+	sessions map[string]*models.Session
+}
+
+func NewMemorySessionRepository() *MemorySessionRepository {
+	return &MemorySessionRepository{
+		sessions: make(map[string]*models.Session),
+	}
+}
+
+func (r *MemorySessionRepository) Create(session *models.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *session
+	r.sessions[session.ID] = &stored
+
+	return nil
+}
+
+func (r *MemorySessionRepository) GetByID(sessionID string) (*models.Session, error) {
+	return r.find(func(session *models.Session) bool {
+		return session.ID == sessionID
+	})
+}
+
+func (r *MemorySessionRepository) GetByRefreshTokenHash(hash string) (*models.Session, error) {
+	return r.find(func(session *models.Session) bool {
+		return session.RefreshTokenHash == hash
+	})
+}
+
+func (r *MemorySessionRepository) GetByPreviousTokenHash(hash string) (*models.Session, error) {
+	return r.find(func(session *models.Session) bool {
+		return session.PreviousTokenHash != "" && session.PreviousTokenHash == hash
+	})
+}
+
+func (r *MemorySessionRepository) find(match func(session *models.Session) bool) (*models.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, stored := range r.sessions {
+		if match(stored) {
+			session := *stored
+			return &session, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+/*
+Rotate - swaps the refresh token hash, only if the session still has oldHash and hasn't been revoked
+*/
+func (r *MemorySessionRepository) Rotate(sessionID, oldHash, newHash string, usedAt, expiresAt time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.sessions[sessionID]
+	if !ok || stored.RefreshTokenHash != oldHash || stored.RevokedAt != nil {
+		return false, nil
+	}
+
+	stored.PreviousTokenHash = oldHash
+	stored.RefreshTokenHash = newHash
+	stored.LastUsedAt = usedAt
+	stored.ExpiresAt = expiresAt
+
+	return true, nil
+}
+
+func (r *MemorySessionRepository) Revoke(sessionID string, revokedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if stored, ok := r.sessions[sessionID]; ok && stored.RevokedAt == nil {
+		stored.RevokedAt = &revokedAt
+	}
+
+	return nil
+}
+
+func (r *MemorySessionRepository) RevokeAllForUser(userID int, revokedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, stored := range r.sessions {
+		if stored.UserID == userID && stored.RevokedAt == nil {
+			revoked := revokedAt
+			stored.RevokedAt = &revoked
+		}
+	}
+
+	return nil
+}
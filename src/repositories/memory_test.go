@@ -0,0 +1,143 @@
+package repositories
+
+import (
+	"dating-app/src/geo"
+	"dating-app/src/models"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemoryGetProfilesForUserIsMutual(t *testing.T) {
+	testGetProfilesForUserIsMutual(t, func(t *testing.T) (UserRepository, MatchRepository) {
+		users := NewMemoryUserRepository()
+		return users, NewMemoryMatchRepository(users)
+	})
+}
+
+/*
+testGetProfilesForUserIsMutual - a is shown b only if b would be shown a, each case with a fresh pair of users from repositories
+*/
+func testGetProfilesForUserIsMutual(t *testing.T, repositories func(t *testing.T) (UserRepository, MatchRepository)) {
+	now := time.Now().UTC().Truncate(time.Second)
+	prefix := time.Now().UnixNano()
+	origin := models.Location{Latitude: 51.5, Longitude: -0.12}
+	// b is 20 miles due north of a
+	bLocation := models.Location{Latitude: origin.Latitude + 20/geo.MilesPerDegreeLatitude, Longitude: origin.Longitude}
+
+	intPtr := func(value int) *int { return &value }
+	floatPtr := func(value float64) *float64 { return &value }
+	// bornBetween - the filters for ages min to max, the same way the interactors build them
+	bornBetween := func(min, max int) (*time.Time, *time.Time) {
+		bornBefore := now.AddDate(-min, 0, 0)
+		bornAfter := now.AddDate(-max-1, 0, 0)
+		return &bornBefore, &bornAfter
+	}
+
+	tests := []struct {
+		name string
+		// bPreferences - who b wants to see, a accepts b in every case
+		bPreferences models.DiscoveryPreferences
+		// bOpts - b's own filters for the same preferences
+		bOpts    func(opts *models.FilterOpts)
+		wantSeen bool
+	}{
+		{
+			name:         "both accept each other",
+			bPreferences: models.DiscoveryPreferences{},
+			bOpts:        func(opts *models.FilterOpts) {},
+			wantSeen:     true,
+		},
+		{
+			name:         "b's age range excludes a",
+			bPreferences: models.DiscoveryPreferences{AgeMin: intPtr(18), AgeMax: intPtr(25)},
+			bOpts: func(opts *models.FilterOpts) {
+				opts.AgeMin, opts.AgeMax = bornBetween(18, 25)
+			},
+		},
+		{
+			name:         "b's show me excludes a's gender",
+			bPreferences: models.DiscoveryPreferences{ShowMe: []models.ShowMe{models.ShowMen}},
+			bOpts: func(opts *models.FilterOpts) {
+				opts.Genders = models.GendersFor([]models.ShowMe{models.ShowMen})
+			},
+		},
+		{
+			name:         "b's max distance is less than the distance to a",
+			bPreferences: models.DiscoveryPreferences{MaxDistance: floatPtr(10), Unit: models.Miles},
+			bOpts: func(opts *models.FilterOpts) {
+				opts.MaxDistance = 10
+			},
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			users, matches := repositories(t)
+
+			a, err := users.Create(models.User{Email: fmt.Sprintf("mutual%d-%d-a@example.com", prefix, i), Password: "hash", DistanceUnit: models.Miles, Profile: models.Profile{
+				Name:   "A",
+				Gender: models.Female, DateOfBirth: now.AddDate(-30, 0, -1), Latitude: origin.Latitude, Longitude: origin.Longitude,
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := users.Create(models.User{Email: fmt.Sprintf("mutual%d-%d-b@example.com", prefix, i), Password: "hash", DistanceUnit: models.Miles, Profile: models.Profile{
+				Name:   "B",
+				Gender: models.Male, DateOfBirth: now.AddDate(-35, 0, -1), Latitude: bLocation.Latitude, Longitude: bLocation.Longitude,
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			aPreferences := models.DiscoveryPreferences{AgeMin: intPtr(30), AgeMax: intPtr(40), ShowMe: []models.ShowMe{models.ShowMen},
+				MaxDistance: floatPtr(50), Unit: models.Miles}
+			if err = users.SavePreferences(a.ID, aPreferences); err != nil {
+				t.Fatal(err)
+			}
+			if err = users.SavePreferences(b.ID, test.bPreferences); err != nil {
+				t.Fatal(err)
+			}
+
+			aOpts := models.FilterOpts{
+				Genders:      models.GendersFor(aPreferences.ShowMe),
+				Origin:       origin,
+				MaxDistance:  50,
+				ViewerAge:    30,
+				ViewerGender: models.Female,
+				Now:          now,
+			}
+			aOpts.AgeMin, aOpts.AgeMax = bornBetween(30, 40)
+			bOpts := models.FilterOpts{
+				Origin:       bLocation,
+				ViewerAge:    35,
+				ViewerGender: models.Male,
+				Now:          now,
+			}
+			test.bOpts(&bOpts)
+
+			if seen := sees(t, matches, a.ID, aOpts, b.ID); seen != test.wantSeen {
+				t.Errorf("a sees b = %v, want %v", seen, test.wantSeen)
+			}
+			if seen := sees(t, matches, b.ID, bOpts, a.ID); seen != test.wantSeen {
+				t.Errorf("b sees a = %v, want %v", seen, test.wantSeen)
+			}
+		})
+	}
+}
+
+/*
+sees - whether the profile is among those the user is shown
+*/
+func sees(t *testing.T, matches MatchRepository, userID int, opts models.FilterOpts, profileID int) bool {
+	t.Helper()
+	profiles, err := matches.GetProfilesForUser(userID, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, profile := range profiles {
+		if profile.ID == profileID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/go-sql-driver/mysql"
+	"time"
+)
+
+/*
+mysqlDuplicateEntry - error number MySQL returns when an insert or update violates a unique index
+*/
+const mysqlDuplicateEntry = 1062
+
+func isDuplicateEntry(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDuplicateEntry
+}
+
+/*
+mysqlDeadlock - error number MySQL returns when it rolls back a transaction to break a deadlock, it's safe to retry
+*/
+const mysqlDeadlock = 1213
+
+func isDeadlock(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDeadlock
+}
+
+/*
+mysqlDateTime - layout MySQL returns datetime columns in, the DSN doesn't set parseTime so they're scanned as strings
+*/
+const mysqlDateTime = "2006-01-02 15:04:05"
+
+func parseDateTime(value string) (time.Time, error) {
+	return time.Parse(mysqlDateTime, value)
+}
+
+/*
+nullableDateTime - a nullable datetime column as a pointer, nil when the column is NULL
+*/
+func nullableDateTime(value sql.NullString) (*time.Time, error) {
+	if !value.Valid {
+		return nil, nil
+	}
+	t, err := parseDateTime(value.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+/*
+toArgs - ids as query args, for query.In
+*/
+func toArgs(ids []int) []any {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return args
+}
+
+/*
+nullableInt - a nullable int column as a pointer, nil when the column is NULL
+*/
+func nullableInt(value sql.NullInt32) *int {
+	if !value.Valid {
+		return nil
+	}
+	i := int(value.Int32)
+	return &i
+}
@@ -0,0 +1,121 @@
+//go:build mysql
+
+package repositories
+
+import (
+	"database/sql"
+	"dating-app/src/migrations"
+	"dating-app/src/models"
+	_ "github.com/go-sql-driver/mysql"
+	"os"
+	"testing"
+)
+
+/*
+openTestDB - the database in TEST_DATABASE_DSN, migrated up. Run these tests with
+TEST_DATABASE_DSN=root:mypassword@tcp(localhost:3306)/testdb go test -tags mysql ./src/repositories
+*/
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN isn't set")
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	runner, err := migrations.NewRunner(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = runner.Up(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestMySQLSwipeConcurrentMutualYes(t *testing.T) {
+	testConcurrentMutualYes(t, mysqlSwipeStore(openTestDB(t)), 50, 5)
+}
+
+func mysqlSwipeStore(db *sql.DB) swipeStore {
+	return swipeStore{
+		users:   NewMySQLUserRepository(db),
+		matches: NewMySQLMatchRepository(db),
+		rows: func(low, high int) ([]models.Match, error) {
+			rows, err := db.Query("SELECT state, matched_at FROM matches WHERE low_user_id = ? AND high_user_id = ?", low, high)
+			if err != nil {
+				return nil, err
+			}
+			defer rows.Close()
+
+			var matches []models.Match
+			for rows.Next() {
+				match := models.Match{LowUserID: low, HighUserID: high}
+				var matchedAt sql.NullString
+				if err = rows.Scan(&match.State, &matchedAt); err != nil {
+					return nil, err
+				}
+				if matchedAt.Valid {
+					t, err := parseDateTime(matchedAt.String)
+					if err != nil {
+						return nil, err
+					}
+					match.MatchedAt = &t
+				}
+				matches = append(matches, match)
+			}
+			return matches, rows.Err()
+		},
+		ledger: func(swipedID, swiperID int) ([]models.LikabilityEntry, error) {
+			rows, err := db.Query("SELECT id, swipe, delta, reverses_id FROM likability_ledger WHERE user_id = ? AND swiper_id = ?", swipedID, swiperID)
+			if err != nil {
+				return nil, err
+			}
+			defer rows.Close()
+
+			var entries []models.LikabilityEntry
+			for rows.Next() {
+				entry := models.LikabilityEntry{UserID: swipedID, SwiperID: swiperID}
+				var reverses sql.NullInt64
+				if err = rows.Scan(&entry.ID, &entry.Swipe, &entry.Delta, &reverses); err != nil {
+					return nil, err
+				}
+				if reverses.Valid {
+					entry.ReversesID = &reverses.Int64
+				}
+				entries = append(entries, entry)
+			}
+			return entries, rows.Err()
+		},
+	}
+}
+
+/*
+TestMySQLGetMatches - each side of the UNION ALL is limited on its own before the two are merged
+*/
+func TestMySQLGetMatches(t *testing.T) {
+	db := openTestDB(t)
+
+	testGetMatches(t, NewMySQLUserRepository(db), NewMySQLMatchRepository(db))
+}
+
+func TestMySQLGetProfilesForUserIsMutual(t *testing.T) {
+	db := openTestDB(t)
+
+	testGetProfilesForUserIsMutual(t, func(t *testing.T) (UserRepository, MatchRepository) {
+		return NewMySQLUserRepository(db), NewMySQLMatchRepository(db)
+	})
+}
+
+func TestMySQLLedger(t *testing.T) {
+	db := openTestDB(t)
+
+	testLedger(t, mysqlSwipeStore(db), NewMySQLLikabilityRepository(db), func(userID, likability int) error {
+		_, err := db.Exec("UPDATE users SET likability = ? WHERE id = ?", likability, userID)
+		return err
+	})
+}
@@ -0,0 +1,157 @@
+package repositories
+
+import (
+	"database/sql"
+	"dating-app/src/models"
+	"dating-app/src/query"
+	"errors"
+)
+
+/*
+MySQLPhotoRepository - PhotoRepository backed by the photos table
+*/
+type MySQLPhotoRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLPhotoRepository(db *sql.DB) *MySQLPhotoRepository {
+	return &MySQLPhotoRepository{
+		db: db,
+	}
+}
+
+/*
+GetPhotosForUsers - each of the users' photos in the order they arranged them, keyed by user id
+*/
+func (r *MySQLPhotoRepository) GetPhotosForUsers(userIDs []int) (map[int][]models.Photo, error) {
+	photosQuery, args, err := query.Select("id", "user_id", "position", "storage_key", "thumbnail_key", "width", "height", "created_at").
+		From("photos").
+		Where(query.In("user_id", toArgs(userIDs)...)).
+		OrderBy("user_id", "position", "id").
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(photosQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	photos := make(map[int][]models.Photo, len(userIDs))
+	for rows.Next() {
+		photo := models.Photo{}
+		var createdAt string
+		err = rows.Scan(&photo.ID, &photo.UserID, &photo.Position, &photo.Key, &photo.ThumbnailKey, &photo.Width, &photo.Height, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		photo.CreatedAt, err = parseDateTime(createdAt)
+		if err != nil {
+			return nil, err
+		}
+		photos[photo.UserID] = append(photos[photo.UserID], photo)
+	}
+	return photos, rows.Err()
+}
+
+/*
+CountPhotos - how many photos the user has
+*/
+func (r *MySQLPhotoRepository) CountPhotos(userID int) (int, error) {
+	var count int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM photos WHERE user_id = ?", userID).Scan(&count)
+	return count, err
+}
+
+/*
+AddPhoto - adds the photo after the user's others, unless they already have limit photos
+the user's photos are locked while they're counted so two uploads at once can't both take the last place
+*/
+func (r *MySQLPhotoRepository) AddPhoto(photo models.Photo, limit int) (models.Photo, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return photo, err
+	}
+	defer tx.Rollback()
+
+	var count int
+	err = tx.QueryRow("SELECT COUNT(*) FROM photos WHERE user_id = ? FOR UPDATE", photo.UserID).Scan(&count)
+	if err != nil {
+		return photo, err
+	}
+	if count >= limit {
+		return photo, ErrPhotoLimit
+	}
+
+	photo.Position = count
+	result, err := tx.Exec(`INSERT INTO photos (user_id, position, storage_key, thumbnail_key, width, height, created_at)
+VALUES (?,?,?,?,?,?,?)`,
+		photo.UserID, photo.Position, photo.Key, photo.ThumbnailKey, photo.Width, photo.Height, photo.CreatedAt.UTC())
+	if err != nil {
+		return photo, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return photo, err
+	}
+	photo.ID = int(id)
+
+	return photo, tx.Commit()
+}
+
+/*
+DeletePhoto - removes the user's photo and moves the ones after it up, returning it so its files can be deleted
+*/
+func (r *MySQLPhotoRepository) DeletePhoto(userID, photoID int) (models.Photo, error) {
+	photo := models.Photo{ID: photoID, UserID: userID}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return photo, err
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRow("SELECT position, storage_key, thumbnail_key FROM photos WHERE id = ? AND user_id = ? FOR UPDATE", photoID, userID)
+	err = row.Scan(&photo.Position, &photo.Key, &photo.ThumbnailKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return photo, ErrNotFound
+	}
+	if err != nil {
+		return photo, err
+	}
+
+	_, err = tx.Exec("DELETE FROM photos WHERE id = ?", photoID)
+	if err != nil {
+		return photo, err
+	}
+
+	_, err = tx.Exec("UPDATE photos SET position = position - 1 WHERE user_id = ? AND position > ?", userID, photo.Position)
+	if err != nil {
+		return photo, err
+	}
+
+	return photo, tx.Commit()
+}
+
+/*
+OrderPhotos - puts the user's photos in the order of photoIDs, callers are expected to have checked they're all the user's photos
+*/
+func (r *MySQLPhotoRepository) OrderPhotos(userID int, photoIDs []int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for position, photoID := range photoIDs {
+		_, err = tx.Exec("UPDATE photos SET position = ? WHERE id = ? AND user_id = ?", position, photoID, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,184 @@
+package repositories
+
+import (
+	"database/sql"
+	"dating-app/src/models"
+	"dating-app/src/query"
+	"strings"
+)
+
+/*
+MySQLProfileRepository - ProfileRepository backed by the interests and prompts tables and the users' picks from them
+*/
+type MySQLProfileRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLProfileRepository(db *sql.DB) *MySQLProfileRepository {
+	return &MySQLProfileRepository{
+		db: db,
+	}
+}
+
+/*
+GetInterests - the interests users can currently pick from, in name order
+*/
+func (r *MySQLProfileRepository) GetInterests() ([]models.Interest, error) {
+	rows, err := r.db.Query("SELECT id, slug, name FROM interests WHERE active = 1 ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var interests []models.Interest
+	for rows.Next() {
+		interest := models.Interest{}
+		err = rows.Scan(&interest.ID, &interest.Slug, &interest.Name)
+		if err != nil {
+			return nil, err
+		}
+		interests = append(interests, interest)
+	}
+	return interests, rows.Err()
+}
+
+/*
+GetPrompts - the prompts users can currently answer
+*/
+func (r *MySQLProfileRepository) GetPrompts() ([]models.Prompt, error) {
+	rows, err := r.db.Query("SELECT id, text FROM prompts WHERE active = 1 ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prompts []models.Prompt
+	for rows.Next() {
+		prompt := models.Prompt{}
+		err = rows.Scan(&prompt.ID, &prompt.Text)
+		if err != nil {
+			return nil, err
+		}
+		prompts = append(prompts, prompt)
+	}
+	return prompts, rows.Err()
+}
+
+/*
+GetInterestsForUsers - the interests each of the users picked, keyed by user id
+retired interests stay on the profiles of users who already picked them
+*/
+func (r *MySQLProfileRepository) GetInterestsForUsers(userIDs []int) (map[int][]models.Interest, error) {
+	interestsQuery, args, err := query.Select("user_interests.user_id", "interests.id", "interests.slug", "interests.name").
+		From("user_interests JOIN interests ON interests.id = user_interests.interest_id").
+		Where(query.In("user_interests.user_id", toArgs(userIDs)...)).
+		OrderBy("user_interests.user_id", "interests.name").
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(interestsQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	interests := make(map[int][]models.Interest, len(userIDs))
+	for rows.Next() {
+		var userID int
+		interest := models.Interest{}
+		err = rows.Scan(&userID, &interest.ID, &interest.Slug, &interest.Name)
+		if err != nil {
+			return nil, err
+		}
+		interests[userID] = append(interests[userID], interest)
+	}
+	return interests, rows.Err()
+}
+
+/*
+GetPromptsForUsers - each of the users' prompt answers in the order they arranged them, keyed by user id
+*/
+func (r *MySQLProfileRepository) GetPromptsForUsers(userIDs []int) (map[int][]models.PromptAnswer, error) {
+	promptsQuery, args, err := query.Select("user_prompts.user_id", "prompts.id", "prompts.text", "user_prompts.answer").
+		From("user_prompts JOIN prompts ON prompts.id = user_prompts.prompt_id").
+		Where(query.In("user_prompts.user_id", toArgs(userIDs)...)).
+		OrderBy("user_prompts.user_id", "user_prompts.position").
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(promptsQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	answers := make(map[int][]models.PromptAnswer, len(userIDs))
+	for rows.Next() {
+		var userID int
+		answer := models.PromptAnswer{}
+		err = rows.Scan(&userID, &answer.PromptID, &answer.Prompt, &answer.Answer)
+		if err != nil {
+			return nil, err
+		}
+		answers[userID] = append(answers[userID], answer)
+	}
+	return answers, rows.Err()
+}
+
+/*
+SetInterests - replaces the user's interests, in one transaction so other requests never see a partial set
+*/
+func (r *MySQLProfileRepository) SetInterests(userID int, interestIDs []int) error {
+	return r.replace(userID, "DELETE FROM user_interests WHERE user_id = ?",
+		"INSERT INTO user_interests (user_id, interest_id) VALUES ", "(?,?)", len(interestIDs),
+		func(i int) []any {
+			return []any{userID, interestIDs[i]}
+		})
+}
+
+/*
+SetPrompts - replaces the user's prompt answers, they're shown in the order given
+*/
+func (r *MySQLProfileRepository) SetPrompts(userID int, answers []models.PromptAnswer) error {
+	return r.replace(userID, "DELETE FROM user_prompts WHERE user_id = ?",
+		"INSERT INTO user_prompts (user_id, prompt_id, position, answer) VALUES ", "(?,?,?,?)", len(answers),
+		func(i int) []any {
+			return []any{userID, answers[i].PromptID, i, answers[i].Answer}
+		})
+}
+
+/*
+replace - deletes the user's rows and inserts count new ones in a single statement, row supplies the args for each
+*/
+func (r *MySQLProfileRepository) replace(userID int, deleteSQL, insertSQL, rowSQL string, count int, row func(i int) []any) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(deleteSQL, userID)
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		values := make([]string, count)
+		var args []any
+		for i := range values {
+			values[i] = rowSQL
+			args = append(args, row(i)...)
+		}
+
+		_, err = tx.Exec(insertSQL+strings.Join(values, ", "), args...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
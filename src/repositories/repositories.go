@@ -0,0 +1,112 @@
+package repositories
+
+import (
+	"dating-app/src/models"
+	"errors"
+	"time"
+)
+
+/*
+ErrNotFound - returned by every repository implementation when the requested row doesn't exist
+so callers don't need to know whether they're talking to MySQL or memory
+*/
+var ErrNotFound = errors.New("not found")
+
+/*
+ErrDuplicateEmail - returned when creating a user with an email that is already registered
+*/
+var ErrDuplicateEmail = errors.New("email already registered")
+
+/*
+ErrVersionConflict - returned when updating a row that has changed since the version the caller read
+*/
+var ErrVersionConflict = errors.New("version conflict")
+
+/*
+ErrPhotoLimit - returned when adding a photo to a user who already has as many as they're allowed
+*/
+var ErrPhotoLimit = errors.New("photo limit reached")
+
+/*
+UserRepository - storage for user accounts and the profile data attached to them
+*/
+type UserRepository interface {
+	GetByID(userID int) (*models.User, error)
+	GetByEmail(email string) (*models.User, error)
+	GetPasswordsAfter(afterID, limit int) ([]models.User, error)
+	Create(user models.User) (models.User, error)
+	UpdatePassword(userID int, password string) error
+	UpdateProfile(user models.User, version int) (models.User, error)
+	UpdateLocation(userID int, location models.Location, locatedAt time.Time) error
+	GetPreferences(userID int) (models.DiscoveryPreferences, error)
+	SavePreferences(userID int, preferences models.DiscoveryPreferences) error
+}
+
+/*
+MatchRepository - storage for the relationships created by swiping and the profile deck built from them
+*/
+type MatchRepository interface {
+	GetProfilesForUser(userID int, opts models.FilterOpts) ([]*models.Profile, error)
+	Swipe(userID, profileID int, swipe SwipeFunc, rate RateFunc) (*models.Match, error)
+	GetMatches(userID int, after *models.MatchCursor, limit int) ([]*models.MatchSummary, error)
+}
+
+/*
+SwipeFunc - decides what a swipe does to a relationship, which is locked until it returns.
+It changes the relationship in place and returns what the swipe is worth to the swiped user's likability,
+or an error to leave both as they were. A pair that has never swiped has a relationship with no swipes.
+The swipe is recorded in the likability ledger, reversing the entry for the user's previous swipe on the same profile
+*/
+type SwipeFunc func(relationship *models.Match) (likability int, err error)
+
+/*
+RateFunc - the swiped user's new rating once the swipe has been applied, given theirs and the swiper's.
+The swiped user's rating is locked until it returns. It's only called for the swiper's first swipe on the user,
+a changed swipe would otherwise be rated as another game and flipping it back and forth would pump the rating up or down
+*/
+type RateFunc func(swiped, swiper models.Rating) models.Rating
+
+/*
+LikabilityRepository - the ledger of likability changes, users.likability is a cache of each user's total
+*/
+type LikabilityRepository interface {
+	Repair(afterID, limit int) (lastID int, repairs []models.LikabilityRepair, err error)
+}
+
+/*
+ProfileRepository - storage for the interest vocabulary and prompt catalog, and the interests and answers users pick from them
+the catalogs only return active entries, but retired ones stay on the profiles of users who already picked them
+*/
+type ProfileRepository interface {
+	GetInterests() ([]models.Interest, error)
+	GetPrompts() ([]models.Prompt, error)
+	GetInterestsForUsers(userIDs []int) (map[int][]models.Interest, error)
+	GetPromptsForUsers(userIDs []int) (map[int][]models.PromptAnswer, error)
+	SetInterests(userID int, interestIDs []int) error
+	SetPrompts(userID int, answers []models.PromptAnswer) error
+}
+
+/*
+PhotoRepository - storage for the photos on users' profiles, the files themselves are kept in blob storage
+photos are returned in the order the user arranged them, positions start at 0 and have no gaps
+*/
+type PhotoRepository interface {
+	GetPhotosForUsers(userIDs []int) (map[int][]models.Photo, error)
+	CountPhotos(userID int) (int, error)
+	AddPhoto(photo models.Photo, limit int) (models.Photo, error)
+	DeletePhoto(userID, photoID int) (models.Photo, error)
+	OrderPhotos(userID int, photoIDs []int) error
+}
+
+/*
+SessionRepository - storage for login sessions and their refresh token hashes
+*/
+type SessionRepository interface {
+	Create(session *models.Session) error
+	GetByID(sessionID string) (*models.Session, error)
+	GetByRefreshTokenHash(hash string) (*models.Session, error)
+	GetByPreviousTokenHash(hash string) (*models.Session, error)
+	Rotate(sessionID, oldHash, newHash string, usedAt, expiresAt time.Time) (bool, error)
+	Revoke(sessionID string, revokedAt time.Time) error
+	RevokeAllForUser(userID int, revokedAt time.Time) error
+}
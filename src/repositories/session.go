@@ -0,0 +1,131 @@
+package repositories
+
+import (
+	"database/sql"
+	"dating-app/src/models"
+	"errors"
+	"time"
+)
+
+/*
+MySQLSessionRepository - SessionRepository backed by the sessions table
+*/
+type MySQLSessionRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLSessionRepository(db *sql.DB) *MySQLSessionRepository {
+	return &MySQLSessionRepository{
+		db: db,
+	}
+}
+
+const sessionColumns = `id, user_id, refresh_token_hash, COALESCE(previous_token_hash, ''), device_name, user_agent, ip_address,
+created_at, last_used_at, expires_at, revoked_at`
+
+/*
+Create - insert a new session
+*/
+func (r *MySQLSessionRepository) Create(session *models.Session) error {
+	_, err := r.db.Exec(`INSERT INTO sessions (id, user_id, refresh_token_hash, device_name, user_agent, ip_address, created_at, last_used_at, expires_at)
+VALUES (?,?,?,?,?,?,?,?,?)`,
+		session.ID, session.UserID, session.RefreshTokenHash, session.DeviceName, session.UserAgent, session.IPAddress,
+		session.CreatedAt.UTC(), session.LastUsedAt.UTC(), session.ExpiresAt.UTC())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *MySQLSessionRepository) GetByID(sessionID string) (*models.Session, error) {
+	return r.getOne("SELECT "+sessionColumns+" FROM sessions WHERE id = ?", sessionID)
+}
+
+func (r *MySQLSessionRepository) GetByRefreshTokenHash(hash string) (*models.Session, error) {
+	return r.getOne("SELECT "+sessionColumns+" FROM sessions WHERE refresh_token_hash = ?", hash)
+}
+
+func (r *MySQLSessionRepository) GetByPreviousTokenHash(hash string) (*models.Session, error) {
+	return r.getOne("SELECT "+sessionColumns+" FROM sessions WHERE previous_token_hash = ?", hash)
+}
+
+func (r *MySQLSessionRepository) getOne(query string, arg any) (*models.Session, error) {
+	row := r.db.QueryRow(query, arg)
+
+	session := new(models.Session)
+	var createdAt, lastUsedAt, expiresAt string
+	var revokedAt sql.NullString
+	err := row.Scan(&session.ID, &session.UserID, &session.RefreshTokenHash, &session.PreviousTokenHash,
+		&session.DeviceName, &session.UserAgent, &session.IPAddress, &createdAt, &lastUsedAt, &expiresAt, &revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, field := range []struct {
+		value string
+		dest  *time.Time
+	}{{createdAt, &session.CreatedAt}, {lastUsedAt, &session.LastUsedAt}, {expiresAt, &session.ExpiresAt}} {
+		*field.dest, err = parseDateTime(field.value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if revokedAt.Valid {
+		revoked, err := parseDateTime(revokedAt.String)
+		if err != nil {
+			return nil, err
+		}
+		session.RevokedAt = &revoked
+	}
+
+	return session, nil
+}
+
+/*
+Rotate - swaps the refresh token hash, only if the session still has oldHash and hasn't been revoked
+returns false when another request rotated it first, so a refresh token can only ever be used once
+*/
+func (r *MySQLSessionRepository) Rotate(sessionID, oldHash, newHash string, usedAt, expiresAt time.Time) (bool, error) {
+	result, err := r.db.Exec(`UPDATE sessions SET refresh_token_hash = ?, previous_token_hash = ?, last_used_at = ?, expires_at = ?
+WHERE id = ? AND refresh_token_hash = ? AND revoked_at IS NULL`,
+		newHash, oldHash, usedAt.UTC(), expiresAt.UTC(), sessionID, oldHash)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+/*
+Revoke - log out a single session, revoking an already revoked session keeps the original time
+*/
+func (r *MySQLSessionRepository) Revoke(sessionID string, revokedAt time.Time) error {
+	_, err := r.db.Exec("UPDATE sessions SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", revokedAt.UTC(), sessionID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+/*
+RevokeAllForUser - log out every session the user has
+*/
+func (r *MySQLSessionRepository) RevokeAllForUser(userID int, revokedAt time.Time) error {
+	_, err := r.db.Exec("UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", revokedAt.UTC(), userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
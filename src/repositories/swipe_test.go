@@ -0,0 +1,214 @@
+package repositories
+
+import (
+	"dating-app/src/models"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errAlreadySwiped = errors.New("already swiped")
+
+/*
+swipeYes - what a yes does to a relationship, the same as the interactors' swipe without the no cases
+*/
+func swipeYes(userID int) SwipeFunc {
+	return func(relationship *models.Match) (int, error) {
+		if current := relationship.SwipeOf(userID); current != nil {
+			return 0, errAlreadySwiped
+		}
+		yes := models.Yes
+		if userID == relationship.LowUserID {
+			relationship.LowSwipe = &yes
+		} else {
+			relationship.HighSwipe = &yes
+		}
+
+		now := time.Now().UTC().Truncate(time.Second)
+		relationship.State = models.Pending
+		if relationship.LowSwipe != nil && relationship.HighSwipe != nil {
+			relationship.State = models.Matched
+			relationship.MatchedAt = &now
+		}
+		relationship.UpdatedAt = now
+		return 1, nil
+	}
+}
+
+func keepRating(swiped, swiper models.Rating) models.Rating {
+	return swiped
+}
+
+/*
+swipeStore - a match repository along with a way to read back what the swipes stored
+*/
+type swipeStore struct {
+	users   UserRepository
+	matches MatchRepository
+	// rows - the stored relationships for the pair
+	rows func(low, high int) ([]models.Match, error)
+	// ledger - the swiper's ledger entries on the swiped user
+	ledger func(swipedID, swiperID int) ([]models.LikabilityEntry, error)
+}
+
+/*
+testConcurrentMutualYes - pairs of users swipe yes on each other at the same time, each of them several times over.
+Exactly one of each user's swipes takes, the pair has a single matched row and each swipe has one ledger entry
+*/
+func testConcurrentMutualYes(t *testing.T, store swipeStore, pairs, attempts int) {
+	prefix := time.Now().UnixNano()
+	userIDs := make([]int, 2*pairs)
+	for i := range userIDs {
+		user, err := store.users.Create(models.User{
+			Email:        fmt.Sprintf("swipe%d-%d@example.com", prefix, i),
+			Password:     "hash",
+			DistanceUnit: models.Kilometres,
+			Profile: models.Profile{
+				Name:        "Swiper",
+				Gender:      models.NotSpecified,
+				DateOfBirth: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		userIDs[i] = user.ID
+	}
+
+	var wg sync.WaitGroup
+	taken := make([]int, len(userIDs))
+	errs := make(chan error, len(userIDs)*attempts)
+	for i := range userIDs {
+		// users 2n and 2n+1 are a pair
+		swiper, swiped := i, i^1
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for attempt := 0; attempt < attempts; attempt++ {
+				_, err := store.matches.Swipe(userIDs[swiper], userIDs[swiped], swipeYes(userIDs[swiper]), keepRating)
+				switch {
+				case err == nil:
+					taken[swiper]++
+				case !errors.Is(err, errAlreadySwiped):
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("Swipe() error = %v", err)
+	}
+
+	for i := 0; i < len(userIDs); i += 2 {
+		low, high := models.Pair(userIDs[i], userIDs[i+1])
+		if taken[i] != 1 || taken[i+1] != 1 {
+			t.Errorf("pair %d-%d: %d and %d swipes took, want 1 each", low, high, taken[i], taken[i+1])
+		}
+
+		rows, err := store.rows(low, high)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 1 {
+			t.Errorf("pair %d-%d has %d rows, want 1", low, high, len(rows))
+			continue
+		}
+		if rows[0].State != models.Matched || rows[0].MatchedAt == nil {
+			t.Errorf("pair %d-%d is %v matched at %v, want matched", low, high, rows[0].State, rows[0].MatchedAt)
+		}
+
+		for _, swipe := range [][2]int{{low, high}, {high, low}} {
+			entries, err := store.ledger(swipe[1], swipe[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 || entries[0].Swipe != models.Yes || entries[0].Delta != 1 || entries[0].ReversesID != nil {
+				t.Errorf("%d's swipe on %d has ledger entries %+v, want a single yes worth 1", swipe[0], swipe[1], entries)
+			}
+
+			user, err := store.users.GetByID(swipe[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user.LikabilityScore == nil || *user.LikabilityScore != 1 {
+				t.Errorf("%d's likability = %v, want 1", swipe[1], user.LikabilityScore)
+			}
+		}
+	}
+}
+
+func TestMemorySwipeConcurrentMutualYes(t *testing.T) {
+	testConcurrentMutualYes(t, memorySwipeStore(NewMemoryUserRepository()), 200, 5)
+}
+
+func memorySwipeStore(users *MemoryUserRepository) swipeStore {
+	matches := NewMemoryMatchRepository(users)
+	return swipeStore{
+		users:   users,
+		matches: matches,
+		rows: func(low, high int) ([]models.Match, error) {
+			matches.mu.RLock()
+			defer matches.mu.RUnlock()
+			var rows []models.Match
+			for _, match := range matches.matches {
+				if match.LowUserID == low && match.HighUserID == high {
+					rows = append(rows, match)
+				}
+			}
+			return rows, nil
+		},
+		ledger: func(swipedID, swiperID int) ([]models.LikabilityEntry, error) {
+			users.mu.RLock()
+			defer users.mu.RUnlock()
+			var entries []models.LikabilityEntry
+			for _, entry := range users.ledger {
+				if entry.UserID == swipedID && entry.SwiperID == swiperID {
+					entries = append(entries, entry)
+				}
+			}
+			return entries, nil
+		},
+	}
+}
+
+func TestMemorySwipeOnlyRatesTheFirstSwipe(t *testing.T) {
+	users := NewMemoryUserRepository()
+	matches := NewMemoryMatchRepository(users)
+	var userIDs []int
+	for i := 0; i < 2; i++ {
+		user, err := users.Create(models.User{Email: fmt.Sprintf("rated%d@example.com", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		userIDs = append(userIDs, user.ID)
+	}
+	swiper, swiped := userIDs[0], userIDs[1]
+
+	rated := 0
+	countRating := func(swiped, swiper models.Rating) models.Rating {
+		rated++
+		return swiped
+	}
+	for _, preference := range []models.Preference{models.Yes, models.No, models.Yes, models.No, models.Yes} {
+		preference := preference
+		_, err := matches.Swipe(swiper, swiped, func(relationship *models.Match) (int, error) {
+			if swiper == relationship.LowUserID {
+				relationship.LowSwipe = &preference
+			} else {
+				relationship.HighSwipe = &preference
+			}
+			return 1, nil
+		}, countRating)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if rated != 1 {
+		t.Errorf("swiping 5 times rated the user %d times, want only the first swipe", rated)
+	}
+}
@@ -0,0 +1,256 @@
+package repositories
+
+import (
+	"database/sql"
+	"dating-app/src/models"
+	"errors"
+	"time"
+)
+
+/*
+MySQLUserRepository - UserRepository backed by the users table
+*/
+type MySQLUserRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLUserRepository(db *sql.DB) *MySQLUserRepository {
+	return &MySQLUserRepository{
+		db: db,
+	}
+}
+
+/*
+GetByID - returns a user by the provided id
+*/
+func (r *MySQLUserRepository) GetByID(userID int) (*models.User, error) {
+	userQuery := `SELECT id, email, password, name, gender, gender_description, date_of_birth, latitude, longitude, last_located_at, distance_unit,
+bio, job_title, school, height_cm, version, likability FROM users WHERE id = ?;`
+
+	row := r.db.QueryRow(userQuery, userID)
+	user := new(models.User)
+	var dateOfBirth string
+	var lastLocatedAt sql.NullString
+	var heightCM sql.NullInt32
+	var likability int
+	err := row.Scan(&user.ID, &user.Email, &user.Password, &user.Name, &user.Gender, &user.GenderDescription, &dateOfBirth, &user.Latitude, &user.Longitude, &lastLocatedAt, &user.DistanceUnit,
+		&user.Bio, &user.JobTitle, &user.School, &heightCM, &user.Version, &likability)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user.DateOfBirth, err = parseDateTime(dateOfBirth)
+	if err != nil {
+		return nil, err
+	}
+
+	if lastLocatedAt.Valid {
+		locatedAt, err := parseDateTime(lastLocatedAt.String)
+		if err != nil {
+			return nil, err
+		}
+		user.LastLocatedAt = &locatedAt
+	}
+	user.HeightCM = nullableInt(heightCM)
+	user.LikabilityScore = &likability
+
+	return user, nil
+}
+
+/*
+GetByEmail - returns the login details of the user with the matching email
+*/
+func (r *MySQLUserRepository) GetByEmail(email string) (*models.User, error) {
+	userQuery := `SELECT id, email, password FROM users WHERE email = ?`
+
+	row := r.db.QueryRow(userQuery, email)
+	user := new(models.User)
+	err := row.Scan(&user.ID, &user.Email, &user.Password)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+/*
+GetPasswordsAfter - returns the id and stored password of up to limit users with an id greater than afterID
+used to walk the whole table in batches
+*/
+func (r *MySQLUserRepository) GetPasswordsAfter(afterID, limit int) ([]models.User, error) {
+	rows, err := r.db.Query("SELECT id, password FROM users WHERE id > ? ORDER BY id LIMIT ?", afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		user := models.User{}
+		err = rows.Scan(&user.ID, &user.Password)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+/*
+Create - add a new user row and return it with its new id
+*/
+func (r *MySQLUserRepository) Create(user models.User) (models.User, error) {
+	result, err := r.db.Exec(`INSERT INTO users (email, password, name, gender, gender_description, date_of_birth, latitude, longitude, distance_unit, bio, job_title, school, height_cm, created_at)
+VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,UTC_TIMESTAMP())`,
+		user.Email, user.Password, user.Name, user.Gender, user.GenderDescription, user.DateOfBirth, user.Latitude, user.Longitude, user.DistanceUnit,
+		user.Bio, user.JobTitle, user.School, user.HeightCM)
+	if isDuplicateEntry(err) {
+		return user, ErrDuplicateEmail
+	}
+	if err != nil {
+		return user, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return user, err
+	}
+
+	user.ID = int(id)
+	user.Version = 1
+
+	return user, nil
+}
+
+/*
+UpdatePassword - replace the stored password hash for the provided user
+*/
+func (r *MySQLUserRepository) UpdatePassword(userID int, password string) error {
+	_, err := r.db.Exec("UPDATE users set password = ? WHERE id = ?", password, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+/*
+UpdateProfile - saves the user's editable details, only if the row is still at version
+returns the user with its new version, or ErrVersionConflict if someone else updated it first
+*/
+func (r *MySQLUserRepository) UpdateProfile(user models.User, version int) (models.User, error) {
+	result, err := r.db.Exec(`UPDATE users SET name = ?, gender = ?, gender_description = ?, bio = ?, job_title = ?, school = ?, height_cm = ?, distance_unit = ?, version = version + 1
+WHERE id = ? AND version = ?`,
+		user.Name, user.Gender, user.GenderDescription, user.Bio, user.JobTitle, user.School, user.HeightCM, user.DistanceUnit, user.ID, version)
+	if err != nil {
+		return user, err
+	}
+
+	// the version always changes, so no rows affected means the row was at a different version
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return user, err
+	}
+	if affected == 0 {
+		return user, ErrVersionConflict
+	}
+
+	user.Version = version + 1
+
+	return user, nil
+}
+
+/*
+UpdateLocation - record where the user is now
+*/
+func (r *MySQLUserRepository) UpdateLocation(userID int, location models.Location, locatedAt time.Time) error {
+	_, err := r.db.Exec("UPDATE users set latitude = ?, longitude = ?, last_located_at = ? WHERE id = ?",
+		location.Latitude, location.Longitude, locatedAt.UTC(), userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+/*
+GetPreferences - the user's discovery preferences, with MaxDistance in miles
+a user who has never saved any gets the defaults, which don't restrict anything
+*/
+func (r *MySQLUserRepository) GetPreferences(userID int) (models.DiscoveryPreferences, error) {
+	preferences := models.DiscoveryPreferences{Unit: models.Miles}
+
+	var ageMin, ageMax sql.NullInt32
+	var maxDistance sql.NullFloat64
+	row := r.db.QueryRow("SELECT age_min, age_max, max_distance, sort FROM discovery_preferences WHERE user_id = ?", userID)
+	err := row.Scan(&ageMin, &ageMax, &maxDistance, &preferences.Sort)
+	if errors.Is(err, sql.ErrNoRows) {
+		return preferences, nil
+	}
+	if err != nil {
+		return preferences, err
+	}
+
+	preferences.AgeMin = nullableInt(ageMin)
+	preferences.AgeMax = nullableInt(ageMax)
+	if maxDistance.Valid {
+		preferences.MaxDistance = &maxDistance.Float64
+	}
+
+	rows, err := r.db.Query("SELECT show_me FROM discovery_show_me WHERE user_id = ? ORDER BY show_me", userID)
+	if err != nil {
+		return preferences, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var showMe models.ShowMe
+		err = rows.Scan(&showMe)
+		if err != nil {
+			return preferences, err
+		}
+		preferences.ShowMe = append(preferences.ShowMe, showMe)
+	}
+
+	return preferences, rows.Err()
+}
+
+/*
+SavePreferences - replaces the user's discovery preferences
+*/
+func (r *MySQLUserRepository) SavePreferences(userID int, preferences models.DiscoveryPreferences) error {
+	preferences = preferences.In(models.Miles)
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO discovery_preferences (user_id, age_min, age_max, max_distance, sort) VALUES (?,?,?,?,?)
+ON DUPLICATE KEY UPDATE age_min = VALUES(age_min), age_max = VALUES(age_max), max_distance = VALUES(max_distance), sort = VALUES(sort)`,
+		userID, preferences.AgeMin, preferences.AgeMax, preferences.MaxDistance, preferences.Sort)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM discovery_show_me WHERE user_id = ?", userID)
+	if err != nil {
+		return err
+	}
+
+	for _, showMe := range preferences.ShowMe {
+		_, err = tx.Exec("INSERT INTO discovery_show_me (user_id, show_me) VALUES (?,?)", userID, showMe)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
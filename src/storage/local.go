@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+/*
+LocalPath - the path local blobs are served from when no public URL is configured
+*/
+const LocalPath = "/media"
+
+/*
+Local - blobs kept in a directory on this server, served from publicURL
+*/
+type Local struct {
+	dir       string
+	publicURL string
+}
+
+func NewLocal(dir, publicURL string) (*Local, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	if publicURL == "" {
+		publicURL = LocalPath
+	}
+
+	return &Local{
+		dir:       dir,
+		publicURL: publicURL,
+	}, nil
+}
+
+/*
+Dir - the directory the blobs are in
+*/
+func (l *Local) Dir() string {
+	return l.dir
+}
+
+/*
+Put - writes the blob to a temporary file first so a half written file is never served
+*/
+func (l *Local) Put(key, contentType string, body []byte) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+
+	path := filepath.Join(l.dir, filepath.FromSlash(key))
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(tmp.Name(), 0o644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+/*
+Delete - removes the blob, deleting one that doesn't exist isn't an error
+*/
+func (l *Local) Delete(key string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+
+	err := os.Remove(filepath.Join(l.dir, filepath.FromSlash(key)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (l *Local) URL(key string) string {
+	return joinURL(l.publicURL, key)
+}
@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"dating-app/src/config"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+S3 - blobs kept in an S3 compatible bucket, requests are signed with AWS Signature Version 4
+the bucket is expected to allow anonymous reads, the URLs returned point straight at it
+*/
+type S3 struct {
+	cfg       config.S3
+	endpoint  *url.URL
+	publicURL string
+	client    *http.Client
+	now       func() time.Time
+}
+
+func NewS3(cfg config.S3, publicURL string) (*S3, error) {
+	endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("s3 endpoint %q must include the scheme and host", cfg.Endpoint)
+	}
+
+	s3 := &S3{
+		cfg:       cfg,
+		endpoint:  endpoint,
+		publicURL: publicURL,
+		client:    &http.Client{Timeout: 30 * time.Second},
+		now:       time.Now,
+	}
+	if s3.publicURL == "" {
+		s3.publicURL = s3.bucketURL().String()
+	}
+
+	return s3, nil
+}
+
+/*
+Put - uploads the blob, keys are never reused so it can be cached forever
+*/
+func (s *S3) Put(key, contentType string, body []byte) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+
+	request, err := http.NewRequest(http.MethodPut, s.objectURL(key), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", contentType)
+	request.Header.Set("Cache-Control", "public, max-age=31536000, immutable")
+
+	return s.do(request, body)
+}
+
+/*
+Delete - removes the blob, S3 doesn't complain about deleting one that doesn't exist
+*/
+func (s *S3) Delete(key string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+
+	request, err := http.NewRequest(http.MethodDelete, s.objectURL(key), nil)
+	if err != nil {
+		return err
+	}
+
+	return s.do(request, nil)
+}
+
+func (s *S3) URL(key string) string {
+	return joinURL(s.publicURL, key)
+}
+
+func (s *S3) do(request *http.Request, body []byte) error {
+	s.sign(request, body, s.now())
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		// S3 explains the error in an XML body, it's short enough to include
+		detail, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("s3 %s %s: %s: %s", request.Method, request.URL.Path, response.Status, bytes.TrimSpace(detail))
+	}
+
+	return nil
+}
+
+/*
+bucketURL - https://bucket.endpoint, or https://endpoint/bucket with path style addressing
+*/
+func (s *S3) bucketURL() *url.URL {
+	bucket := *s.endpoint
+	if s.cfg.PathStyle {
+		bucket.Path += "/" + s.cfg.Bucket
+	} else {
+		bucket.Host = s.cfg.Bucket + "." + bucket.Host
+	}
+	return &bucket
+}
+
+func (s *S3) objectURL(key string) string {
+	return joinURL(s.bucketURL().String(), key)
+}
+
+const (
+	signingAlgorithm = "AWS4-HMAC-SHA256"
+	amzDateFormat    = "20060102T150405Z"
+)
+
+/*
+sign - adds the Authorization header for AWS Signature Version 4, signing every header already on the request
+https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+*/
+func (s *S3) sign(request *http.Request, body []byte, now time.Time) {
+	now = now.UTC()
+	payloadHash := sha256Hex(body)
+	request.Header.Set("X-Amz-Date", now.Format(amzDateFormat))
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		uriEncode(request.URL.Path, false),
+		canonicalQuery(request.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	date := now.Format("20060102")
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		signingAlgorithm,
+		now.Format(amzDateFormat),
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretAccessKey), date)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signingAlgorithm, s.cfg.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+/*
+uriEncode - percent encodes everything but the unreserved characters, and / unless encodeSlash is set
+*/
+func uriEncode(value string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for _, b := range []byte(value) {
+		switch {
+		case b >= 'A' && b <= 'Z', b >= 'a' && b <= 'z', b >= '0' && b <= '9', b == '-', b == '_', b == '.', b == '~':
+			encoded.WriteByte(b)
+		case b == '/' && !encodeSlash:
+			encoded.WriteByte(b)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return encoded.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
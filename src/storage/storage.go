@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"dating-app/src/config"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/*
+Blobs - somewhere to keep uploaded files, addressed by key
+keys are paths made of the characters [a-z0-9_-.] separated by /, see ValidKey
+*/
+type Blobs interface {
+	Put(key, contentType string, body []byte) error
+	Delete(key string) error
+	URL(key string) string
+}
+
+var ErrInvalidKey = errors.New("invalid blob key")
+
+/*
+New - the blob store the config asks for
+*/
+func New(cfg config.Media) (Blobs, error) {
+	switch cfg.Storage {
+	case config.LocalStorage:
+		return NewLocal(cfg.LocalDir, cfg.PublicURL)
+	case config.S3Storage:
+		return NewS3(cfg.S3, cfg.PublicURL)
+	default:
+		return nil, fmt.Errorf("unknown media storage %q", cfg.Storage)
+	}
+}
+
+/*
+ValidKey - keys can't be empty, absolute or contain empty, . or .. segments, so they can't escape the store
+*/
+func ValidKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+		for _, r := range segment {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func joinURL(base, key string) string {
+	return strings.TrimRight(base, "/") + "/" + key
+}